@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gbm-dev/pots/internal/auth"
 	"github.com/gbm-dev/pots/internal/config"
 	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
 	"github.com/gbm-dev/pots/internal/sshserver"
+	"github.com/gbm-dev/pots/internal/sweep"
+	"github.com/gbm-dev/pots/internal/tui"
 )
 
 func main() {
@@ -41,12 +49,87 @@ func main() {
 	}
 	slog.Info("sites loaded", "count", len(sites), "path", cfg.SitesPath)
 
+	// Snippets are optional
+	snippets, err := config.ParseSnippetsFile(cfg.SnippetsPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		slog.Info("no snippets file", "path", cfg.SnippetsPath)
+	case err != nil:
+		slog.Error("loading snippets", "err", err)
+		os.Exit(1)
+	default:
+		slog.Info("snippets loaded", "count", len(snippets), "path", cfg.SnippetsPath)
+	}
+
 	// Create modem device lock
-	lock := modem.NewDeviceLock(cfg.DevicePath)
-	slog.Info("modem device configured", "device", cfg.DevicePath)
+	devices := modem.ParseDevicePaths(cfg.DevicePath)
+	lock := modem.NewDeviceLock(devices...)
+	slog.Info("modem devices configured", "devices", devices)
+
+	// Every dial outcome is kept for the menu's per-site history
+	dials, err := session.OpenDialLog(filepath.Join(cfg.LogDir, "dials.jsonl"))
+	if err != nil {
+		slog.Error("loading dial history", "err", err)
+		os.Exit(1)
+	}
+
+	// Approvals, and later other access decisions, are audited separately
+	// from the dial history, which is compacted
+	audit, err := session.OpenAuditLog(filepath.Join(cfg.LogDir, "audit.jsonl"))
+	if err != nil {
+		slog.Error("opening audit log", "err", err)
+		os.Exit(1)
+	}
+
+	// Calls live here so they can outlive the SSH session that dialed them
+	calls := session.NewManager(cfg.LogDir, cfg.DetachGrace, cfg.ScrollbackBytes, cfg.AutoRedial, dials)
+
+	// Reachability sweeps are optional
+	var sweeps *sweep.Scheduler
+	if cfg.SweepWindow != "" {
+		window, err := sweep.ParseWindow(cfg.SweepWindow)
+		if err != nil {
+			slog.Error("configuring sweeps", "err", err)
+			os.Exit(1)
+		}
+		var tags []string
+		for _, tag := range strings.Split(cfg.SweepTags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		sweeps = sweep.New(sweep.Config{
+			Window:       window,
+			Tags:         tags,
+			Interval:     cfg.SweepInterval,
+			AlertAfter:   cfg.SweepAlertAfter,
+			AlertCommand: cfg.SweepAlertCommand,
+		}, sites, lock, dials)
+	}
+
+	// Change tickets before dialing are optional hub-wide
+	var tickets *regexp.Regexp
+	if cfg.TicketPattern != "" {
+		tickets, err = config.CompileTicketPattern(cfg.TicketPattern)
+		if err != nil {
+			slog.Error("parsing TICKET_PATTERN", "err", err)
+			os.Exit(1)
+		}
+	}
 
 	// Start SSH server
-	srv, err := sshserver.New(cfg, store, lock, sites)
+	srv, err := sshserver.New(tui.Deps{
+		Config:        cfg,
+		Sites:         sites,
+		Lock:          lock,
+		Store:         store,
+		Calls:         calls,
+		Snippets:      snippets,
+		Sweeps:        sweeps,
+		Audit:         audit,
+		Approvals:     session.NewApprovals(audit),
+		TicketPattern: tickets,
+	})
 	if err != nil {
 		slog.Error("creating SSH server", "err", err)
 		os.Exit(1)
@@ -56,6 +139,11 @@ func main() {
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
 
+	sweepCtx, stopSweeps := context.WithCancel(context.Background())
+	if sweeps != nil {
+		go sweeps.Run(sweepCtx)
+	}
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil {
 			slog.Error("SSH server error", "err", err)
@@ -65,6 +153,7 @@ func main() {
 
 	<-done
 	slog.Info("shutting down")
+	stopSweeps()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -72,6 +161,7 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		slog.Error("shutdown error", "err", err)
 	}
+	calls.HangupAll("hub shutting down")
 
 	slog.Info("shutdown complete")
 }
@@ -3,14 +3,16 @@ package main
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
 )
 
-const userDataDir = "/data/users"
-
 func usage() {
 	fmt.Fprintf(os.Stderr, `Usage: oob-manage <command> [args]
 
@@ -21,7 +23,54 @@ Commands:
   lock <username>     Lock a user account
   unlock <username>   Unlock a user account
   reset <username>    Reset a user's password
-`)
+  grant <username> <right>
+                      Grant an optional right to a user
+  revoke <username> <right>
+                      Revoke a right from a user
+  escape <username> <char>
+                      Set a user's terminal escape character (e.g. "%%" or "^]")
+  priority <username> <0-%d>
+                      Set a user's dial priority; higher dials may preempt lower calls
+  key add <username> <public key | file>
+                      Authorize an SSH public key (authorized_keys line) for a user
+  key list <username>
+                      List a user's SSH keys
+  key remove <username> <fingerprint>
+                      Remove a user's SSH key (SHA256:... as shown by key list)
+  password-login <username> on|off
+                      Allow or refuse password logins (off needs a key)
+  totp-reset <username>
+                      Turn off a user's two-factor login, e.g. after a lost device
+  group add <username> <group>
+                      Put a user in a user group, which access grants can name
+  group remove <username> <group>
+                      Take a user out of a user group
+  access grant <username | group:name> <site | tag:name | group:name | *> <role>
+                      Give a user or user group a role on sites
+  access revoke <username | group:name> <target>
+                      Remove a grant
+  access list         List every grant
+  access show <username>
+                      Show a user's role on each configured site
+  access enforce <on | off>
+                      Limit users to the sites their grants cover, or lift the limit
+  snippet set <username> <name> <text>
+                      Store a snippet for ~p (\n for newlines, {{site.Name}}, {{date}}, ...)
+  snippet list <username>
+                      List a user's snippets
+  snippet remove <username> <name>
+                      Remove a user's snippet
+
+Rights:
+  %s
+
+Roles:
+  viewer    sees the site and reads their own logs of it
+  operator  also dials the site
+  admin     also reads everyone's logs of the site and takes over their calls
+Until access is enforced, every user may dial every site whatever the
+grants say. Users with the admin right are admins on every site.
+`, config.MaxPriority, strings.Join(auth.AllRights, ", "))
 	os.Exit(1)
 }
 
@@ -30,7 +79,8 @@ func main() {
 		usage()
 	}
 
-	store, err := auth.NewFileStore(userDataDir)
+	cfg := config.LoadFromEnv()
+	store, err := auth.NewFileStore(cfg.UserDataDir)
 	if err != nil {
 		fatalf("initializing user store: %v", err)
 	}
@@ -54,6 +104,41 @@ func main() {
 	case "reset":
 		requireArg(2, "username")
 		cmdReset(store, os.Args[2])
+	case "grant":
+		requireArg(2, "username")
+		requireArg(3, "right")
+		cmdGrant(store, os.Args[2], os.Args[3])
+	case "revoke":
+		requireArg(2, "username")
+		requireArg(3, "right")
+		cmdRevoke(store, os.Args[2], os.Args[3])
+	case "escape":
+		requireArg(2, "username")
+		requireArg(3, "char")
+		cmdEscape(store, os.Args[2], os.Args[3])
+	case "priority":
+		requireArg(2, "username")
+		requireArg(3, "priority")
+		cmdPriority(store, os.Args[2], os.Args[3])
+	case "key":
+		requireArg(2, "subcommand")
+		cmdKey(store, os.Args[2])
+	case "password-login":
+		requireArg(2, "username")
+		requireArg(3, "on|off")
+		cmdPasswordLogin(store, os.Args[2], os.Args[3])
+	case "totp-reset":
+		requireArg(2, "username")
+		cmdTOTPReset(store, os.Args[2])
+	case "group":
+		requireArg(2, "subcommand")
+		cmdGroup(store, os.Args[2])
+	case "access":
+		requireArg(2, "subcommand")
+		cmdAccess(store, cfg.SitesPath, os.Args[2])
+	case "snippet":
+		requireArg(2, "subcommand")
+		cmdSnippet(store, os.Args[2])
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
 		usage()
@@ -61,7 +146,7 @@ func main() {
 }
 
 func cmdAdd(store *auth.FileStore, username string) {
-	if !validUsername(username) {
+	if !auth.ValidUsername(username) {
 		fatalf("invalid username %q: must be 2-32 lowercase alphanumeric chars, dots, or hyphens", username)
 	}
 	tempPwd, err := auth.GenerateTemporaryPassword()
@@ -94,7 +179,7 @@ func cmdList(store *auth.FileStore) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "USERNAME\tSTATUS\tLAST LOGIN\tPASSWORD CHANGE")
+	fmt.Fprintln(w, "USERNAME\tSTATUS\tLAST LOGIN\tPASSWORD CHANGE\tKEYS\t2FA\tPRIORITY\tGROUPS\tRIGHTS")
 	for _, u := range users {
 		status := "active"
 		if u.Locked {
@@ -105,10 +190,17 @@ func cmdList(store *auth.FileStore) {
 			lastLogin = u.LastLogin.Format(time.RFC3339)
 		}
 		pwChange := ""
-		if u.ForceChange {
+		switch {
+		case u.PasswordDisabled:
+			pwChange = "login disabled"
+		case u.ForceChange:
 			pwChange = "required"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Username, status, lastLogin, pwChange)
+		totp := ""
+		if u.TOTPEnabled {
+			totp = fmt.Sprintf("on (%d recovery)", u.RecoveryCodesLeft)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s\t%s\n", u.Username, status, lastLogin, pwChange, len(u.AuthorizedKeys), totp, u.Priority, strings.Join(u.Groups, ","), strings.Join(u.Rights, ","))
 	}
 	w.Flush()
 }
@@ -140,16 +232,273 @@ func cmdReset(store *auth.FileStore, username string) {
 	fmt.Println("User must change password on next login.")
 }
 
-func validUsername(s string) bool {
-	if len(s) < 2 || len(s) > 32 {
-		return false
+func cmdGrant(store *auth.FileStore, username, right string) {
+	if err := store.Grant(username, right); err != nil {
+		fatalf("granting right: %v", err)
+	}
+	fmt.Printf("Granted %q to %q.\n", right, username)
+}
+
+func cmdRevoke(store *auth.FileStore, username, right string) {
+	if err := store.Revoke(username, right); err != nil {
+		fatalf("revoking right: %v", err)
+	}
+	fmt.Printf("Revoked %q from %q.\n", right, username)
+}
+
+func cmdEscape(store *auth.FileStore, username, escape string) {
+	if err := store.SetEscapeChar(username, escape); err != nil {
+		fatalf("setting escape character: %v", err)
+	}
+	fmt.Printf("Escape character for %q set to %q.\n", username, escape)
+}
+
+func cmdPriority(store *auth.FileStore, username, value string) {
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		fatalf("invalid priority %q", value)
+	}
+	if err := store.SetPriority(username, priority); err != nil {
+		fatalf("setting priority: %v", err)
+	}
+	fmt.Printf("Priority for %q set to %d.\n", username, priority)
+}
+
+func cmdKey(store *auth.FileStore, sub string) {
+	switch sub {
+	case "add":
+		requireArg(3, "username")
+		requireArg(4, "public key or file")
+		line := os.Args[4]
+		if data, err := os.ReadFile(line); err == nil {
+			line = string(data)
+		}
+		key, err := store.AddKey(os.Args[3], line)
+		if err != nil {
+			fatalf("adding key: %v", err)
+		}
+		fmt.Printf("Key %s added for %q.\n", key.Fingerprint(), os.Args[3])
+	case "list":
+		requireArg(3, "username")
+		info, err := store.Get(os.Args[3])
+		if err != nil {
+			fatalf("loading user: %v", err)
+		}
+		if len(info.AuthorizedKeys) == 0 {
+			fmt.Println("No keys.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FINGERPRINT\tTYPE\tCOMMENT\tADDED")
+		for _, k := range info.AuthorizedKeys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.Fingerprint(), k.Type(), k.Comment, k.Added.Format(time.RFC3339))
+		}
+		w.Flush()
+	case "remove":
+		requireArg(3, "username")
+		requireArg(4, "fingerprint")
+		if err := store.RemoveKey(os.Args[3], os.Args[4]); err != nil {
+			fatalf("removing key: %v", err)
+		}
+		fmt.Printf("Key %s removed from %q.\n", os.Args[4], os.Args[3])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown key command: %s\n", sub)
+		usage()
+	}
+}
+
+func cmdPasswordLogin(store *auth.FileStore, username, value string) {
+	var disabled bool
+	switch value {
+	case "on":
+	case "off":
+		disabled = true
+	default:
+		fatalf("expected on or off, got %q", value)
+	}
+	if err := store.SetPasswordDisabled(username, disabled); err != nil {
+		fatalf("setting password login: %v", err)
+	}
+	fmt.Printf("Password login for %q turned %s.\n", username, value)
+}
+
+func cmdTOTPReset(store *auth.FileStore, username string) {
+	if err := store.ResetTOTP(username); err != nil {
+		fatalf("resetting two-factor login: %v", err)
+	}
+	fmt.Printf("Two-factor login for %q turned off; they can set it up again from the menu.\n", username)
+}
+
+func cmdGroup(store *auth.FileStore, sub string) {
+	requireArg(3, "username")
+	requireArg(4, "group")
+	switch sub {
+	case "add":
+		if err := store.AddToGroup(os.Args[3], os.Args[4]); err != nil {
+			fatalf("adding to group: %v", err)
+		}
+		fmt.Printf("User %q added to group %q.\n", os.Args[3], os.Args[4])
+	case "remove":
+		if err := store.RemoveFromGroup(os.Args[3], os.Args[4]); err != nil {
+			fatalf("removing from group: %v", err)
+		}
+		fmt.Printf("User %q removed from group %q.\n", os.Args[3], os.Args[4])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown group command: %s\n", sub)
+		usage()
+	}
+}
+
+func cmdAccess(store *auth.FileStore, sitesPath, sub string) {
+	switch sub {
+	case "grant":
+		requireArg(3, "username or group:name")
+		requireArg(4, "site, tag:name, group:name or *")
+		requireArg(5, "role")
+		role, err := auth.ParseRole(os.Args[5])
+		if err != nil {
+			fatalf("%v", err)
+		}
+		g := auth.Grant{Subject: os.Args[3], Target: os.Args[4], Role: role}
+		if err := store.AddGrant(g); err != nil {
+			fatalf("adding grant: %v", err)
+		}
+		fmt.Printf("Granted %s on %s to %s.\n", role, g.Target, g.Subject)
+		if sites, err := config.ParseSitesFile(sitesPath); err == nil && !matchesAny(g, sites) {
+			fmt.Printf("Warning: %s matches no site in %s.\n", g.Target, sitesPath)
+		}
+		if enforced, err := store.AccessEnforced(); err == nil && !enforced {
+			fmt.Println("Access is not enforced; every user may still dial every site. Turn it on with: access enforce on")
+		}
+	case "revoke":
+		requireArg(3, "username or group:name")
+		requireArg(4, "target")
+		if err := store.RemoveGrant(os.Args[3], os.Args[4]); err != nil {
+			fatalf("removing grant: %v", err)
+		}
+		fmt.Printf("Grant from %s to %s removed.\n", os.Args[3], os.Args[4])
+	case "list":
+		grants, err := store.Grants()
+		if err != nil {
+			fatalf("listing grants: %v", err)
+		}
+		enforced, err := store.AccessEnforced()
+		if err != nil {
+			fatalf("loading access: %v", err)
+		}
+		if enforced {
+			fmt.Println("Access is enforced; each user may dial only the sites granted below.")
+		} else {
+			fmt.Println("Access is not enforced; every user may dial every site.")
+		}
+		if len(grants) == 0 {
+			fmt.Println("No grants.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SUBJECT\tTARGET\tROLE")
+		for _, g := range grants {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", g.Subject, g.Target, g.Role)
+		}
+		w.Flush()
+	case "show":
+		requireArg(3, "username")
+		access, err := store.Access(os.Args[3])
+		if err != nil {
+			fatalf("loading access: %v", err)
+		}
+		sites, err := config.ParseSitesFile(sitesPath)
+		if err != nil {
+			fatalf("loading sites: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SITE\tGROUP\tTAGS\tROLE")
+		for _, site := range sites {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", site.Name, site.Group, strings.Join(site.Tags, ","), access.Role(site))
+		}
+		w.Flush()
+	case "enforce":
+		requireArg(3, "on or off")
+		var on bool
+		switch os.Args[3] {
+		case "on":
+			on = true
+		case "off":
+		default:
+			fatalf("enforce takes on or off, not %q", os.Args[3])
+		}
+		if on {
+			grants, err := store.Grants()
+			if err != nil {
+				fatalf("listing grants: %v", err)
+			}
+			if len(grants) == 0 {
+				fmt.Println("Warning: there are no grants; only users with the admin right will see any site.")
+			}
+		}
+		if err := store.SetAccessEnforced(on); err != nil {
+			fatalf("setting access enforcement: %v", err)
+		}
+		if on {
+			fmt.Println("Access enforced; users may dial only the sites their grants cover.")
+		} else {
+			fmt.Println("Access no longer enforced; every user may dial every site.")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown access command: %s\n", sub)
+		usage()
 	}
-	for _, c := range s {
-		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '-') {
-			return false
+}
+
+// matchesAny reports whether g covers at least one of sites, to catch
+// typos in site, tag and group names.
+func matchesAny(g auth.Grant, sites []config.Site) bool {
+	return slices.ContainsFunc(sites, g.Matches)
+}
+
+func cmdSnippet(store *auth.FileStore, sub string) {
+	switch sub {
+	case "set":
+		requireArg(3, "username")
+		requireArg(4, "name")
+		requireArg(5, "text")
+		text := config.UnescapeSnippet(os.Args[5])
+		if err := store.SetSnippet(os.Args[3], os.Args[4], text); err != nil {
+			fatalf("setting snippet: %v", err)
+		}
+		fmt.Printf("Snippet %q saved for %q.\n", os.Args[4], os.Args[3])
+	case "list":
+		requireArg(3, "username")
+		info, err := store.Get(os.Args[3])
+		if err != nil {
+			fatalf("loading user: %v", err)
+		}
+		if len(info.Snippets) == 0 {
+			fmt.Println("No snippets.")
+			return
+		}
+		names := make([]string, 0, len(info.Snippets))
+		for name := range info.Snippets {
+			names = append(names, name)
 		}
+		slices.Sort(names)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTEXT")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%q\n", name, info.Snippets[name])
+		}
+		w.Flush()
+	case "remove":
+		requireArg(3, "username")
+		requireArg(4, "name")
+		if err := store.RemoveSnippet(os.Args[3], os.Args[4]); err != nil {
+			fatalf("removing snippet: %v", err)
+		}
+		fmt.Printf("Snippet %q removed from %q.\n", os.Args[4], os.Args[3])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown snippet command: %s\n", sub)
+		usage()
 	}
-	return true
 }
 
 func requireArg(idx int, name string) {
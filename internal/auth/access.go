@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gbm-dev/pots/internal/config"
+)
+
+// Role is what a user may do on a site. Each role includes the ones below
+// it.
+type Role int
+
+const (
+	// RoleNone hides the site from the user.
+	RoleNone Role = iota
+	// RoleViewer shows the site, its status and dial history, and the
+	// user's own session logs for it, but doesn't allow dialing.
+	RoleViewer
+	// RoleOperator allows dialing the site and attaching to one's calls.
+	RoleOperator
+	// RoleAdmin also allows reading every user's session logs for the site
+	// and taking over calls to it that others detached.
+	RoleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "operator", "admin"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole parses viewer, operator or admin.
+func ParseRole(s string) (Role, error) {
+	i := slices.Index(roleNames, s)
+	if i <= int(RoleNone) {
+		return RoleNone, fmt.Errorf("unknown role %q: want viewer, operator or admin", s)
+	}
+	return Role(i), nil
+}
+
+func (r Role) MarshalText() ([]byte, error) { return []byte(r.String()), nil }
+
+func (r *Role) UnmarshalText(b []byte) error {
+	role, err := ParseRole(string(b))
+	*r = role
+	return err
+}
+
+// Grant gives a user, or every member of a user group, a role on a site,
+// the sites with a tag, the sites in a site group, or every site.
+type Grant struct {
+	// Subject is a username, or group:<name> for a user group.
+	Subject string `json:"subject"`
+	// Target is a site name, tag:<tag>, group:<site group>, or * for every
+	// site.
+	Target string `json:"target"`
+	Role   Role   `json:"role"`
+}
+
+const (
+	groupPrefix = "group:"
+	tagPrefix   = "tag:"
+)
+
+// validate checks the subject and target are well formed. Site names
+// aren't checked against the sites file, which the store doesn't read.
+func (g Grant) validate() error {
+	if name, ok := strings.CutPrefix(g.Subject, groupPrefix); ok {
+		if !ValidUsername(name) {
+			return fmt.Errorf("invalid user group %q", name)
+		}
+	} else if !ValidUsername(g.Subject) {
+		return fmt.Errorf("invalid username %q", g.Subject)
+	}
+	for _, prefix := range []string{tagPrefix, groupPrefix} {
+		if name, ok := strings.CutPrefix(g.Target, prefix); ok && name == "" {
+			return fmt.Errorf("target %q names no %s", g.Target, strings.TrimSuffix(prefix, ":"))
+		}
+	}
+	if strings.TrimSpace(g.Target) == "" {
+		return fmt.Errorf("grant needs a target site, tag:, group: or *")
+	}
+	if g.Role <= RoleNone || g.Role > RoleAdmin {
+		return fmt.Errorf("invalid role %v", g.Role)
+	}
+	return nil
+}
+
+// Matches reports whether the grant's target covers site.
+func (g Grant) Matches(site config.Site) bool {
+	switch {
+	case g.Target == "*":
+		return true
+	case strings.HasPrefix(g.Target, tagPrefix):
+		return slices.Contains(site.Tags, strings.TrimPrefix(g.Target, tagPrefix))
+	case strings.HasPrefix(g.Target, groupPrefix):
+		return site.Group == strings.TrimPrefix(g.Target, groupPrefix)
+	}
+	return site.Name == g.Target
+}
+
+// appliesTo reports whether the grant names u or one of u's groups.
+func (g Grant) appliesTo(u *user) bool {
+	if group, ok := strings.CutPrefix(g.Subject, groupPrefix); ok {
+		return slices.Contains(u.Groups, group)
+	}
+	return g.Subject == u.Username
+}
+
+// Access resolves one user's role on each site. The zero Access allows
+// every user to operate every site, as on a hub with no grants at all.
+type Access struct {
+	restricted bool    // the hub has grants, so sites without one are hidden
+	admin      bool    // the user has the admin right: admin on every site
+	grants     []Grant // the grants naming the user or one of their groups
+}
+
+// NoAccess hides every site, for when a user's access can't be read.
+var NoAccess = Access{restricted: true}
+
+// Role returns the user's role on site: the highest of their grants that
+// cover it.
+func (a Access) Role(site config.Site) Role {
+	if a.admin {
+		return RoleAdmin
+	}
+	if !a.restricted {
+		return RoleOperator
+	}
+	best := RoleNone
+	for _, g := range a.grants {
+		if g.Role > best && g.Matches(site) {
+			best = g.Role
+		}
+	}
+	return best
+}
+
+// Access returns the user's access to sites. Until access is enforced
+// every user may dial every site; after that, each site needs a grant.
+// Users with the admin right are admins on every site either way.
+func (s *FileStore) Access(username string) (Access, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return Access{}, err
+	}
+	u := findUser(data, username)
+	if u == nil {
+		return Access{}, fmt.Errorf("user %q not found", username)
+	}
+	a := Access{
+		restricted: data.EnforceAccess,
+		admin:      slices.Contains(u.Rights, RightAdmin),
+	}
+	for _, g := range data.Grants {
+		if g.appliesTo(u) {
+			a.grants = append(a.grants, g)
+		}
+	}
+	return a, nil
+}
+
+// AddGrant adds g, replacing the role of any grant with the same subject
+// and target. The user or group needn't exist yet.
+func (s *FileStore) AddGrant(g Grant) error {
+	if err := g.validate(); err != nil {
+		return err
+	}
+	return s.modifyData(func(data *usersFile) error {
+		i := slices.IndexFunc(data.Grants, func(o Grant) bool { return o.Subject == g.Subject && o.Target == g.Target })
+		if i >= 0 {
+			data.Grants[i].Role = g.Role
+		} else {
+			data.Grants = append(data.Grants, g)
+		}
+		return nil
+	})
+}
+
+// RemoveGrant removes the grant from subject to target.
+func (s *FileStore) RemoveGrant(subject, target string) error {
+	return s.modifyData(func(data *usersFile) error {
+		n := len(data.Grants)
+		data.Grants = slices.DeleteFunc(data.Grants, func(g Grant) bool { return g.Subject == subject && g.Target == target })
+		if len(data.Grants) == n {
+			return fmt.Errorf("no grant from %s to %s", subject, target)
+		}
+		return nil
+	})
+}
+
+// SetAccessEnforced turns grant-based access on or off. While off, grants
+// can be set up but every user may dial every site.
+func (s *FileStore) SetAccessEnforced(on bool) error {
+	return s.modifyData(func(data *usersFile) error {
+		data.EnforceAccess = on
+		return nil
+	})
+}
+
+// AccessEnforced reports whether users are limited to their grants.
+func (s *FileStore) AccessEnforced() (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return false, err
+	}
+	return data.EnforceAccess, nil
+}
+
+// Grants lists every grant, in the order they were added.
+func (s *FileStore) Grants() ([]Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	return data.Grants, nil
+}
+
+// AddToGroup puts the user in a user group, which grants can name as
+// group:<name>.
+func (s *FileStore) AddToGroup(username, group string) error {
+	if !ValidUsername(group) {
+		return fmt.Errorf("invalid group name %q: must be 2-32 lowercase alphanumeric chars, dots, or hyphens", group)
+	}
+	return s.modifyUser(username, func(u *user) {
+		if !slices.Contains(u.Groups, group) {
+			u.Groups = append(u.Groups, group)
+		}
+	})
+}
+
+// RemoveFromGroup takes the user out of a user group.
+func (s *FileStore) RemoveFromGroup(username, group string) error {
+	return s.updateUser(username, func(u *user) error {
+		if !slices.Contains(u.Groups, group) {
+			return fmt.Errorf("user %q is not in group %q", username, group)
+		}
+		u.Groups = slices.DeleteFunc(u.Groups, func(g string) bool { return g == group })
+		return nil
+	})
+}
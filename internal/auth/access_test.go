@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gbm-dev/pots/internal/config"
+)
+
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"viewer", "operator", "admin"} {
+		r, err := ParseRole(name)
+		if err != nil || r.String() != name {
+			t.Errorf("ParseRole(%q) = %v, %v", name, r, err)
+		}
+	}
+	for _, bad := range []string{"", "none", "root"} {
+		if _, err := ParseRole(bad); err == nil {
+			t.Errorf("ParseRole(%q) should fail", bad)
+		}
+	}
+	b, _ := json.Marshal(Grant{Subject: "alice", Target: "*", Role: RoleOperator})
+	if string(b) != `{"subject":"alice","target":"*","role":"operator"}` {
+		t.Errorf("grant JSON = %s", b)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "secret123")
+	s.Add("bob", "secret123")
+	s.Add("root", "secret123")
+	s.Grant("root", RightAdmin)
+
+	router := config.Site{Name: "router1", Group: "NYC", Tags: []string{"core"}}
+	sw := config.Site{Name: "switch1", Group: "NYC", Tags: []string{"access"}}
+	lab := config.Site{Name: "lab1"}
+
+	// With no grants, everyone operates every site.
+	a, err := s.Access("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Role(lab) != RoleOperator {
+		t.Errorf("open hub: role = %v", a.Role(lab))
+	}
+	if a, _ := s.Access("root"); a.Role(lab) != RoleAdmin {
+		t.Errorf("admin right: role = %v", a.Role(lab))
+	}
+
+	for _, g := range []Grant{
+		{Subject: "group:noc", Target: "group:NYC", Role: RoleViewer},
+		{Subject: "alice", Target: "tag:core", Role: RoleOperator},
+		{Subject: "alice", Target: "switch1", Role: RoleViewer},
+		{Subject: "group:noc", Target: "switch1", Role: RoleAdmin},
+	} {
+		if err := s.AddGrant(g); err != nil {
+			t.Fatalf("AddGrant(%+v): %v", g, err)
+		}
+	}
+	if err := s.AddToGroup("bob", "noc"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grants do nothing until access is enforced.
+	if a, _ := s.Access("alice"); a.Role(lab) != RoleOperator {
+		t.Errorf("grants before enforcing: role = %v", a.Role(lab))
+	}
+	if err := s.SetAccessEnforced(true); err != nil {
+		t.Fatal(err)
+	}
+	if on, err := s.AccessEnforced(); err != nil || !on {
+		t.Errorf("AccessEnforced = %v, %v", on, err)
+	}
+
+	tests := []struct {
+		user string
+		site config.Site
+		want Role
+	}{
+		{"alice", router, RoleOperator},
+		{"alice", sw, RoleViewer},
+		{"alice", lab, RoleNone},
+		{"bob", router, RoleViewer},
+		{"bob", sw, RoleAdmin},
+		{"bob", lab, RoleNone},
+		{"root", lab, RoleAdmin},
+	}
+	for _, tt := range tests {
+		a, _ := s.Access(tt.user)
+		if got := a.Role(tt.site); got != tt.want {
+			t.Errorf("%s on %s = %v, want %v", tt.user, tt.site.Name, got, tt.want)
+		}
+	}
+
+	// Granting again changes the role rather than adding a second grant.
+	s.AddGrant(Grant{Subject: "alice", Target: "switch1", Role: RoleOperator})
+	if a, _ := s.Access("alice"); a.Role(sw) != RoleOperator {
+		t.Errorf("regrant: role = %v", a.Role(sw))
+	}
+	if grants, _ := s.Grants(); len(grants) != 4 {
+		t.Errorf("grants = %+v", grants)
+	}
+
+	if err := s.RemoveFromGroup("bob", "noc"); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := s.Access("bob"); a.Role(sw) != RoleNone {
+		t.Errorf("after leaving group: role = %v", a.Role(sw))
+	}
+	if err := s.RemoveFromGroup("bob", "noc"); err == nil {
+		t.Error("RemoveFromGroup of a non-member should fail")
+	}
+
+	if err := s.RemoveGrant("alice", "tag:core"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveGrant("alice", "tag:core"); err == nil {
+		t.Error("removing a missing grant should fail")
+	}
+	s.Remove("alice")
+	if grants, _ := s.Grants(); len(grants) != 2 {
+		t.Errorf("removed user's grants kept: %+v", grants)
+	}
+
+	if err := s.SetAccessEnforced(false); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := s.Access("bob"); a.Role(lab) != RoleOperator {
+		t.Errorf("after lifting enforcement: role = %v", a.Role(lab))
+	}
+}
+
+func TestAddGrantValidation(t *testing.T) {
+	s := newTestStore(t)
+	for _, g := range []Grant{
+		{Subject: "Alice", Target: "router1", Role: RoleOperator},
+		{Subject: "group:", Target: "router1", Role: RoleOperator},
+		{Subject: "alice", Target: "", Role: RoleOperator},
+		{Subject: "alice", Target: "tag:", Role: RoleOperator},
+		{Subject: "alice", Target: "router1", Role: RoleNone},
+	} {
+		if err := s.AddGrant(g); err == nil {
+			t.Errorf("AddGrant(%+v) should fail", g)
+		}
+	}
+	if grants, _ := s.Grants(); len(grants) != 0 {
+		t.Errorf("invalid grants stored: %+v", grants)
+	}
+}
@@ -0,0 +1,22 @@
+package auth
+
+import "fmt"
+
+// DefaultEscapeChar starts terminal escape sequences unless a user picks
+// another, e.g. to avoid clashing with a nested SSH session.
+const DefaultEscapeChar = '~'
+
+// ParseEscapeChar parses a user's escape character setting: either a single
+// printable character such as "~" or "%", or caret notation such as "^]"
+// for a control character. An empty string means the default.
+func ParseEscapeChar(s string) (byte, error) {
+	switch {
+	case s == "":
+		return DefaultEscapeChar, nil
+	case len(s) == 1 && s[0] > ' ' && s[0] < 0x7f:
+		return s[0], nil
+	case len(s) == 2 && s[0] == '^' && s[1] >= '@' && s[1] <= '_':
+		return s[1] - '@', nil
+	}
+	return 0, fmt.Errorf("invalid escape character %q: use a single printable character or ^X notation", s)
+}
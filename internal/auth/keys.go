@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// AuthorizedKey is an SSH public key a user may log in with.
+type AuthorizedKey struct {
+	Key     string    `json:"key"` // authorized_keys form without the comment, e.g. "ssh-ed25519 AAAA..."
+	Comment string    `json:"comment,omitempty"`
+	Added   time.Time `json:"added"`
+}
+
+// ParseAuthorizedKey parses one line of an authorized_keys file, such as
+// the contents of ~/.ssh/id_ed25519.pub. Key options are not supported.
+func ParseAuthorizedKey(line string) (AuthorizedKey, error) {
+	pub, comment, options, _, err := gossh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return AuthorizedKey{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(options) > 0 {
+		return AuthorizedKey{}, fmt.Errorf("key options are not supported")
+	}
+	return AuthorizedKey{
+		Key:     strings.TrimSpace(string(gossh.MarshalAuthorizedKey(pub))),
+		Comment: comment,
+	}, nil
+}
+
+// Type returns the key's algorithm, e.g. "ssh-ed25519".
+func (k AuthorizedKey) Type() string {
+	typ, _, _ := strings.Cut(k.Key, " ")
+	return typ
+}
+
+// Fingerprint returns the key's SHA256 fingerprint as ssh-keygen -l
+// shows it, or "" if the key can't be parsed.
+func (k AuthorizedKey) Fingerprint() string {
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(k.Key))
+	if err != nil {
+		return ""
+	}
+	return gossh.FingerprintSHA256(pub)
+}
+
+// matches reports whether k is pub.
+func (k AuthorizedKey) matches(pub gossh.PublicKey) bool {
+	own, _, _, _, err := gossh.ParseAuthorizedKey([]byte(k.Key))
+	return err == nil && bytes.Equal(own.Marshal(), pub.Marshal())
+}
+
+// AddKey parses an authorized_keys line and adds it to the user's keys.
+func (s *FileStore) AddKey(username, line string) (AuthorizedKey, error) {
+	key, err := ParseAuthorizedKey(line)
+	if err != nil {
+		return AuthorizedKey{}, err
+	}
+	key.Added = time.Now().UTC()
+	var dup bool
+	err = s.modifyUser(username, func(u *user) {
+		dup = slices.ContainsFunc(u.AuthorizedKeys, func(k AuthorizedKey) bool { return k.Key == key.Key })
+		if !dup {
+			u.AuthorizedKeys = append(u.AuthorizedKeys, key)
+		}
+	})
+	if err == nil && dup {
+		return AuthorizedKey{}, fmt.Errorf("key %s is already added", key.Fingerprint())
+	}
+	return key, err
+}
+
+// RemoveKey removes the user's key with the given SHA256 fingerprint. The
+// last key can't be removed while password login is disabled.
+func (s *FileStore) RemoveKey(username, fingerprint string) error {
+	found, last := false, false
+	err := s.modifyUser(username, func(u *user) {
+		i := slices.IndexFunc(u.AuthorizedKeys, func(k AuthorizedKey) bool { return k.Fingerprint() == fingerprint })
+		found = i >= 0
+		last = found && len(u.AuthorizedKeys) == 1 && u.PasswordDisabled
+		if found && !last {
+			u.AuthorizedKeys = slices.Delete(u.AuthorizedKeys, i, i+1)
+		}
+	})
+	switch {
+	case err != nil:
+		return err
+	case !found:
+		return fmt.Errorf("key %s not found", fingerprint)
+	case last:
+		return fmt.Errorf("can't remove the last key while password login is disabled")
+	}
+	return nil
+}
+
+// SetPasswordDisabled turns password login off or back on for the user.
+// It can only be turned off once the user has a key to log in with.
+func (s *FileStore) SetPasswordDisabled(username string, disabled bool) error {
+	noKeys := false
+	err := s.modifyUser(username, func(u *user) {
+		noKeys = disabled && len(u.AuthorizedKeys) == 0
+		if !noKeys {
+			u.PasswordDisabled = disabled
+		}
+	})
+	if err == nil && noKeys {
+		return fmt.Errorf("add a key before disabling password login")
+	}
+	return err
+}
+
+// AuthenticateKey reports whether pub is one of the user's keys. Locked
+// and unknown users are refused.
+func (s *FileStore) AuthenticateKey(username string, pub gossh.PublicKey) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return false, err
+	}
+	u := findUser(data, username)
+	if u == nil || u.Locked {
+		return false, nil
+	}
+	return slices.ContainsFunc(u.AuthorizedKeys, func(k AuthorizedKey) bool { return k.matches(pub) }), nil
+}
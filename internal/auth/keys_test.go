@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testKey returns a fresh public key and its authorized_keys line.
+func testKey(t *testing.T, comment string) (gossh.PublicKey, string) {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))) + " " + comment
+	return key, line
+}
+
+func TestAuthorizedKeys(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "secret123")
+	laptop, laptopLine := testKey(t, "alice@laptop")
+	other, _ := testKey(t, "")
+
+	key, err := s.AddKey("alice", laptopLine)
+	if err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	if key.Comment != "alice@laptop" || key.Type() != "ssh-ed25519" || key.Fingerprint() != gossh.FingerprintSHA256(laptop) {
+		t.Errorf("AddKey = %+v", key)
+	}
+	if _, err := s.AddKey("alice", laptopLine); err == nil {
+		t.Error("expected an error adding the same key twice")
+	}
+	for _, bad := range []string{"", "ssh-ed25519 notbase64", `command="ls" ` + laptopLine} {
+		if _, err := s.AddKey("alice", bad); err == nil {
+			t.Errorf("AddKey(%q): expected error", bad)
+		}
+	}
+
+	if ok, _ := s.AuthenticateKey("alice", laptop); !ok {
+		t.Error("the added key was refused")
+	}
+	if ok, _ := s.AuthenticateKey("alice", other); ok {
+		t.Error("an unknown key was accepted")
+	}
+	s.Lock("alice")
+	if ok, _ := s.AuthenticateKey("alice", laptop); ok {
+		t.Error("a locked user's key was accepted")
+	}
+	s.Unlock("alice")
+
+	if err := s.RemoveKey("alice", "SHA256:nope"); err == nil {
+		t.Error("expected an error removing an unknown key")
+	}
+	if err := s.RemoveKey("alice", key.Fingerprint()); err != nil {
+		t.Fatalf("RemoveKey: %v", err)
+	}
+	if ok, _ := s.AuthenticateKey("alice", laptop); ok {
+		t.Error("a removed key was accepted")
+	}
+}
+
+func TestPasswordDisabled(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "secret123")
+	if err := s.SetPasswordDisabled("alice", true); err == nil {
+		t.Error("password login disabled with no key to fall back on")
+	}
+	_, line := testKey(t, "")
+	key, _ := s.AddKey("alice", line)
+	if err := s.SetPasswordDisabled("alice", true); err != nil {
+		t.Fatalf("SetPasswordDisabled: %v", err)
+	}
+
+	if ok, _ := s.Authenticate("alice", "secret123"); ok {
+		t.Error("password accepted with password login disabled")
+	}
+	if force, _ := s.MustChangePassword("alice"); force {
+		t.Error("password change forced with password login disabled")
+	}
+	if err := s.RemoveKey("alice", key.Fingerprint()); err == nil {
+		t.Error("removed the last key with password login disabled")
+	}
+
+	s.SetPasswordDisabled("alice", false)
+	if ok, _ := s.Authenticate("alice", "secret123"); !ok {
+		t.Error("password refused after re-enabling password login")
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import "slices"
+
+// Rights are optional privileges granted to individual users on top of the
+// baseline ability to dial sites.
+const (
+	// RightReattachAny allows attaching to calls detached by other users.
+	RightReattachAny = "reattach-any"
+	// RightNoTimeout exempts a user's calls from idle and duration limits.
+	RightNoTimeout = "no-timeout"
+	// RightAdmin opens the admin screens for managing users and sessions.
+	RightAdmin = "admin"
+	// RightReadLogs allows reading every session log, not just one's own.
+	RightReadLogs = "read-logs"
+	// RightApprove allows approving other users' dials to protected sites.
+	RightApprove = "approve"
+	// RightNoPreempt keeps a user's calls from being preempted by
+	// higher-priority dials.
+	RightNoPreempt = "no-preempt"
+)
+
+// AllRights lists every right that can be granted, for validation and help.
+var AllRights = []string{
+	RightReattachAny,
+	RightNoTimeout,
+	RightAdmin,
+	RightReadLogs,
+	RightApprove,
+	RightNoPreempt,
+}
+
+// ValidRight reports whether r is a known right.
+func ValidRight(r string) bool {
+	return slices.Contains(AllRights, r)
+}
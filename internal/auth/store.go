@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 // UserStore defines the interface for user management operations.
@@ -22,28 +26,102 @@ type UserStore interface {
 	SetPassword(username, newPassword string) error
 	MustChangePassword(username string) (bool, error)
 	UpdateLastLogin(username string) error
+	Grant(username, right string) error
+	Revoke(username, right string) error
+	HasRight(username, right string) (bool, error)
+	Get(username string) (UserInfo, error)
+	SetEscapeChar(username, escape string) error
+	SetSnippet(username, name, text string) error
+	RemoveSnippet(username, name string) error
+	SetFavorite(username, site string, favorite bool) error
+	SetPriority(username string, priority int) error
+	AddKey(username, line string) (AuthorizedKey, error)
+	RemoveKey(username, fingerprint string) error
+	SetPasswordDisabled(username string, disabled bool) error
+	AuthenticateKey(username string, pub gossh.PublicKey) (bool, error)
+	BeginTOTP(username string) (string, error)
+	ConfirmTOTP(username, code string) ([]string, error)
+	VerifyTOTP(username, code string) (bool, error)
+	NewRecoveryCodes(username, code string) ([]string, error)
+	ResetTOTP(username string) error
+	Access(username string) (Access, error)
+	AddGrant(g Grant) error
+	RemoveGrant(subject, target string) error
+	Grants() ([]Grant, error)
+	SetAccessEnforced(on bool) error
+	AccessEnforced() (bool, error)
+	AddToGroup(username, group string) error
+	RemoveFromGroup(username, group string) error
 }
 
 // UserInfo is the public view of a user for listing.
 type UserInfo struct {
-	Username    string    `json:"username"`
-	Locked      bool      `json:"locked"`
-	LastLogin   time.Time `json:"last_login,omitempty"`
-	ForceChange bool      `json:"force_change"`
+	Username    string            `json:"username"`
+	Locked      bool              `json:"locked"`
+	LastLogin   time.Time         `json:"last_login,omitempty"`
+	ForceChange bool              `json:"force_change"`
+	Rights      []string          `json:"rights,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+	EscapeChar  string            `json:"escape_char,omitempty"`
+	Snippets    map[string]string `json:"snippets,omitempty"`
+	Favorites   []string          `json:"favorites,omitempty"`
+	Priority    int               `json:"priority,omitempty"`
+
+	AuthorizedKeys   []AuthorizedKey `json:"authorized_keys,omitempty"`
+	PasswordDisabled bool            `json:"password_disabled,omitempty"`
+
+	TOTPEnabled       bool `json:"totp_enabled,omitempty"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left,omitempty"`
 }
 
 // user is the internal representation stored in users.json.
 type user struct {
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"password_hash"`
-	Locked       bool      `json:"locked"`
-	ForceChange  bool      `json:"force_change"`
-	LastLogin    time.Time `json:"last_login,omitempty"`
+	Username     string            `json:"username"`
+	PasswordHash string            `json:"password_hash"`
+	Locked       bool              `json:"locked"`
+	ForceChange  bool              `json:"force_change"`
+	LastLogin    time.Time         `json:"last_login,omitempty"`
+	Rights       []string          `json:"rights,omitempty"`
+	Groups       []string          `json:"groups,omitempty"` // user groups, for grants to group:<name>
+	EscapeChar   string            `json:"escape_char,omitempty"`
+	Snippets     map[string]string `json:"snippets,omitempty"`
+	Favorites    []string          `json:"favorites,omitempty"`
+	Priority     int               `json:"priority,omitempty"` // 0 (default) to config.MaxPriority
+
+	// AuthorizedKeys are the SSH public keys the user may log in with;
+	// with PasswordDisabled set, they are the only way in.
+	AuthorizedKeys   []AuthorizedKey `json:"authorized_keys,omitempty"`
+	PasswordDisabled bool            `json:"password_disabled,omitempty"`
+
+	// TOTP second factor. Secrets are encrypted with the key in totp.key;
+	// TOTPPending holds one being enrolled until a code confirms it.
+	// RecoveryCodes are SHA-256 hashes of the unused recovery codes.
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPPending   string   `json:"totp_pending,omitempty"`
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"` // last time step accepted, so codes can't be replayed
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// ValidUsername reports whether s is acceptable as a username: 2-32
+// lowercase letters, digits, dots or hyphens.
+func ValidUsername(s string) bool {
+	if len(s) < 2 || len(s) > 32 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
 }
 
 // usersFile is the top-level structure in users.json.
 type usersFile struct {
-	Users []user `json:"users"`
+	Users  []user  `json:"users"`
+	Grants []Grant `json:"grants,omitempty"`
+	// EnforceAccess limits users to the sites their grants cover.
+	EnforceAccess bool `json:"enforce_access,omitempty"`
 }
 
 // FileStore is a file-backed UserStore using users.json.
@@ -77,7 +155,7 @@ func (s *FileStore) Authenticate(username, password string) (bool, error) {
 		return false, err
 	}
 	u := findUser(data, username)
-	if u == nil || u.Locked {
+	if u == nil || u.Locked || u.PasswordDisabled {
 		return false, nil
 	}
 	return CheckPassword(password, u.PasswordHash)
@@ -122,6 +200,7 @@ func (s *FileStore) Remove(username string) error {
 			return fmt.Errorf("user %q not found", username)
 		}
 		data.Users = append(data.Users[:idx], data.Users[idx+1:]...)
+		data.Grants = slices.DeleteFunc(data.Grants, func(g Grant) bool { return g.Subject == username })
 		return s.write(data)
 	})
 }
@@ -136,16 +215,26 @@ func (s *FileStore) List() ([]UserInfo, error) {
 	}
 	infos := make([]UserInfo, len(data.Users))
 	for i, u := range data.Users {
-		infos[i] = UserInfo{
-			Username:    u.Username,
-			Locked:      u.Locked,
-			LastLogin:   u.LastLogin,
-			ForceChange: u.ForceChange,
-		}
+		infos[i] = u.info()
 	}
 	return infos, nil
 }
 
+func (s *FileStore) Get(username string) (UserInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return UserInfo{}, err
+	}
+	u := findUser(data, username)
+	if u == nil {
+		return UserInfo{}, fmt.Errorf("user %q not found", username)
+	}
+	return u.info(), nil
+}
+
 func (s *FileStore) Lock(username string) error {
 	return s.modifyUser(username, func(u *user) { u.Locked = true })
 }
@@ -188,7 +277,7 @@ func (s *FileStore) MustChangePassword(username string) (bool, error) {
 	if u == nil {
 		return false, fmt.Errorf("user %q not found", username)
 	}
-	return u.ForceChange, nil
+	return u.ForceChange && !u.PasswordDisabled, nil
 }
 
 func (s *FileStore) UpdateLastLogin(username string) error {
@@ -197,8 +286,142 @@ func (s *FileStore) UpdateLastLogin(username string) error {
 	})
 }
 
+func (s *FileStore) Grant(username, right string) error {
+	if !ValidRight(right) {
+		return fmt.Errorf("unknown right %q", right)
+	}
+	return s.modifyUser(username, func(u *user) {
+		if !slices.Contains(u.Rights, right) {
+			u.Rights = append(u.Rights, right)
+		}
+	})
+}
+
+func (s *FileStore) Revoke(username, right string) error {
+	return s.modifyUser(username, func(u *user) {
+		u.Rights = slices.DeleteFunc(u.Rights, func(r string) bool { return r == right })
+	})
+}
+
+func (s *FileStore) HasRight(username, right string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.readLocked()
+	if err != nil {
+		return false, err
+	}
+	u := findUser(data, username)
+	if u == nil {
+		return false, fmt.Errorf("user %q not found", username)
+	}
+	return slices.Contains(u.Rights, right), nil
+}
+
+func (s *FileStore) SetEscapeChar(username, escape string) error {
+	if _, err := ParseEscapeChar(escape); err != nil {
+		return err
+	}
+	return s.modifyUser(username, func(u *user) { u.EscapeChar = escape })
+}
+
+func (s *FileStore) SetSnippet(username, name, text string) error {
+	if name == "" {
+		return fmt.Errorf("snippet name must not be empty")
+	}
+	return s.modifyUser(username, func(u *user) {
+		if u.Snippets == nil {
+			u.Snippets = make(map[string]string)
+		}
+		u.Snippets[name] = text
+	})
+}
+
+func (s *FileStore) RemoveSnippet(username, name string) error {
+	found := true
+	err := s.modifyUser(username, func(u *user) {
+		if _, found = u.Snippets[name]; found {
+			delete(u.Snippets, name)
+		}
+	})
+	if err == nil && !found {
+		return fmt.Errorf("snippet %q not found", name)
+	}
+	return err
+}
+
+// SetFavorite pins site to the top of the user's menu, or unpins it.
+func (s *FileStore) SetFavorite(username, site string, favorite bool) error {
+	return s.modifyUser(username, func(u *user) {
+		i := slices.Index(u.Favorites, site)
+		switch {
+		case favorite && i < 0:
+			u.Favorites = append(u.Favorites, site)
+		case !favorite && i >= 0:
+			u.Favorites = slices.Delete(u.Favorites, i, i+1)
+		}
+	})
+}
+
+// SetPriority sets the user's priority level, 0 to config.MaxPriority.
+func (s *FileStore) SetPriority(username string, priority int) error {
+	if priority < 0 || priority > config.MaxPriority {
+		return fmt.Errorf("priority must be 0 to %d", config.MaxPriority)
+	}
+	return s.modifyUser(username, func(u *user) { u.Priority = priority })
+}
+
+// info returns the public view of u.
+func (u *user) info() UserInfo {
+	return UserInfo{
+		Username:    u.Username,
+		Locked:      u.Locked,
+		LastLogin:   u.LastLogin,
+		ForceChange: u.ForceChange,
+		Rights:      u.Rights,
+		Groups:      u.Groups,
+		EscapeChar:  u.EscapeChar,
+		Snippets:    u.Snippets,
+		Favorites:   u.Favorites,
+		Priority:    u.Priority,
+
+		AuthorizedKeys:   u.AuthorizedKeys,
+		PasswordDisabled: u.PasswordDisabled,
+
+		TOTPEnabled:       u.TOTPSecret != "",
+		RecoveryCodesLeft: len(u.RecoveryCodes),
+	}
+}
+
 // modifyUser applies fn to the named user under write lock + file lock.
 func (s *FileStore) modifyUser(username string, fn func(*user)) error {
+	return s.updateUser(username, func(u *user) error {
+		fn(u)
+		return nil
+	})
+}
+
+// modifyData applies fn to the whole store under write lock + file lock;
+// nothing is written if fn returns an error.
+func (s *FileStore) modifyData(fn func(*usersFile) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.withFileLock(func() error {
+		data, err := s.readLocked()
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		return s.write(data)
+	})
+}
+
+// updateUser is modifyUser for changes that can fail; nothing is written
+// if fn returns an error.
+func (s *FileStore) updateUser(username string, fn func(*user) error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -211,7 +434,9 @@ func (s *FileStore) modifyUser(username string, fn func(*user)) error {
 		if idx < 0 {
 			return fmt.Errorf("user %q not found", username)
 		}
-		fn(&data.Users[idx])
+		if err := fn(&data.Users[idx]); err != nil {
+			return err
+		}
 		return s.write(data)
 	})
 }
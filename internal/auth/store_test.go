@@ -236,3 +236,151 @@ func TestMigrateNoLegacyFiles(t *testing.T) {
 		t.Fatalf("expected no error: %v", err)
 	}
 }
+
+func TestGrantRevokeRight(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "pw")
+
+	has, err := s.HasRight("alice", RightReattachAny)
+	if err != nil {
+		t.Fatalf("HasRight: %v", err)
+	}
+	if has {
+		t.Error("expected no rights on new user")
+	}
+
+	if err := s.Grant("alice", RightReattachAny); err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	// Granting twice must not duplicate
+	s.Grant("alice", RightReattachAny)
+
+	users, _ := s.List()
+	if len(users[0].Rights) != 1 {
+		t.Errorf("expected 1 right, got %v", users[0].Rights)
+	}
+	has, _ = s.HasRight("alice", RightReattachAny)
+	if !has {
+		t.Error("expected right after grant")
+	}
+
+	s.Revoke("alice", RightReattachAny)
+	has, _ = s.HasRight("alice", RightReattachAny)
+	if has {
+		t.Error("expected right to be gone after revoke")
+	}
+}
+
+func TestGrantUnknownRight(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "pw")
+	if err := s.Grant("alice", "fly"); err == nil {
+		t.Error("expected error granting unknown right")
+	}
+}
+
+func TestParseEscapeChar(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    byte
+		wantErr bool
+	}{
+		{"", '~', false},
+		{"%", '%', false},
+		{"^]", 0x1d, false},
+		{"^A", 0x01, false},
+		{" ", 0, true},
+		{"ab", 0, true},
+		{"^a", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEscapeChar(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseEscapeChar(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseEscapeChar(%q) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeCharAndSnippets(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "pw")
+
+	if err := s.SetEscapeChar("alice", "bad!"); err == nil {
+		t.Error("expected error for invalid escape char")
+	}
+	if err := s.SetEscapeChar("alice", "^]"); err != nil {
+		t.Fatalf("SetEscapeChar: %v", err)
+	}
+	if err := s.SetSnippet("alice", "nopage", "terminal length 0\n"); err != nil {
+		t.Fatalf("SetSnippet: %v", err)
+	}
+
+	info, err := s.Get("alice")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if info.EscapeChar != "^]" {
+		t.Errorf("EscapeChar = %q, want ^]", info.EscapeChar)
+	}
+	if info.Snippets["nopage"] != "terminal length 0\n" {
+		t.Errorf("Snippets = %v", info.Snippets)
+	}
+
+	if err := s.RemoveSnippet("alice", "nopage"); err != nil {
+		t.Fatalf("RemoveSnippet: %v", err)
+	}
+	if err := s.RemoveSnippet("alice", "nopage"); err == nil {
+		t.Error("expected error removing missing snippet")
+	}
+	if _, err := s.Get("ghost"); err == nil {
+		t.Error("expected error getting unknown user")
+	}
+}
+
+func TestFavorites(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "pw")
+
+	for _, site := range []string{"router1", "nyc-sw1", "router1"} {
+		if err := s.SetFavorite("alice", site, true); err != nil {
+			t.Fatalf("SetFavorite: %v", err)
+		}
+	}
+	if err := s.SetFavorite("alice", "router1", false); err != nil {
+		t.Fatalf("SetFavorite: %v", err)
+	}
+	info, err := s.Get("alice")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(info.Favorites) != 1 || info.Favorites[0] != "nyc-sw1" {
+		t.Errorf("Favorites = %q, want [nyc-sw1]", info.Favorites)
+	}
+	if err := s.SetFavorite("ghost", "router1", true); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "pw")
+
+	if err := s.SetPriority("alice", 7); err != nil {
+		t.Fatalf("SetPriority: %v", err)
+	}
+	if info, _ := s.Get("alice"); info.Priority != 7 {
+		t.Errorf("Priority = %d, want 7", info.Priority)
+	}
+	for _, p := range []int{-1, 10} {
+		if err := s.SetPriority("alice", p); err == nil {
+			t.Errorf("SetPriority(%d): expected error", p)
+		}
+	}
+	if err := s.SetPriority("ghost", 1); err == nil {
+		t.Error("expected error for unknown user")
+	}
+}
@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, the defaults every authenticator app supports.
+const (
+	totpDigits = 6
+	totpPeriod = 30 // seconds per time step
+	totpSkew   = 1  // steps of clock drift accepted either way
+
+	recoveryCodeCount = 10
+)
+
+// TOTPIssuer names the hub in authenticator apps.
+const TOTPIssuer = "OOB Hub"
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit secret in base32, the form
+// authenticator apps take.
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCode returns the RFC 6238 code for secret at t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/totpPeriod)), nil
+}
+
+// hotp is the RFC 4226 HMAC-based one-time password for counter.
+func hotp(key []byte, counter uint64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, v%1_000_000)
+}
+
+// matchTOTP returns the time step whose code for secret is code, allowing
+// totpSkew steps of drift around t.
+func matchTOTP(secret, code string, t time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	now := t.Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step))), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps scan to enroll
+// username with secret.
+func TOTPURI(username, secret string) string {
+	v := url.Values{"secret": {secret}, "issuer": {TOTPIssuer}}
+	return "otpauth://totp/" + url.PathEscape(TOTPIssuer+":"+username) + "?" + v.Encode()
+}
+
+// newRecoveryCodes returns single-use codes such as "k3x9q-7mwpd" and the
+// hashes to store for them.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	enc := base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+	for range recoveryCodeCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		s := enc.EncodeToString(b)[:10]
+		code := s[:5] + "-" + s[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginTOTP starts enrolling the user, returning a new secret for their
+// authenticator app. Until ConfirmTOTP sees a code from it, the user logs
+// in as before. Starting again replaces the pending secret.
+func (s *FileStore) BeginTOTP(username string) (string, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	err = s.updateUser(username, func(u *user) error {
+		if u.TOTPSecret != "" {
+			return fmt.Errorf("two-factor login is already set up")
+		}
+		u.TOTPPending, err = s.seal(secret)
+		return err
+	})
+	return secret, err
+}
+
+// ConfirmTOTP completes enrollment if code matches the pending secret. It
+// returns the user's recovery codes, which are only ever shown this once.
+func (s *FileStore) ConfirmTOTP(username, code string) ([]string, error) {
+	var codes []string
+	err := s.updateUser(username, func(u *user) error {
+		if u.TOTPPending == "" {
+			return fmt.Errorf("two-factor setup has not been started")
+		}
+		secret, err := s.open(u.TOTPPending)
+		if err != nil {
+			return err
+		}
+		step, ok := matchTOTP(secret, strings.TrimSpace(code), time.Now())
+		if !ok {
+			return fmt.Errorf("code does not match; check the time on your device")
+		}
+		var hashes []string
+		if codes, hashes, err = newRecoveryCodes(); err != nil {
+			return err
+		}
+		u.TOTPSecret, u.TOTPPending = u.TOTPPending, ""
+		u.TOTPLastStep = step
+		u.RecoveryCodes = hashes
+		return nil
+	})
+	return codes, err
+}
+
+// VerifyTOTP checks a login code: a current code from the user's
+// authenticator that hasn't been used before, or an unused recovery code,
+// which is then spent. Locked and unenrolled users are refused.
+func (s *FileStore) VerifyTOTP(username, code string) (bool, error) {
+	ok := false
+	err := s.updateUser(username, func(u *user) error {
+		if u.Locked || u.TOTPSecret == "" {
+			return nil
+		}
+		secret, err := s.open(u.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		code = strings.TrimSpace(code)
+		if step, match := matchTOTP(secret, code, time.Now()); match && step > u.TOTPLastStep {
+			u.TOTPLastStep, ok = step, true
+			return nil
+		}
+		hash := hashRecoveryCode(code)
+		if i := slices.Index(u.RecoveryCodes, hash); i >= 0 {
+			u.RecoveryCodes = slices.Delete(u.RecoveryCodes, i, i+1)
+			ok = true
+		}
+		return nil
+	})
+	return ok, err
+}
+
+// NewRecoveryCodes replaces the user's recovery codes if code is a valid
+// code from their authenticator.
+func (s *FileStore) NewRecoveryCodes(username, code string) ([]string, error) {
+	var codes []string
+	err := s.updateUser(username, func(u *user) error {
+		if u.TOTPSecret == "" {
+			return fmt.Errorf("two-factor login is not set up")
+		}
+		secret, err := s.open(u.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		step, ok := matchTOTP(secret, strings.TrimSpace(code), time.Now())
+		if !ok || step <= u.TOTPLastStep {
+			return fmt.Errorf("code does not match")
+		}
+		var hashes []string
+		if codes, hashes, err = newRecoveryCodes(); err != nil {
+			return err
+		}
+		u.TOTPLastStep = step
+		u.RecoveryCodes = hashes
+		return nil
+	})
+	return codes, err
+}
+
+// ResetTOTP removes the user's two-factor enrollment, e.g. after they lose
+// their authenticator. They can enroll again from the menu.
+func (s *FileStore) ResetTOTP(username string) error {
+	return s.modifyUser(username, func(u *user) {
+		u.TOTPSecret, u.TOTPPending, u.TOTPLastStep, u.RecoveryCodes = "", "", 0, nil
+	})
+}
+
+// secretKey returns the key TOTP secrets are encrypted with, creating
+// totp.key next to users.json on first use. Callers hold the file lock.
+func (s *FileStore) secretKey() ([]byte, error) {
+	path := filepath.Join(filepath.Dir(s.path), "totp.key")
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return nil, fmt.Errorf("writing TOTP key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading TOTP key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("TOTP key %s: want 32 bytes, got %d", path, len(key))
+	}
+	return key, nil
+}
+
+// seal encrypts a TOTP secret for users.json with AES-256-GCM.
+func (s *FileStore) seal(secret string) (string, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// open decrypts a secret sealed by seal.
+func (s *FileStore) open(sealed string) (string, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("corrupt TOTP secret")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting TOTP secret: %w", err)
+	}
+	return string(plain), nil
+}
+
+func (s *FileStore) aead() (cipher.AEAD, error) {
+	key, err := s.secretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"encoding/base32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The SHA-1 test vectors from RFC 6238 appendix B, truncated to six digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("alice", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/OOB%20Hub:alice?issuer=OOB+Hub&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("TOTPURI = %q, want %q", got, want)
+	}
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "secret123")
+
+	if _, err := s.ConfirmTOTP("alice", "123456"); err == nil {
+		t.Error("ConfirmTOTP before BeginTOTP should fail")
+	}
+	secret, err := s.BeginTOTP("alice")
+	if err != nil {
+		t.Fatalf("BeginTOTP: %v", err)
+	}
+	if info, _ := s.Get("alice"); info.TOTPEnabled {
+		t.Error("TOTP enabled before it was confirmed")
+	}
+	data, _ := os.ReadFile(filepath.Join(filepath.Dir(s.path), "users.json"))
+	if strings.Contains(string(data), secret) {
+		t.Error("users.json holds the TOTP secret in the clear")
+	}
+
+	code, _ := TOTPCode(secret, time.Now())
+	recovery, err := s.ConfirmTOTP("alice", code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	if len(recovery) != recoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(recovery), recoveryCodeCount)
+	}
+	if info, _ := s.Get("alice"); !info.TOTPEnabled || info.RecoveryCodesLeft != recoveryCodeCount {
+		t.Errorf("after confirm: %+v", info)
+	}
+	if _, err := s.BeginTOTP("alice"); err == nil {
+		t.Error("BeginTOTP should refuse while enrolled")
+	}
+
+	// The code that confirmed enrollment can't be used again to log in.
+	if ok, _ := s.VerifyTOTP("alice", code); ok {
+		t.Error("confirmation code was accepted again")
+	}
+	if ok, _ := s.VerifyTOTP("alice", "000000"); ok {
+		t.Error("wrong code accepted")
+	}
+
+	// Recovery codes work once each, with or without the dash.
+	if ok, _ := s.VerifyTOTP("alice", strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))); !ok {
+		t.Error("recovery code rejected")
+	}
+	if ok, _ := s.VerifyTOTP("alice", recovery[0]); ok {
+		t.Error("recovery code accepted twice")
+	}
+	if info, _ := s.Get("alice"); info.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Errorf("RecoveryCodesLeft = %d", info.RecoveryCodesLeft)
+	}
+
+	s.Lock("alice")
+	if ok, _ := s.VerifyTOTP("alice", recovery[1]); ok {
+		t.Error("locked user passed the second factor")
+	}
+	s.Unlock("alice")
+
+	if err := s.ResetTOTP("alice"); err != nil {
+		t.Fatalf("ResetTOTP: %v", err)
+	}
+	if info, _ := s.Get("alice"); info.TOTPEnabled || info.RecoveryCodesLeft != 0 {
+		t.Errorf("after reset: %+v", info)
+	}
+	if ok, _ := s.VerifyTOTP("alice", recovery[1]); ok {
+		t.Error("recovery code survived reset")
+	}
+}
+
+func TestTOTPKeyFile(t *testing.T) {
+	s := newTestStore(t)
+	s.Add("alice", "secret123")
+	secret, _ := s.BeginTOTP("alice")
+
+	path := filepath.Join(filepath.Dir(s.path), "totp.key")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("totp.key not created: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("totp.key mode = %v", fi.Mode().Perm())
+	}
+
+	// A store opened on the same directory decrypts with the same key.
+	s2, err := NewFileStore(filepath.Dir(s.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(secret, time.Now())
+	if _, err := s2.ConfirmTOTP("alice", code); err != nil {
+		t.Errorf("ConfirmTOTP from a second store: %v", err)
+	}
+}
@@ -3,29 +3,74 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // AppConfig holds application configuration loaded from environment variables.
 type AppConfig struct {
-	SSHAddress  string
-	SSHPort     int
-	DevicePath  string
-	SitesPath   string
-	UserDataDir string
-	LogDir      string
-	HostKeyDir  string
+	SSHAddress   string
+	SSHPort      int
+	DevicePath   string // comma-separated for a pool of modems
+	SitesPath    string
+	SnippetsPath string // optional site and global snippets
+	UserDataDir  string
+	LogDir       string
+	HostKeyDir   string
+
+	// DetachGrace is how long a call stays up after its SSH connection
+	// drops, waiting for someone to reattach. Zero hangs up immediately.
+	DetachGrace time.Duration
+	// ScrollbackBytes is how much recent modem output is kept per call
+	// and replayed on reattach.
+	ScrollbackBytes int
+	// IdleTimeout hangs up a call after this long without user input and
+	// MaxCallDuration after this long in total. Zero disables either;
+	// sites may override both.
+	IdleTimeout     time.Duration
+	MaxCallDuration time.Duration
+	// AutoRedial is how many times to redial a call after carrier loss
+	// before giving up. Zero disables; sites may override.
+	AutoRedial int
+
+	// Reachability sweeps dial each site (or those with one of SweepTags,
+	// comma-separated) once per SweepInterval during SweepWindow
+	// ("HH:MM-HH:MM", local time; empty disables). SweepAlertAfter failures
+	// in a row raise an alert, running SweepAlertCommand if set.
+	SweepWindow       string
+	SweepTags         string
+	SweepInterval     time.Duration
+	SweepAlertAfter   int
+	SweepAlertCommand string
+
+	// TicketPattern, if set, is a regex every site's change ticket must
+	// match before it is dialed; sites may override or opt out.
+	TicketPattern string
 }
 
 // LoadFromEnv loads configuration from environment variables with defaults.
 func LoadFromEnv() AppConfig {
 	return AppConfig{
-		SSHAddress:  envStr("SSH_ADDRESS", ""),
-		SSHPort:     envInt("SSH_PORT", 2222),
-		DevicePath:  envStr("DEVICE_PATH", "/dev/ttySL0"),
-		SitesPath:   envStr("SITES_PATH", "/etc/oob-sites.conf"),
-		UserDataDir: envStr("USER_DATA_DIR", "/data/users"),
-		LogDir:      envStr("LOG_DIR", "/var/log/oob-sessions"),
-		HostKeyDir:  envStr("HOST_KEY_DIR", "/data/users/ssh_host_keys"),
+		SSHAddress:      envStr("SSH_ADDRESS", ""),
+		SSHPort:         envInt("SSH_PORT", 2222),
+		DevicePath:      envStr("DEVICE_PATH", "/dev/ttySL0"),
+		SitesPath:       envStr("SITES_PATH", "/etc/oob-sites.conf"),
+		SnippetsPath:    envStr("SNIPPETS_PATH", "/etc/oob-snippets.conf"),
+		UserDataDir:     envStr("USER_DATA_DIR", "/data/users"),
+		LogDir:          envStr("LOG_DIR", "/var/log/oob-sessions"),
+		HostKeyDir:      envStr("HOST_KEY_DIR", "/data/users/ssh_host_keys"),
+		DetachGrace:     envDuration("DETACH_GRACE", 10*time.Minute),
+		ScrollbackBytes: envInt("SCROLLBACK_BYTES", 64*1024),
+		IdleTimeout:     envDuration("IDLE_TIMEOUT", 0),
+		MaxCallDuration: envDuration("MAX_CALL_DURATION", 0),
+		AutoRedial:      envInt("AUTO_REDIAL", 0),
+
+		SweepWindow:       envStr("SWEEP_WINDOW", ""),
+		SweepTags:         envStr("SWEEP_TAGS", ""),
+		SweepInterval:     envDuration("SWEEP_INTERVAL", 24*time.Hour),
+		SweepAlertAfter:   envInt("SWEEP_ALERT_AFTER", 3),
+		SweepAlertCommand: envStr("SWEEP_ALERT_COMMAND", ""),
+
+		TicketPattern: envStr("TICKET_PATTERN", ""),
 	}
 }
 
@@ -44,3 +89,12 @@ func envInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
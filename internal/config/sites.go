@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Site represents a remote console site.
@@ -15,15 +18,150 @@ type Site struct {
 	Description string
 	BaudRate    int
 	ModemInit   []string // optional AT commands sent after Init, before Dial
+
+	// Group places the site under a heading in the menu; Tags (region,
+	// customer, device type, ...) can be filtered on with tag:name.
+	Group string
+	Tags  []string
+
+	// SkipSweep (sweep=false) leaves the site out of scheduled
+	// reachability sweeps. Protected sites are left out too, unless
+	// ForceSweep (sweep=true) asks for them.
+	SkipSweep  bool
+	ForceSweep bool
+
+	// Ticket, if set, is the pattern the change ticket must match before
+	// the site is dialed, overriding TICKET_PATTERN. NoTicket exempts the
+	// site from TICKET_PATTERN.
+	Ticket   *regexp.Regexp
+	NoTicket bool
+
+	// Protected sites are only dialed once a second person approves.
+	Protected bool
+
+	// Priority ranks dials to the site, 0 (default) to MaxPriority. A dial
+	// ranks at the higher of its site's and its user's priority.
+	Priority int
+
+	// Per-site overrides from the options field. Zero values fall back to
+	// the hub-wide defaults.
+	IdleTimeout time.Duration // hang up after this long without user input
+	MaxDuration time.Duration // hang up after this long regardless of activity
+	RawMode     bool          // start sessions in raw pass-through mode
+	AutoRedial  int           // redial attempts after carrier loss; negative disables
+
+	// Paste pacing for slow consoles. CharDelay and LineDelay are slept
+	// after each pasted character and line. With PasteWait, each pasted
+	// line after the first waits until the output matches Prompt.
+	CharDelay     time.Duration
+	LineDelay     time.Duration
+	Prompt        *regexp.Regexp // the device's command prompt, e.g. [>#] ?$
+	PromptTimeout time.Duration  // how long to wait for Prompt; zero means the default
+	PasteWait     bool
+
+	// Login is the chat script batch runs use to log in after connecting.
+	Login []ChatStep
+}
+
+// MaxPriority is the highest priority level a user or site can have. A dial
+// may preempt calls that rank below it.
+const MaxPriority = 9
+
+// ChatStep is one expect/send pair of a login chat script. A nil Expect
+// sends without waiting. Send may refer to hub environment variables as
+// $NAME, so passwords can be kept out of the sites file; see SendText.
+type ChatStep struct {
+	Expect *regexp.Regexp
+	Send   string
+}
+
+// SendText returns the text to send, with environment variables expanded
+// and a carriage return appended.
+func (c ChatStep) SendText() string {
+	return os.ExpandEnv(c.Send) + "\r"
+}
+
+// parseChat parses a chat(8)-style script: alternating expect and send
+// strings separated by spaces, with "" for an empty one. Expect strings
+// are regular expressions.
+func parseChat(s string) ([]ChatStep, error) {
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("expected expect/send pairs, got %d strings", len(fields))
+	}
+	var steps []ChatStep
+	for i := 0; i < len(fields); i += 2 {
+		var step ChatStep
+		if expect := unquoteEmpty(fields[i]); expect != "" {
+			re, err := regexp.Compile(expect)
+			if err != nil {
+				return nil, err
+			}
+			step.Expect = re
+		}
+		step.Send = unquoteEmpty(fields[i+1])
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func unquoteEmpty(s string) string {
+	if s == `""` {
+		return ""
+	}
+	return s
+}
+
+// DefaultPromptTimeout is how long to wait for a site's prompt when the site
+// does not set prompt_timeout.
+const DefaultPromptTimeout = 10 * time.Second
+
+// HasTag reports whether the site carries tag, ignoring case.
+func (s Site) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// TicketRule returns the pattern a change ticket for this site must match,
+// given the hub-wide pattern (nil if none), or nil if the site can be
+// dialed without one.
+func (s Site) TicketRule(hub *regexp.Regexp) *regexp.Regexp {
+	switch {
+	case s.NoTicket:
+		return nil
+	case s.Ticket != nil:
+		return s.Ticket
+	}
+	return hub
+}
+
+// CompileTicketPattern compiles a change ticket pattern, anchored so the
+// whole ticket must match rather than just part of it.
+func CompileTicketPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// PromptWait returns how long to wait for the site's prompt.
+func (s Site) PromptWait() time.Duration {
+	if s.PromptTimeout > 0 {
+		return s.PromptTimeout
+	}
+	return DefaultPromptTimeout
 }
 
 // ParseSites reads site definitions from r.
 // Each non-blank, non-comment line must be:
 //
 //	name|phone|description|baud_rate
-//	name|phone|description|baud_rate|AT+MS=132,0,4800,9600,AT+OTHER
+//	name|phone|description|baud_rate|AT+MS=132,0,4800,9600;AT+OTHER
+//	name|phone|description|baud_rate|modem_init|idle_timeout=15m;mode=raw
 //
 // The 5th field (modem init commands) is optional and semicolon-separated.
+// The 6th field is optional semicolon-separated key=value site options.
 func ParseSites(r io.Reader) ([]Site, error) {
 	var sites []Site
 	scanner := bufio.NewScanner(r)
@@ -34,9 +172,9 @@ func ParseSites(r io.Reader) ([]Site, error) {
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		parts := strings.SplitN(line, "|", 5)
+		parts := strings.SplitN(line, "|", 6)
 		if len(parts) < 4 {
-			return nil, fmt.Errorf("line %d: expected 4-5 pipe-delimited fields, got %d", lineNum, len(parts))
+			return nil, fmt.Errorf("line %d: expected 4-6 pipe-delimited fields, got %d", lineNum, len(parts))
 		}
 		baud, err := strconv.Atoi(strings.TrimSpace(parts[3]))
 		if err != nil {
@@ -48,7 +186,7 @@ func ParseSites(r io.Reader) ([]Site, error) {
 			Description: strings.TrimSpace(parts[2]),
 			BaudRate:    baud,
 		}
-		if len(parts) == 5 && strings.TrimSpace(parts[4]) != "" {
+		if len(parts) >= 5 && strings.TrimSpace(parts[4]) != "" {
 			for _, cmd := range strings.Split(parts[4], ";") {
 				cmd = strings.TrimSpace(cmd)
 				if cmd != "" {
@@ -56,6 +194,11 @@ func ParseSites(r io.Reader) ([]Site, error) {
 				}
 			}
 		}
+		if len(parts) == 6 {
+			if err := parseSiteOptions(&site, parts[5]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		}
 		sites = append(sites, site)
 	}
 	if err := scanner.Err(); err != nil {
@@ -64,6 +207,94 @@ func ParseSites(r io.Reader) ([]Site, error) {
 	return sites, nil
 }
 
+// parseSiteOptions applies semicolon-separated key=value options to site.
+func parseSiteOptions(site *Site, field string) error {
+	for _, opt := range strings.Split(field, ";") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return fmt.Errorf("option %q: expected key=value", opt)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch key {
+		case "idle_timeout":
+			site.IdleTimeout, err = time.ParseDuration(value)
+		case "max_duration":
+			site.MaxDuration, err = time.ParseDuration(value)
+		case "mode":
+			switch value {
+			case "raw":
+				site.RawMode = true
+			case "line":
+				site.RawMode = false
+			default:
+				err = fmt.Errorf("must be raw or line, got %q", value)
+			}
+		case "auto_redial":
+			if value == "off" {
+				site.AutoRedial = -1
+				break
+			}
+			site.AutoRedial, err = strconv.Atoi(value)
+			if err == nil && site.AutoRedial < 0 {
+				err = fmt.Errorf("must be a count or off, got %q", value)
+			}
+		case "char_delay":
+			site.CharDelay, err = time.ParseDuration(value)
+		case "line_delay":
+			site.LineDelay, err = time.ParseDuration(value)
+		case "prompt":
+			site.Prompt, err = regexp.Compile(value)
+		case "prompt_timeout":
+			site.PromptTimeout, err = time.ParseDuration(value)
+		case "paste_wait":
+			site.PasteWait, err = strconv.ParseBool(value)
+		case "sweep":
+			var sweep bool
+			sweep, err = strconv.ParseBool(value)
+			site.SkipSweep, site.ForceSweep = !sweep, sweep
+		case "protected":
+			site.Protected, err = strconv.ParseBool(value)
+		case "priority":
+			site.Priority, err = strconv.Atoi(value)
+			if err == nil && (site.Priority < 0 || site.Priority > MaxPriority) {
+				err = fmt.Errorf("must be 0 to %d, got %d", MaxPriority, site.Priority)
+			}
+		case "login":
+			site.Login, err = parseChat(value)
+		case "ticket":
+			site.Ticket, site.NoTicket = nil, value == "off"
+			if !site.NoTicket {
+				site.Ticket, err = CompileTicketPattern(value)
+			}
+		case "group":
+			site.Group = value
+		case "tags":
+			site.Tags = nil
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					site.Tags = append(site.Tags, tag)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+	}
+	if site.PasteWait && site.Prompt == nil {
+		return fmt.Errorf("option paste_wait: requires prompt")
+	}
+	return nil
+}
+
 // ParseSitesFile reads site definitions from a file path.
 func ParseSitesFile(path string) ([]Site, error) {
 	f, err := openFile(path)
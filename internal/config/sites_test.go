@@ -1,8 +1,11 @@
 package config
 
 import (
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseSites(t *testing.T) {
@@ -90,6 +93,176 @@ multi-cmd|15551111111|Multiple commands|9600|AT+MS=132,0,4800,9600;ATS7=60
 	}
 }
 
+func TestParseSitesWithOptions(t *testing.T) {
+	input := `core|15551234567|Core router|9600||idle_timeout=15m;max_duration=2h;mode=raw
+edge|15559876543|Edge switch|9600|ATS7=60|idle_timeout=5m
+`
+	sites, err := ParseSites(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sites[0].ModemInit) != 0 {
+		t.Errorf("site 0: expected no modem init, got %v", sites[0].ModemInit)
+	}
+	if sites[0].IdleTimeout != 15*time.Minute {
+		t.Errorf("site 0: idle timeout = %s, want 15m", sites[0].IdleTimeout)
+	}
+	if sites[0].MaxDuration != 2*time.Hour {
+		t.Errorf("site 0: max duration = %s, want 2h", sites[0].MaxDuration)
+	}
+	if !sites[0].RawMode {
+		t.Error("site 0: expected raw mode")
+	}
+	if sites[1].RawMode {
+		t.Error("site 1: expected line mode by default")
+	}
+	if len(sites[1].ModemInit) != 1 || sites[1].ModemInit[0] != "ATS7=60" {
+		t.Errorf("site 1: modem init = %v", sites[1].ModemInit)
+	}
+	if sites[1].IdleTimeout != 5*time.Minute {
+		t.Errorf("site 1: idle timeout = %s, want 5m", sites[1].IdleTimeout)
+	}
+	if sites[1].MaxDuration != 0 {
+		t.Errorf("site 1: max duration = %s, want unset", sites[1].MaxDuration)
+	}
+}
+
+func TestParseSitesPasteOptions(t *testing.T) {
+	input := `console|15551234567|Slow console|9600||char_delay=5ms;line_delay=200ms;prompt=[>#] ?$;paste_wait=true
+`
+	sites, err := ParseSites(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := sites[0]
+	if s.CharDelay != 5*time.Millisecond || s.LineDelay != 200*time.Millisecond {
+		t.Errorf("delays = %s/%s, want 5ms/200ms", s.CharDelay, s.LineDelay)
+	}
+	if s.Prompt == nil || !s.Prompt.MatchString("router# ") {
+		t.Errorf("prompt = %v, want match for %q", s.Prompt, "router# ")
+	}
+	if !s.PasteWait {
+		t.Error("expected paste_wait")
+	}
+	if s.PromptWait() != DefaultPromptTimeout {
+		t.Errorf("prompt wait = %s, want default", s.PromptWait())
+	}
+}
+
+func TestParseSitesGroupAndTags(t *testing.T) {
+	input := `nyc-sw1|12125551111|NYC switch|9600||group=New York;tags=nyc, cisco,,switch;sweep=false
+`
+	sites, err := ParseSites(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := sites[0]
+	if s.Group != "New York" {
+		t.Errorf("group = %q, want %q", s.Group, "New York")
+	}
+	if want := []string{"nyc", "cisco", "switch"}; !slices.Equal(s.Tags, want) {
+		t.Errorf("tags = %q, want %q", s.Tags, want)
+	}
+	if !s.HasTag("NYC") || s.HasTag("juniper") {
+		t.Errorf("HasTag mismatch for %q", s.Tags)
+	}
+	if !s.SkipSweep || s.ForceSweep {
+		t.Error("expected sweep=false to skip sweeps")
+	}
+}
+
+func TestParseSitesLogin(t *testing.T) {
+	input := `core|15551234567|Core router|9600||prompt=[>#] ?$;login="" "" [Uu]sername: audit [Pp]assword: $CORE_PW
+`
+	sites, err := ParseSites(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	login := sites[0].Login
+	if len(login) != 3 {
+		t.Fatalf("login = %+v, want 3 steps", login)
+	}
+	if login[0].Expect != nil || login[0].SendText() != "\r" {
+		t.Errorf("step 1 = %+v, want a bare Enter", login[0])
+	}
+	if !login[1].Expect.MatchString("Username:") || login[1].Send != "audit" {
+		t.Errorf("step 2 = %+v", login[1])
+	}
+	t.Setenv("CORE_PW", "s3cret")
+	if got := login[2].SendText(); got != "s3cret\r" {
+		t.Errorf("password step sends %q, want the expanded variable", got)
+	}
+}
+
+func TestSiteTicketRule(t *testing.T) {
+	input := `a|1|d|9600
+b|2|d|9600||ticket=^INC[0-9]+$
+c|3|d|9600||ticket=off;protected=true;priority=5
+`
+	sites, err := ParseSites(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hub := regexp.MustCompile(`^CHG[0-9]+$`)
+	if rule := sites[0].TicketRule(hub); rule != hub {
+		t.Errorf("a: rule = %v, want the hub pattern", rule)
+	}
+	if rule := sites[1].TicketRule(hub); rule == nil || !rule.MatchString("INC42") {
+		t.Errorf("b: rule = %v, want the site pattern", rule)
+	}
+	if rule := sites[2].TicketRule(hub); rule != nil {
+		t.Errorf("c: rule = %v, want none", rule)
+	}
+	if rule := sites[0].TicketRule(nil); rule != nil {
+		t.Errorf("a without hub pattern: rule = %v, want none", rule)
+	}
+	if sites[1].Protected || !sites[2].Protected {
+		t.Errorf("protected = %v, %v; want only c", sites[1].Protected, sites[2].Protected)
+	}
+	if sites[1].Priority != 0 || sites[2].Priority != 5 {
+		t.Errorf("priority = %d, %d; want 0, 5", sites[1].Priority, sites[2].Priority)
+	}
+}
+
+func TestCompileTicketPattern(t *testing.T) {
+	rule, err := CompileTicketPattern(`CHG[0-9]{4}|INC[0-9]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{
+		"CHG1234":           true,
+		"INC42":             true,
+		"see CHG1234":       false,
+		"CHG1234 and more":  false,
+		"INC42 or CHG1234x": false,
+	} {
+		if got := rule.MatchString(id); got != want {
+			t.Errorf("%q matches = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestParseSitesInvalidOptions(t *testing.T) {
+	for _, line := range []string{
+		"a|1|d|9600||idle_timeout",
+		"a|1|d|9600||idle_timeout=soon",
+		"a|1|d|9600||colour=blue",
+		"a|1|d|9600||mode=binary",
+		"a|1|d|9600||prompt=[",
+		"a|1|d|9600||paste_wait=true",
+		"a|1|d|9600||login=Username: audit Password:",
+		"a|1|d|9600||login=( x",
+		"a|1|d|9600||ticket=[",
+		"a|1|d|9600||protected=maybe",
+		"a|1|d|9600||priority=10",
+		"a|1|d|9600||priority=high",
+	} {
+		if _, err := ParseSites(strings.NewReader(line)); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
+
 func TestParseSitesFile(t *testing.T) {
 	sites, err := ParseSitesFile("../../tests/fixtures/oob-sites.conf")
 	if err != nil {
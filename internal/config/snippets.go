@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Snippet is a named block of text that can be inserted into a session.
+type Snippet struct {
+	Site string // site name, or "*" for every site
+	Name string
+	Text string
+}
+
+// ParseSnippets reads snippet definitions from r.
+// Each non-blank, non-comment line must be:
+//
+//	site|name|text
+//	*|nopage|terminal length 0\n
+//
+// A site of * applies to every site. In text, \n is a newline and \\ a
+// backslash.
+func ParseSnippets(r io.Reader) ([]Snippet, error) {
+	var snippets []Snippet
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "|", 3)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("line %d: expected 3 pipe-delimited fields, got %d", lineNum, len(parts))
+		}
+		s := Snippet{
+			Site: strings.TrimSpace(parts[0]),
+			Name: strings.TrimSpace(parts[1]),
+			Text: UnescapeSnippet(parts[2]),
+		}
+		if s.Site == "" || s.Name == "" {
+			return nil, fmt.Errorf("line %d: site and name are required", lineNum)
+		}
+		snippets = append(snippets, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading snippets: %w", err)
+	}
+	return snippets, nil
+}
+
+// ParseSnippetsFile reads snippet definitions from a file path.
+func ParseSnippetsFile(path string) ([]Snippet, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening snippets file: %w", err)
+	}
+	defer f.Close()
+	return ParseSnippets(f)
+}
+
+// SnippetsFor returns the snippets that apply to site, with site-specific
+// snippets replacing global ones of the same name.
+func SnippetsFor(snippets []Snippet, site string) []Snippet {
+	var out []Snippet
+	index := make(map[string]int)
+	for _, s := range snippets {
+		if s.Site != "*" && s.Site != site {
+			continue
+		}
+		if i, ok := index[s.Name]; ok {
+			if s.Site != "*" {
+				out[i] = s
+			}
+			continue
+		}
+		index[s.Name] = len(out)
+		out = append(out, s)
+	}
+	return out
+}
+
+// UnescapeSnippet turns \n into a newline and \\ into a backslash.
+func UnescapeSnippet(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+var snippetVar = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// ExpandSnippet substitutes {{site.Name}}, {{site.Phone}},
+// {{site.Description}}, {{user}}, {{date}}, {{time}} and {{datetime}} in
+// text. Unknown variables are left as they are.
+func ExpandSnippet(text string, site Site, user string, now time.Time) string {
+	vars := map[string]string{
+		"site.Name":        site.Name,
+		"site.Phone":       site.Phone,
+		"site.Description": site.Description,
+		"user":             user,
+		"date":             now.Format(time.DateOnly),
+		"time":             now.Format(time.TimeOnly),
+		"datetime":         now.Format(time.DateTime),
+	}
+	return snippetVar.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := vars[snippetVar.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
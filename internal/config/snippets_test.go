@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSnippets(t *testing.T) {
+	input := `# site|name|text
+*|nopage|terminal length 0\n
+*|ver|show version\n
+router1|ver|show version | include uptime\n
+router1|banner|hello\\n{{site.Name}}
+`
+	snippets, err := ParseSnippets(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(snippets) != 4 {
+		t.Fatalf("expected 4 snippets, got %d", len(snippets))
+	}
+	if got := snippets[0].Text; got != "terminal length 0\n" {
+		t.Errorf("nopage text = %q", got)
+	}
+	if got := snippets[3].Text; got != `hello\n{{site.Name}}` {
+		t.Errorf("banner text = %q", got)
+	}
+
+	router := SnippetsFor(snippets, "router1")
+	if len(router) != 3 {
+		t.Fatalf("router1: expected 3 snippets, got %d", len(router))
+	}
+	if router[1].Name != "ver" || router[1].Text != "show version | include uptime\n" {
+		t.Errorf("router1 ver = %+v, want the site override", router[1])
+	}
+	if other := SnippetsFor(snippets, "switch1"); len(other) != 2 {
+		t.Errorf("switch1: expected 2 global snippets, got %d", len(other))
+	}
+}
+
+func TestParseSnippetsInvalid(t *testing.T) {
+	for _, line := range []string{"*|nopage", "|name|text", "*||text"} {
+		if _, err := ParseSnippets(strings.NewReader(line)); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
+
+func TestExpandSnippet(t *testing.T) {
+	site := Site{Name: "router1", Phone: "13125559876"}
+	now := time.Date(2024, 3, 9, 14, 5, 0, 0, time.UTC)
+	got := ExpandSnippet("! {{site.Name}} ({{ site.Phone }}) by {{user}} on {{date}} {{time}} {{nope}}", site, "alice", now)
+	want := "! router1 (13125559876) by alice on 2024-03-09 14:05:00 {{nope}}"
+	if got != want {
+		t.Errorf("ExpandSnippet = %q, want %q", got, want)
+	}
+}
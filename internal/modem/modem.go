@@ -5,9 +5,13 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
+
+	"golang.org/x/sys/unix"
 )
 
 // DialResult represents the outcome of a dial attempt.
@@ -45,6 +49,7 @@ func (r DialResult) String() string {
 type DialResponse struct {
 	Result     DialResult
 	Transcript string // raw AT command/response exchange
+	Rate       int    // bps from "CONNECT 14400", 0 if not reported
 }
 
 // Modem represents an open modem device.
@@ -52,6 +57,20 @@ type Modem struct {
 	dev  *os.File
 	path string
 	log  strings.Builder // accumulates the full AT transcript
+	rate int             // connect rate of the last successful dial
+}
+
+var connectRate = regexp.MustCompile(`CONNECT\s+(\d+)`)
+
+// parseConnectRate extracts the bps from a CONNECT result such as
+// "CONNECT 14400/ARQ", or 0 if none is reported.
+func parseConnectRate(resp string) int {
+	m := connectRate.FindStringSubmatch(resp)
+	if m == nil {
+		return 0
+	}
+	rate, _ := strconv.Atoi(m[1])
+	return rate
 }
 
 // Open opens a modem device at the given path.
@@ -171,8 +190,40 @@ func (m *Modem) Dial(phone string, timeout time.Duration) (DialResponse, error)
 		result = ResultError
 	}
 
-	slog.Info("modem dial result", "device", m.path, "result", result.String(), "transcript", transcript)
-	return DialResponse{Result: result, Transcript: transcript}, nil
+	var rate int
+	if result == ResultConnect {
+		rate = parseConnectRate(resp)
+		m.rate = rate
+	}
+
+	slog.Info("modem dial result", "device", m.path, "result", result.String(), "rate", rate, "transcript", transcript)
+	return DialResponse{Result: result, Transcript: transcript, Rate: rate}, nil
+}
+
+// ConnectRate returns the bps reported by the last CONNECT, or 0.
+func (m *Modem) ConnectRate() int {
+	return m.rate
+}
+
+// Carrier reports the state of the Data Carrier Detect line. Virtual modems
+// on a PTY may not support the query, in which case an error is returned.
+func (m *Modem) Carrier() (bool, error) {
+	conn, err := m.dev.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var status int
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		status, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCMGET)
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading modem status: %w", err)
+	}
+	return status&unix.TIOCM_CD != 0, nil
 }
 
 // Transcript returns the accumulated AT command log.
@@ -195,6 +246,31 @@ func (m *Modem) Hangup() error {
 	return nil
 }
 
+// SendBreak asserts a serial break on the line for 250ms, which consoles
+// use to drop into ROMMON or a boot monitor.
+func (m *Modem) SendBreak() error {
+	conn, err := m.dev.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("sending break: %w", err)
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		if ioctlErr = unix.IoctlSetInt(int(fd), unix.TIOCSBRK, 0); ioctlErr != nil {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+		ioctlErr = unix.IoctlSetInt(int(fd), unix.TIOCCBRK, 0)
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		return fmt.Errorf("sending break: %w", err)
+	}
+	slog.Debug("modem break sent", "device", m.path)
+	return nil
+}
+
 // ReadWriteCloser returns the underlying device for raw I/O pass-through.
 func (m *Modem) ReadWriteCloser() io.ReadWriteCloser {
 	return m.dev
@@ -289,6 +365,32 @@ func (m *Modem) readUntil(timeout time.Duration, matches ...string) (string, err
 	return accumulated.String(), fmt.Errorf("timeout after %s", timeout)
 }
 
+// Expect reads output from the remote end until it matches re or timeout
+// passes, returning what was read. Use it only while nothing else is
+// reading from the modem.
+func (m *Modem) Expect(re *regexp.Regexp, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	defer m.dev.SetReadDeadline(time.Time{})
+	var out []byte
+	buf := make([]byte, 1024)
+	for {
+		if err := m.dev.SetReadDeadline(deadline); err != nil {
+			return string(out), err
+		}
+		n, err := m.dev.Read(buf)
+		out = append(out, buf[:n]...)
+		if re.Match(out) {
+			return string(out), nil
+		}
+		if os.IsTimeout(err) {
+			return string(out), fmt.Errorf("no match for %q after %s", re, timeout)
+		}
+		if err != nil {
+			return string(out), err
+		}
+	}
+}
+
 // cleanResponse strips control chars and excess whitespace from modem output.
 func cleanResponse(s string) string {
 	s = strings.Map(func(r rune) rune {
@@ -2,6 +2,7 @@ package modem
 
 import (
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -28,6 +29,22 @@ func TestDialResultString(t *testing.T) {
 	}
 }
 
+func TestParseConnectRate(t *testing.T) {
+	tests := []struct {
+		resp string
+		want int
+	}{
+		{"\r\nCONNECT 14400\r\n", 14400},
+		{"\r\nCONNECT 9600/ARQ/V32\r\n", 9600},
+		{"\r\nCONNECT\r\n", 0},
+	}
+	for _, tt := range tests {
+		if got := parseConnectRate(tt.resp); got != tt.want {
+			t.Errorf("parseConnectRate(%q) = %d, want %d", tt.resp, got, tt.want)
+		}
+	}
+}
+
 func TestConfigureSendsCommands(t *testing.T) {
 	// Create a PTY pair to simulate a modem device
 	ptmx, pts, err := pty.Open()
@@ -123,3 +140,27 @@ func TestConfigureEmptyCommands(t *testing.T) {
 		t.Fatalf("Configure with empty: %v", err)
 	}
 }
+
+func TestExpect(t *testing.T) {
+	ptmx, pts, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptmx.Close()
+	defer pts.Close()
+	m := &Modem{dev: pts, path: pts.Name()}
+
+	// The PTY is in canonical mode, so output must be newline-terminated.
+	ptmx.Write([]byte("Username: admin\nrouter1#\n"))
+	out, err := m.Expect(regexp.MustCompile(`(?m)^\S+[>#]$`), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Expect: %v", err)
+	}
+	if !strings.Contains(out, "router1#") {
+		t.Errorf("output = %q", out)
+	}
+
+	if _, err := m.Expect(regexp.MustCompile(`never`), 100*time.Millisecond); err == nil {
+		t.Error("expected timeout")
+	}
+}
@@ -1,64 +1,270 @@
 package modem
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 )
 
-// DeviceLock manages a single modem device (e.g. /dev/ttySL0).
-// Only one session can hold the device at a time.
+// DeviceLock manages a pool of modem devices (e.g. /dev/ttyIAX0-7, or a
+// single /dev/ttySL0). Each device is held by at most one site at a time.
+// Dials that find every device busy can queue for the next one to be
+// released; see Wait.
 type DeviceLock struct {
-	mu         sync.Mutex
-	devicePath string
-	activeSite string // "" = idle
+	mu      sync.Mutex
+	devices []string
+	active  map[string]string // device → site; absent = idle
+	queue   []*Waiter         // highest priority first, then first come
 }
 
-// NewDeviceLock creates a lock for the given modem device path.
-func NewDeviceLock(devicePath string) *DeviceLock {
-	return &DeviceLock{devicePath: devicePath}
+// Waiter is a place in the queue for a modem device.
+type Waiter struct {
+	d        *DeviceLock
+	site     string
+	priority int
+	ready    chan struct{} // closed once a device is claimed or the wait is cancelled
+	stop     func() bool   // stops Wait's context from cancelling the waiter
+
+	// Guarded by d.mu.
+	device    string // claimed for the waiter, until taken
+	taken     bool
+	cancelled bool
+}
+
+// NewDeviceLock creates a lock for the given modem device paths, tried in
+// order by Acquire.
+func NewDeviceLock(devicePaths ...string) *DeviceLock {
+	return &DeviceLock{devices: devicePaths, active: make(map[string]string)}
 }
 
-// Acquire claims the modem device for the given site.
-// Returns the device path if available, or an error if busy or missing.
+// ParseDevicePaths splits a comma-separated DEVICE_PATH into device paths.
+func ParseDevicePaths(s string) []string {
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Acquire claims the first idle modem device for the given site.
+// Returns the device path if one is available, or an error if all are busy
+// or missing. It never jumps the wait queue: while anyone is waiting, every
+// device counts as busy.
 func (d *DeviceLock) Acquire(siteName string) (string, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if d.activeSite != "" {
-		return "", fmt.Errorf("modem busy: connected to %s", d.activeSite)
+	if n := len(d.queue); n > 0 {
+		return "", fmt.Errorf("modem busy: %d waiting for a line", n)
+	}
+	dev, busy, err := d.claim(siteName)
+	if dev != "" {
+		return dev, nil
 	}
+	if len(busy) > 0 && err == nil {
+		err = fmt.Errorf("modem busy: connected to %s", strings.Join(busy, ", "))
+	}
+	return "", err
+}
 
-	if _, err := os.Stat(d.devicePath); err != nil {
-		return "", fmt.Errorf("modem device %s not found: %w", d.devicePath, err)
+// claim marks the first idle device as held by siteName. If none is idle
+// it returns the sites holding devices and why any others can't be used.
+// Callers hold d.mu.
+func (d *DeviceLock) claim(siteName string) (dev string, busy []string, err error) {
+	for _, dev := range d.devices {
+		if site, ok := d.active[dev]; ok {
+			busy = append(busy, site)
+			continue
+		}
+		if _, statErr := os.Stat(dev); statErr != nil {
+			err = fmt.Errorf("modem device %s not found: %w", dev, statErr)
+			continue
+		}
+		d.active[dev] = siteName
+		return dev, nil, nil
+	}
+	if len(busy) == 0 && err == nil {
+		err = fmt.Errorf("no modem devices configured")
 	}
+	return "", busy, err
+}
 
-	d.activeSite = siteName
-	return d.devicePath, nil
+// Wait claims an idle device for siteName like Acquire or, if every device
+// is busy, joins the queue behind everyone waiting at the same or a higher
+// priority. Released devices go to the queue in order. Either way the
+// waiter's Ready channel is closed once it has a device to Take. When ctx
+// ends before then, typically because the user's SSH session dropped, the
+// wait is cancelled as if by Cancel. An error means no device can be had,
+// e.g. none exist.
+func (d *DeviceLock) Wait(ctx context.Context, siteName string, priority int) (*Waiter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w := &Waiter{d: d, site: siteName, priority: priority, ready: make(chan struct{})}
+	if len(d.queue) == 0 {
+		dev, busy, err := d.claim(siteName)
+		if dev == "" && len(busy) == 0 {
+			return nil, err
+		}
+		w.device = dev
+	}
+	if w.device != "" {
+		close(w.ready)
+	} else {
+		i := len(d.queue)
+		for i > 0 && d.queue[i-1].priority < priority {
+			i--
+		}
+		d.queue = slices.Insert(d.queue, i, w)
+	}
+	// Cancel runs on its own goroutine, so it can take d.mu.
+	w.stop = context.AfterFunc(ctx, w.Cancel)
+	return w, nil
+}
+
+// Release marks the modem device as idle, or hands it to the first waiter
+// in the queue.
+func (d *DeviceLock) Release(device string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.queue) == 0 {
+		delete(d.active, device)
+		return
+	}
+	w := d.queue[0]
+	d.queue = d.queue[1:]
+	d.active[device] = w.site
+	w.device = device
+	close(w.ready)
 }
 
-// Release marks the modem device as idle.
-func (d *DeviceLock) Release() {
+// Waiting returns the sites waiting for a device, first in line first.
+func (d *DeviceLock) Waiting() []string {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.activeSite = ""
+	sites := make([]string, len(d.queue))
+	for i, w := range d.queue {
+		sites[i] = w.site
+	}
+	return sites
 }
 
-// ActiveSite returns the name of the currently connected site, or "" if idle.
+// Ready is closed once a device has been claimed for the waiter, or the
+// wait is cancelled.
+func (w *Waiter) Ready() <-chan struct{} { return w.ready }
+
+// Position returns the waiter's place in the queue, 1 being next, or 0
+// once it has a device or has been cancelled.
+func (w *Waiter) Position() int {
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	for i, other := range w.d.queue {
+		if other == w {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Take returns the device claimed for the waiter, which the caller must
+// Release when done. It reports false if the wait was cancelled or no
+// device has been claimed yet.
+func (w *Waiter) Take() (string, bool) {
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	if w.cancelled || w.device == "" || w.taken {
+		return "", false
+	}
+	w.taken = true
+	w.stop()
+	return w.device, true
+}
+
+// Cancel leaves the queue. A device claimed for the waiter but not yet
+// taken is released to the next in line. It does nothing after Take.
+func (w *Waiter) Cancel() {
+	w.d.mu.Lock()
+	if w.taken || w.cancelled {
+		w.d.mu.Unlock()
+		return
+	}
+	w.cancelled = true
+	w.stop()
+	for i, other := range w.d.queue {
+		if other == w {
+			w.d.queue = append(w.d.queue[:i], w.d.queue[i+1:]...)
+			close(w.ready)
+			break
+		}
+	}
+	device := w.device
+	w.d.mu.Unlock()
+
+	if device != "" {
+		w.d.Release(device)
+	}
+}
+
+// ActiveSite returns the name of the site connected on the first busy
+// device, or "" if all are idle.
 func (d *DeviceLock) ActiveSite() string {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	return d.activeSite
+	for _, dev := range d.devices {
+		if site, ok := d.active[dev]; ok {
+			return site
+		}
+	}
+	return ""
+}
+
+// ActiveSites returns the names of all sites holding a device, in device
+// order.
+func (d *DeviceLock) ActiveSites() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var sites []string
+	for _, dev := range d.devices {
+		if site, ok := d.active[dev]; ok {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// Holders returns which site holds each busy device.
+func (d *DeviceLock) Holders() map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	holders := make(map[string]string, len(d.active))
+	for dev, site := range d.active {
+		holders[dev] = site
+	}
+	return holders
 }
 
-// IsAvailable returns true if the modem device is not in use.
+// IsAvailable returns true if any modem device is not in use and nobody
+// is waiting for one.
 func (d *DeviceLock) IsAvailable() bool {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	return d.activeSite == ""
+	return len(d.active) < len(d.devices) && len(d.queue) == 0
 }
 
-// DevicePath returns the configured device path.
+// DevicePath returns the first configured device path.
 func (d *DeviceLock) DevicePath() string {
-	return d.devicePath
+	if len(d.devices) == 0 {
+		return ""
+	}
+	return d.devices[0]
+}
+
+// Devices returns all configured device paths.
+func (d *DeviceLock) Devices() []string {
+	return d.devices
 }
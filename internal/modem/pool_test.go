@@ -1,9 +1,12 @@
 package modem
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func testDeviceLock(t *testing.T) (*DeviceLock, string) {
@@ -36,7 +39,7 @@ func TestDeviceLockAcquireRelease(t *testing.T) {
 	}
 
 	// Release and re-acquire
-	dl.Release()
+	dl.Release(dev)
 	dev, err = dl.Acquire("site-b")
 	if err != nil {
 		t.Fatalf("Acquire after release: %v", err)
@@ -62,12 +65,12 @@ func TestDeviceLockActiveSite(t *testing.T) {
 		t.Errorf("expected empty active site, got %q", site)
 	}
 
-	dl.Acquire("site-a")
+	dev, _ := dl.Acquire("site-a")
 	if site := dl.ActiveSite(); site != "site-a" {
 		t.Errorf("expected %q, got %q", "site-a", site)
 	}
 
-	dl.Release()
+	dl.Release(dev)
 	if site := dl.ActiveSite(); site != "" {
 		t.Errorf("expected empty after release, got %q", site)
 	}
@@ -80,12 +83,12 @@ func TestDeviceLockIsAvailable(t *testing.T) {
 		t.Error("expected available when idle")
 	}
 
-	dl.Acquire("site-a")
+	dev, _ := dl.Acquire("site-a")
 	if dl.IsAvailable() {
 		t.Error("expected not available when busy")
 	}
 
-	dl.Release()
+	dl.Release(dev)
 	if !dl.IsAvailable() {
 		t.Error("expected available after release")
 	}
@@ -97,3 +100,191 @@ func TestDeviceLockDevicePath(t *testing.T) {
 		t.Errorf("expected /dev/ttySL0, got %q", dl.DevicePath())
 	}
 }
+
+func TestDeviceLockPool(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"ttyIAX0", "ttyIAX1"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	dl := NewDeviceLock(paths...)
+
+	a, err := dl.Acquire("site-a")
+	if err != nil || a != paths[0] {
+		t.Fatalf("Acquire site-a = %q, %v; want %q", a, err, paths[0])
+	}
+	b, err := dl.Acquire("site-b")
+	if err != nil || b != paths[1] {
+		t.Fatalf("Acquire site-b = %q, %v; want %q", b, err, paths[1])
+	}
+	if dl.IsAvailable() {
+		t.Error("expected pool exhausted")
+	}
+	if _, err := dl.Acquire("site-c"); err == nil || err.Error() != "modem busy: connected to site-a, site-b" {
+		t.Errorf("Acquire on full pool: %v", err)
+	}
+	if got := dl.ActiveSites(); len(got) != 2 || got[1] != "site-b" {
+		t.Errorf("ActiveSites = %v", got)
+	}
+
+	dl.Release(a)
+	if dev, err := dl.Acquire("site-c"); err != nil || dev != paths[0] {
+		t.Errorf("Acquire after release = %q, %v; want %q", dev, err, paths[0])
+	}
+}
+
+func TestDeviceLockWaitQueue(t *testing.T) {
+	dl, devPath := testDeviceLock(t)
+
+	first, err := dl.Wait(context.Background(), "site-a", 0)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if dev, ok := first.Take(); !ok || dev != devPath {
+		t.Fatalf("idle device: Take = %q, %v", dev, ok)
+	}
+
+	second, _ := dl.Wait(context.Background(), "site-b", 0)
+	third, _ := dl.Wait(context.Background(), "site-c", 0)
+	if second.Position() != 1 || third.Position() != 2 {
+		t.Fatalf("positions = %d, %d; want 1, 2", second.Position(), third.Position())
+	}
+	if _, ok := second.Take(); ok {
+		t.Error("Take succeeded before a device was released")
+	}
+	if _, err := dl.Acquire("site-d"); err == nil {
+		t.Error("Acquire jumped the queue")
+	}
+	if got := dl.Waiting(); len(got) != 2 || got[0] != "site-b" {
+		t.Errorf("Waiting = %v", got)
+	}
+
+	// Releasing hands the device straight to the head of the queue.
+	dl.Release(devPath)
+	<-second.Ready()
+	if dev, ok := second.Take(); !ok || dev != devPath {
+		t.Fatalf("after release: Take = %q, %v", dev, ok)
+	}
+	if site := dl.Holders()[devPath]; site != "site-b" {
+		t.Errorf("ActiveSite = %q, want site-b", site)
+	}
+	if third.Position() != 1 {
+		t.Errorf("third position = %d, want 1", third.Position())
+	}
+
+	// Cancelling after Take is a no-op; the holder releases as usual.
+	second.Cancel()
+	if site := dl.Holders()[devPath]; site != "site-b" {
+		t.Errorf("cancel after Take released the device to %q", site)
+	}
+	dl.Release(devPath)
+	<-third.Ready()
+	third.Cancel()
+	if !dl.IsAvailable() || len(dl.Waiting()) != 0 {
+		t.Error("device claimed for a cancelled waiter was not released")
+	}
+}
+
+func TestDeviceLockWaitCancel(t *testing.T) {
+	dl, devPath := testDeviceLock(t)
+	dl.Acquire("site-a")
+
+	gone, _ := dl.Wait(context.Background(), "site-b", 0)
+	next, _ := dl.Wait(context.Background(), "site-c", 0)
+	gone.Cancel()
+	select {
+	case <-gone.Ready():
+	default:
+		t.Error("Ready not closed by Cancel")
+	}
+	if _, ok := gone.Take(); ok {
+		t.Error("Take succeeded after Cancel")
+	}
+	if next.Position() != 1 {
+		t.Errorf("position = %d after the waiter ahead left, want 1", next.Position())
+	}
+
+	dl.Release(devPath)
+	if dev, ok := next.Take(); !ok || dev != devPath {
+		t.Errorf("Take = %q, %v", dev, ok)
+	}
+}
+
+func TestDeviceLockWaitContext(t *testing.T) {
+	dl, devPath := testDeviceLock(t)
+	dl.Acquire("site-a")
+
+	ctxB, dropB := context.WithCancel(context.Background())
+	ctxD, dropD := context.WithCancel(context.Background())
+	defer dropD()
+	gone, _ := dl.Wait(ctxB, "site-b", 0)
+	next, _ := dl.Wait(context.Background(), "site-c", 0)
+	last, _ := dl.Wait(ctxD, "site-d", 0)
+
+	// A queued waiter whose session ends leaves the queue, and the next
+	// one gets the released device.
+	dropB()
+	<-gone.Ready()
+	if next.Position() != 1 {
+		t.Fatalf("position = %d after the session ahead ended, want 1", next.Position())
+	}
+	dl.Release(devPath)
+	if dev, ok := next.Take(); !ok || dev != devPath {
+		t.Fatalf("Take = %q, %v; want the released device", dev, ok)
+	}
+
+	// One whose session ends after the device was handed over but before
+	// it was taken gives the device back to the pool.
+	dl.Release(devPath)
+	<-last.Ready()
+	dropD()
+	deadline := time.Now().Add(time.Second)
+	for !dl.IsAvailable() {
+		if time.Now().After(deadline) {
+			t.Fatalf("device still held by %q after its waiter's session ended", dl.Holders()[devPath])
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := last.Take(); ok {
+		t.Error("Take succeeded after the wait was cancelled")
+	}
+}
+
+func TestDeviceLockWaitPriority(t *testing.T) {
+	dl, devPath := testDeviceLock(t)
+	dl.Acquire("site-a")
+
+	low, _ := dl.Wait(context.Background(), "low", 0)
+	high, _ := dl.Wait(context.Background(), "high", 5)
+	alsoHigh, _ := dl.Wait(context.Background(), "also-high", 5)
+	if got := dl.Waiting(); strings.Join(got, ",") != "high,also-high,low" {
+		t.Errorf("Waiting = %v, want high first, then first come", got)
+	}
+	dl.Release(devPath)
+	if _, ok := high.Take(); !ok {
+		t.Error("the highest-priority waiter did not get the device")
+	}
+	if alsoHigh.Position() != 1 || low.Position() != 2 {
+		t.Errorf("positions = %d, %d; want 1, 2", alsoHigh.Position(), low.Position())
+	}
+}
+
+func TestDeviceLockWaitNoDevices(t *testing.T) {
+	if _, err := NewDeviceLock().Wait(context.Background(), "site-a", 0); err == nil {
+		t.Error("expected an error with no devices configured")
+	}
+	if _, err := NewDeviceLock("/nonexistent/ttyX").Wait(context.Background(), "site-a", 0); err == nil {
+		t.Error("expected an error for a missing device")
+	}
+}
+
+func TestParseDevicePaths(t *testing.T) {
+	got := ParseDevicePaths(" /dev/ttyIAX0, /dev/ttyIAX1,,")
+	if len(got) != 2 || got[0] != "/dev/ttyIAX0" || got[1] != "/dev/ttyIAX1" {
+		t.Errorf("ParseDevicePaths = %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ApprovalTimeout is how long a request for a protected site waits for an
+// approver before it lapses. A variable so tests can shorten it.
+var ApprovalTimeout = 10 * time.Minute
+
+// Errors from a request that was not approved.
+var (
+	ErrApprovalCancelled = errors.New("request cancelled")
+	ErrApprovalExpired   = errors.New("no approver answered in time")
+)
+
+// Approvals holds the pending requests to dial protected sites, which
+// need a second person to approve them. Every request and decision is
+// written to the audit log.
+type Approvals struct {
+	audit *AuditLog
+
+	mu      sync.Mutex
+	seq     int
+	pending []*ApprovalRequest // oldest first
+	changed chan struct{}
+}
+
+// ApprovalRequest is one user's request to dial a protected site.
+type ApprovalRequest struct {
+	ID        string
+	User      string
+	Site      string
+	Ticket    Ticket
+	Requested time.Time
+
+	approvals *Approvals
+	timer     *time.Timer
+	stop      func() bool // stops the request's context from cancelling it
+	done      chan struct{}
+
+	// Set once, when the request is decided.
+	err      error
+	approver string
+}
+
+// NewApprovals creates an empty approval queue recording to audit, which
+// may be nil.
+func NewApprovals(audit *AuditLog) *Approvals {
+	return &Approvals{audit: audit, changed: make(chan struct{})}
+}
+
+// Request files a request by user to dial site. It lapses after
+// ApprovalTimeout unless decided or cancelled first, and is cancelled when
+// ctx ends, e.g. because the requester disconnected.
+func (a *Approvals) Request(ctx context.Context, user, site string, ticket Ticket) *ApprovalRequest {
+	a.mu.Lock()
+	a.seq++
+	r := &ApprovalRequest{
+		ID:        strconv.Itoa(a.seq),
+		User:      user,
+		Site:      site,
+		Ticket:    ticket,
+		Requested: time.Now(),
+		approvals: a,
+		done:      make(chan struct{}),
+	}
+	r.timer = time.AfterFunc(ApprovalTimeout, func() { a.finish(r, "expired", "", ErrApprovalExpired) })
+	r.stop = context.AfterFunc(ctx, r.Cancel)
+	a.pending = append(a.pending, r)
+	a.notify()
+	a.mu.Unlock()
+
+	slog.Info("approval requested", "request", r.ID, "user", user, "site", site)
+	a.record(r, "requested", "")
+	return r
+}
+
+// Pending returns the undecided requests, oldest first.
+func (a *Approvals) Pending() []*ApprovalRequest {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*ApprovalRequest(nil), a.pending...)
+}
+
+// Changed returns a channel that is closed the next time a request is
+// filed or decided.
+func (a *Approvals) Changed() <-chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.changed
+}
+
+// Decide approves or rejects the pending request id on behalf of approver,
+// who may not be the requester.
+func (a *Approvals) Decide(id, approver string, approve bool) error {
+	a.mu.Lock()
+	var r *ApprovalRequest
+	for _, p := range a.pending {
+		if p.ID == id {
+			r = p
+		}
+	}
+	a.mu.Unlock()
+	switch {
+	case r == nil:
+		return fmt.Errorf("request %s is no longer pending", id)
+	case r.User == approver:
+		return fmt.Errorf("you cannot approve your own request")
+	}
+
+	outcome, err := "approved", error(nil)
+	if !approve {
+		outcome, err = "rejected", fmt.Errorf("rejected by %s", approver)
+	}
+	if !a.finish(r, outcome, approver, err) {
+		return fmt.Errorf("request %s is no longer pending", id)
+	}
+	return nil
+}
+
+// finish decides r unless it already was, reporting whether it did.
+func (a *Approvals) finish(r *ApprovalRequest, outcome, approver string, err error) bool {
+	a.mu.Lock()
+	i := -1
+	for j, p := range a.pending {
+		if p == r {
+			i = j
+		}
+	}
+	if i < 0 {
+		a.mu.Unlock()
+		return false
+	}
+	a.pending = append(a.pending[:i], a.pending[i+1:]...)
+	r.err = err
+	r.approver = approver
+	a.notify()
+	a.mu.Unlock()
+
+	r.timer.Stop()
+	r.stop()
+	close(r.done)
+	slog.Info("approval "+outcome, "request", r.ID, "user", r.User, "site", r.Site, "by", approver)
+	a.record(r, outcome, approver)
+	return true
+}
+
+// notify wakes everyone waiting on Changed. Callers hold a.mu.
+func (a *Approvals) notify() {
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+func (a *Approvals) record(r *ApprovalRequest, outcome, by string) {
+	e := AuditEvent{
+		Event:  "approval-" + outcome,
+		User:   r.User,
+		Site:   r.Site,
+		Ticket: r.Ticket.ID,
+		Reason: r.Ticket.Reason,
+		By:     by,
+	}
+	if outcome != "requested" {
+		e.Waited = time.Since(r.Requested).Round(time.Second)
+	}
+	if err := a.audit.Record(e); err != nil {
+		slog.Error("recording audit event", "event", e.Event, "err", err)
+	}
+}
+
+// Done is closed once the request is approved, rejected, cancelled or
+// expired.
+func (r *ApprovalRequest) Done() <-chan struct{} { return r.done }
+
+// Err waits for the request to be decided and returns nil if it was
+// approved, or why it was not.
+func (r *ApprovalRequest) Err() error {
+	<-r.done
+	return r.err
+}
+
+// Approver waits for the request to be decided and returns who approved
+// or rejected it, if anyone.
+func (r *ApprovalRequest) Approver() string {
+	<-r.done
+	return r.approver
+}
+
+// Cancel withdraws the request if it is still pending.
+func (r *ApprovalRequest) Cancel() {
+	r.approvals.finish(r, "cancelled", "", ErrApprovalCancelled)
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApprovals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewApprovals(audit)
+
+	changed := a.Changed()
+	r := a.Request(context.Background(), "alice", "core1", Ticket{ID: "CHG1", Reason: "PSU"})
+	select {
+	case <-changed:
+	default:
+		t.Error("Changed not closed by a new request")
+	}
+	if p := a.Pending(); len(p) != 1 || p[0] != r {
+		t.Fatalf("pending = %v", p)
+	}
+	if err := a.Decide(r.ID, "alice", true); err == nil {
+		t.Error("requester approved their own request")
+	}
+	if err := a.Decide(r.ID, "bob", true); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if r.Err() != nil || r.Approver() != "bob" || len(a.Pending()) != 0 {
+		t.Errorf("after approval: err %v, approver %q, pending %d", r.Err(), r.Approver(), len(a.Pending()))
+	}
+	if err := a.Decide(r.ID, "carol", false); err == nil {
+		t.Error("decided an approved request again")
+	}
+
+	r = a.Request(context.Background(), "alice", "core1", Ticket{})
+	a.Decide(r.ID, "bob", false)
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "rejected by bob") {
+		t.Errorf("rejected: err = %v", err)
+	}
+	r = a.Request(context.Background(), "alice", "core1", Ticket{})
+	r.Cancel()
+	if !errors.Is(r.Err(), ErrApprovalCancelled) {
+		t.Errorf("cancelled: err = %v", r.Err())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e AuditEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("bad audit line %q: %v", line, err)
+		}
+		events = append(events, e.Event+"/"+e.By)
+	}
+	want := "approval-requested/ approval-approved/bob approval-requested/ approval-rejected/bob approval-requested/ approval-cancelled/"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("audit events = %s\nwant %s", got, want)
+	}
+}
+
+func TestApprovalExpires(t *testing.T) {
+	defer func(d time.Duration) { ApprovalTimeout = d }(ApprovalTimeout)
+	ApprovalTimeout = 10 * time.Millisecond
+
+	a := NewApprovals(nil)
+	r := a.Request(context.Background(), "alice", "core1", Ticket{})
+	select {
+	case <-r.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request did not expire")
+	}
+	if !errors.Is(r.Err(), ErrApprovalExpired) || len(a.Pending()) != 0 {
+		t.Errorf("err = %v, pending %d", r.Err(), len(a.Pending()))
+	}
+}
+
+func TestApprovalCancelledWithContext(t *testing.T) {
+	a := NewApprovals(nil)
+	ctx, disconnect := context.WithCancel(context.Background())
+	r := a.Request(ctx, "alice", "core1", Ticket{})
+
+	disconnect()
+	select {
+	case <-r.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request outlived its session")
+	}
+	if !errors.Is(r.Err(), ErrApprovalCancelled) || len(a.Pending()) != 0 {
+		t.Errorf("err = %v, pending %d", r.Err(), len(a.Pending()))
+	}
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEvent is one entry in the audit log.
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"` // e.g. "approval-requested", "approval-approved"
+	User   string    `json:"user"`  // who the event is about, e.g. the requester
+	Site   string    `json:"site,omitempty"`
+	Ticket string    `json:"ticket,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	// By is who acted on the user's behalf or against them, e.g. the
+	// approver.
+	By string `json:"by,omitempty"`
+	// Waited is how long the event took from its start, e.g. from request
+	// to approval.
+	Waited time.Duration `json:"waited,omitempty"`
+}
+
+// AuditLog is an append-only record of access decisions, one JSON event
+// per line. Unlike the dial history it is never compacted. A nil
+// *AuditLog records nothing.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// OpenAuditLog returns the audit log at path, creating its directory.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating audit log dir: %w", err)
+	}
+	return &AuditLog{path: path}, nil
+}
+
+// Record appends an event, stamping it with the current time if unset.
+func (a *AuditLog) Record(e AuditEvent) error {
+	if a == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+)
+
+// wakeInterval is how often RunBatch sends Enter while waiting for the
+// first prompt.
+const wakeInterval = 2 * time.Second
+
+// BatchError reports which stage of a batch run failed.
+type BatchError struct {
+	Stage string // "login", "prompt" or "line N"
+	Err   error
+}
+
+func (e *BatchError) Error() string { return e.Stage + ": " + e.Err.Error() }
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// RunBatch drives a connected call unattended. It attaches out to the
+// call, replaying anything already received, runs the site's login chat
+// script, waits for prompt, then sends each line and waits for prompt
+// again before the next. Each wait gives up after timeout. The caller
+// hangs up.
+func RunBatch(call *Call, user string, site config.Site, prompt *regexp.Regexp, timeout time.Duration, lines []string, out io.Writer) error {
+	if err := call.Attach(user, out, true); err != nil {
+		return err
+	}
+	watch := call.Watch()
+	defer watch.Close()
+	// The device may have sent its banner before the watch started.
+	watch.write(call.RecentOutput(watchBufferBytes))
+
+	for i, step := range site.Login {
+		if step.Expect != nil {
+			if err := watch.Wait(step.Expect, timeout, nil); err != nil {
+				return &BatchError{Stage: "login", Err: fmt.Errorf("step %d: %w", i+1, err)}
+			}
+		}
+		watch.Reset()
+		if _, err := io.WriteString(call, step.SendText()); err != nil {
+			return &BatchError{Stage: "login", Err: err}
+		}
+	}
+
+	// Nudge the device with Enter until it shows a prompt.
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := watch.Wait(prompt, min(wakeInterval, time.Until(deadline)), nil); err == nil {
+			break
+		}
+		if !time.Now().Before(deadline) || call.isEnded() {
+			return &BatchError{Stage: "prompt", Err: fmt.Errorf("no prompt matching %q within %s", prompt, timeout)}
+		}
+		if _, err := io.WriteString(call, "\r"); err != nil {
+			return &BatchError{Stage: "prompt", Err: err}
+		}
+	}
+
+	for i, line := range lines {
+		watch.Reset()
+		if _, err := io.WriteString(call, line+"\r"); err != nil {
+			return &BatchError{Stage: fmt.Sprintf("line %d", i+1), Err: err}
+		}
+		if err := watch.Wait(prompt, timeout, nil); err != nil {
+			return &BatchError{Stage: fmt.Sprintf("line %d", i+1), Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+)
+
+// fakeRouter answers a username/password login and then each command with
+// canned output followed by a prompt. Anything else, including the PTY's
+// echo of its own output, is ignored. It returns after "show version".
+func fakeRouter(ptmx *os.File) {
+	ptmx.Write([]byte("Username:\n"))
+	var pending string
+	buf := make([]byte, 256)
+	for {
+		n, err := ptmx.Read(buf)
+		if err != nil {
+			return
+		}
+		pending += string(buf[:n])
+		for {
+			i := strings.IndexAny(pending, "\r\n")
+			if i < 0 {
+				break
+			}
+			line := pending[:i]
+			pending = pending[i+1:]
+			switch line {
+			case "admin":
+				ptmx.Write([]byte("Password:\n"))
+			case "secret":
+				ptmx.Write([]byte("router#\n"))
+			case "show version":
+				// Stop reading so the test can close ptmx.
+				ptmx.Write([]byte("IOS 15.1\nrouter#\n"))
+				return
+			}
+		}
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	_, call, ptmx, _ := testCallPTY(t)
+	go fakeRouter(ptmx)
+
+	site := config.Site{Name: "site-a", Login: []config.ChatStep{
+		{Expect: regexp.MustCompile("Username:"), Send: "admin"},
+		{Expect: regexp.MustCompile("Password:"), Send: "secret"},
+	}}
+	var out syncBuffer
+	err := RunBatch(call, "alice", site, regexp.MustCompile("router#"), 2*time.Second, []string{"show version"}, &out)
+	if err != nil {
+		t.Fatalf("RunBatch: %v\noutput:\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "IOS 15.1") {
+		t.Errorf("output missing command result:\n%s", out.String())
+	}
+}
+
+func TestRunBatchPromptTimeout(t *testing.T) {
+	_, call, _, _ := testCallPTY(t)
+
+	err := RunBatch(call, "alice", config.Site{Name: "site-a"}, regexp.MustCompile("router#"), 100*time.Millisecond, []string{"show version"}, &syncBuffer{})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Stage != "prompt" {
+		t.Fatalf("err = %v, want a prompt BatchError", err)
+	}
+}
@@ -0,0 +1,688 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+)
+
+// ErrCallEnded is returned when attaching to a call that has already hung up.
+var ErrCallEnded = errors.New("call has ended")
+
+// Call is a live modem connection owned by the hub rather than by the SSH
+// session that dialed it. The modem reader runs for the lifetime of the
+// call, teeing output to the session log and scrollback buffer and
+// forwarding it to whichever terminal is currently attached. This lets a
+// call survive its SSH connection dropping and be reattached later.
+type Call struct {
+	ID      string
+	Site    string
+	Owner   string
+	Ticket  Ticket // the change ticket given when dialing, if any
+	Started time.Time
+	// Priority is what the call was dialed at, which ranks it when a
+	// queued dial looks for a call to preempt.
+	Priority int
+
+	mgr        *Manager
+	site       config.Site
+	modem      *modem.Modem
+	lock       *modem.DeviceLock
+	logger     *Logger
+	scrollback *Scrollback
+
+	mu            sync.Mutex
+	device        string    // changes if auto-redial moves to another device
+	autoRedial    int       // redial attempts after carrier loss; 0 disables
+	attempts      int       // times the site was dialed for this call, for the dial history
+	out           io.Writer // attached terminal, nil while detached
+	attachedBy    string
+	replaying     bool   // Attach is replaying scrollback to attachedBy
+	pending       []byte // output that arrived while replaying, up to the scrollback size
+	detachedUntil time.Time
+	graceTimer    *time.Timer
+	watches       []*Watch
+	redialing     bool          // modem is being replaced; input is dropped
+	readDone      chan struct{} // closed when the current readLoop exits
+	ended         bool
+	endReason     string
+	preemptedBy   string    // who asked for the line, while the holder is warned
+	preemptAt     time.Time // when the preempted call is hung up
+
+	gotData     atomic.Bool
+	carrierLost atomic.Bool
+	lastInput   atomic.Int64 // unix nanos of the last user write
+	bytesIn     atomic.Int64 // modem → user
+	bytesOut    atomic.Int64 // user → modem
+	done        chan struct{}
+}
+
+// Attach connects a terminal to the call. When replay is set the scrollback
+// buffer is written to w first, so the user sees what happened while the
+// call was detached. Only one terminal may be attached at a time.
+func (c *Call) Attach(username string, w io.Writer, replay bool) error {
+	c.mu.Lock()
+	if c.ended {
+		c.mu.Unlock()
+		return ErrCallEnded
+	}
+	if c.out != nil || c.replaying {
+		c.mu.Unlock()
+		return fmt.Errorf("call to %s is already attached by %s", c.Site, c.attachedBy)
+	}
+	if !replay {
+		c.attachLocked(username, w)
+		c.mu.Unlock()
+		return nil
+	}
+	c.replaying = true
+	c.attachedBy = username
+	backlog := c.scrollback.Bytes()
+	c.mu.Unlock()
+
+	// Replay outside the lock, so a slow SSH client cannot stall the modem
+	// reader or Hangup, then catch up on what deliver queued meanwhile.
+	for {
+		if _, err := w.Write(backlog); err != nil {
+			c.mu.Lock()
+			c.replaying, c.pending, c.attachedBy = false, nil, ""
+			c.mu.Unlock()
+			return fmt.Errorf("replaying scrollback: %w", err)
+		}
+		c.mu.Lock()
+		backlog, c.pending = c.pending, nil
+		if len(backlog) == 0 {
+			c.replaying = false
+			if c.ended {
+				c.attachedBy = ""
+				c.mu.Unlock()
+				return ErrCallEnded
+			}
+			c.attachLocked(username, w)
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+	}
+}
+
+// attachLocked makes w the call's terminal, ending any detach grace
+// period. Callers hold c.mu.
+func (c *Call) attachLocked(username string, w io.Writer) {
+	if c.graceTimer != nil {
+		c.graceTimer.Stop()
+		c.graceTimer = nil
+	}
+	if !c.detachedUntil.IsZero() {
+		c.logger.Mark("Reattached by " + username)
+		c.detachedUntil = time.Time{}
+	}
+	c.out = w
+	c.attachedBy = username
+}
+
+// Detach disconnects the attached terminal but keeps the call up for grace,
+// after which it is hung up unless someone reattaches. Detaching again
+// restarts the grace period.
+func (c *Call) Detach(grace time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ended {
+		return
+	}
+	if c.graceTimer != nil {
+		c.graceTimer.Stop()
+	}
+	c.out = nil
+	c.attachedBy = ""
+	c.detachedUntil = time.Now().Add(grace)
+	c.logger.Mark(fmt.Sprintf("Detached, holding call for %s", grace))
+	c.graceTimer = time.AfterFunc(grace, func() {
+		c.Hangup("detach grace period expired")
+	})
+	slog.Info("call detached", "call", c.ID, "site", c.Site, "grace", grace)
+}
+
+// Write sends user input to the modem and resets the idle clock. Input
+// typed while the call is redialing is discarded.
+func (c *Call) Write(p []byte) (int, error) {
+	c.lastInput.Store(time.Now().UnixNano())
+
+	c.mu.Lock()
+	mdm, redialing := c.modem, c.redialing
+	c.mu.Unlock()
+	if redialing {
+		return len(p), nil
+	}
+
+	n, err := mdm.ReadWriteCloser().Write(p)
+	c.bytesOut.Add(int64(n))
+	return n, err
+}
+
+// SendBreak sends a serial break to the remote device.
+func (c *Call) SendBreak() error {
+	c.mu.Lock()
+	mdm := c.modem
+	c.mu.Unlock()
+	return mdm.SendBreak()
+}
+
+// Mark writes a timestamped marker into the session log.
+func (c *Call) Mark(event string) {
+	c.logger.Mark(event)
+}
+
+// BytesIn returns how many bytes the remote end has sent.
+func (c *Call) BytesIn() int64 { return c.bytesIn.Load() }
+
+// BytesOut returns how many bytes the user has sent.
+func (c *Call) BytesOut() int64 { return c.bytesOut.Load() }
+
+// Redial hangs up the current connection and dials the site again on the
+// same device. The call keeps its ID, session log and attached terminal;
+// a reconnect marker is written to the log. If the dial fails the call is
+// ended. progress, if non-nil, receives dial progress messages.
+//
+// While redialing, the call's modem and device belong to the redial rather
+// than to Hangup, so a hangup in the meantime leaves the redial to release
+// the device once the dial resolves.
+func (c *Call) Redial(reason string, progress func(string)) error {
+	c.mu.Lock()
+	if c.ended || c.redialing {
+		c.mu.Unlock()
+		return fmt.Errorf("call to %s cannot be redialed", c.Site)
+	}
+	c.redialing = true
+	old, readDone, device := c.modem, c.readDone, c.device
+	c.mu.Unlock()
+
+	c.logger.Mark("Redialing: " + reason)
+	slog.Info("redialing call", "call", c.ID, "site", c.Site, "reason", reason)
+	if !c.carrierLost.Load() {
+		old.Hangup()
+	}
+	old.Close()
+	<-readDone
+	if c.isEnded() {
+		c.lock.Release(device)
+		return ErrCallEnded
+	}
+
+	mdm, resp, err := DialSite(c.site, device, progress)
+	c.mu.Lock()
+	c.attempts += resp.Attempts
+	c.mu.Unlock()
+	if err == nil && resp.Result != modem.ResultConnect {
+		err = fmt.Errorf("redial failed: %s", resp.Result)
+	}
+	if err != nil {
+		c.redialFailed(device, err.Error())
+		return err
+	}
+
+	if err := c.reconnected(mdm, device, "Reconnected"); err != nil {
+		c.lock.Release(device)
+		return err
+	}
+	return nil
+}
+
+// redialAfterCarrierLoss is started by readLoop, with redialing already
+// set, when the carrier drops on a call with auto-redial enabled. It runs
+// the dial sequence up to autoRedial times, first on the call's own device
+// and then on any other free device in the pool, reporting progress to the
+// attached terminal. If every attempt fails the call is ended. As with
+// Redial, the call's device stays held until the redial resolves, even if
+// the call is hung up meanwhile.
+func (c *Call) redialAfterCarrierLoss() {
+	c.mu.Lock()
+	old, readDone, device, attempts := c.modem, c.readDone, c.device, c.autoRedial
+	c.mu.Unlock()
+
+	c.logger.Mark("Carrier lost, auto-redialing")
+	slog.Info("carrier lost, auto-redialing", "call", c.ID, "site", c.Site, "attempts", attempts)
+	old.Close()
+	<-readDone
+
+	progress := func(step string) { c.notify(step) }
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(AutoRedialDelay)
+		}
+		if c.isEnded() {
+			break
+		}
+
+		dev := device
+		if attempt > 1 {
+			// Try another device in case this line is the problem.
+			if other, err := c.lock.Acquire(c.Site); err == nil {
+				dev = other
+			}
+		}
+		c.notify(fmt.Sprintf("Carrier lost — redialing %s on %s (attempt %d of %d)", c.Site, dev, attempt, attempts))
+
+		mdm, resp, err := DialSite(c.site, dev, progress)
+		c.mu.Lock()
+		c.attempts += resp.Attempts
+		c.mu.Unlock()
+		if err == nil && resp.Result == modem.ResultConnect {
+			if err := c.reconnected(mdm, dev, fmt.Sprintf("Reconnected by auto-redial on %s (attempt %d of %d)", dev, attempt, attempts)); err != nil {
+				// Hung up mid-dial: nobody else will release either line.
+				c.lock.Release(dev)
+				if dev != device {
+					c.lock.Release(device)
+				}
+				return
+			}
+			if dev != device {
+				c.lock.Release(device)
+			}
+			c.notify("Reconnected to " + c.Site)
+			return
+		}
+		if dev != device {
+			c.lock.Release(dev)
+		}
+		if err == nil {
+			err = fmt.Errorf("dial result: %s", resp.Result)
+		}
+		slog.Info("auto-redial attempt failed", "call", c.ID, "attempt", attempt, "err", err)
+		c.notify(fmt.Sprintf("Redial failed: %v", err))
+	}
+
+	c.redialFailed(device, fmt.Sprintf("carrier lost; auto-redial gave up after %d attempts", attempts))
+}
+
+// redialFailed ends a call whose redial did not connect. If the call was
+// hung up while redialing, Hangup left the device to the redial, so it is
+// released here instead.
+func (c *Call) redialFailed(device, reason string) {
+	c.mu.Lock()
+	c.redialing = false
+	ended := c.ended
+	c.mu.Unlock()
+	if ended {
+		c.lock.Release(device)
+		return
+	}
+	// The old modem is already closed; skip the hangup sequence.
+	c.carrierLost.Store(true)
+	c.Hangup(reason)
+}
+
+// reconnected installs a freshly dialed modem and restarts the reader. If
+// the call was hung up while the redial was in progress the new modem is
+// hung up and closed instead and ErrCallEnded is returned; releasing the
+// device is then up to the caller.
+func (c *Call) reconnected(mdm *modem.Modem, device, marker string) error {
+	c.mu.Lock()
+	if c.ended {
+		c.mu.Unlock()
+		mdm.Hangup()
+		mdm.Close()
+		return ErrCallEnded
+	}
+	readDone := make(chan struct{})
+	c.modem = mdm
+	c.device = device
+	c.readDone = readDone
+	c.redialing = false
+	c.mu.Unlock()
+	c.carrierLost.Store(false)
+	c.gotData.Store(false)
+	c.logger.Mark(marker)
+
+	go c.readLoop(mdm, readDone)
+	return nil
+}
+
+// notify prints a hub message to the attached terminal, if any.
+func (c *Call) notify(msg string) {
+	c.mu.Lock()
+	out := c.out
+	c.mu.Unlock()
+	if out != nil {
+		fmt.Fprintf(out, "\r\n*** %s ***\r\n", msg)
+	}
+}
+
+func (c *Call) isEnded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ended
+}
+
+// Redialing reports whether the call is between connections.
+func (c *Call) Redialing() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.redialing
+}
+
+// ConnectRate returns the bps of the current connection, or 0 if the modem
+// did not report one.
+func (c *Call) ConnectRate() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.modem.ConnectRate()
+}
+
+// Carrier reports whether the line is up. known is false when the modem
+// cannot report DCD, in which case up reflects only whether the call is
+// between connections.
+func (c *Call) Carrier() (up, known bool) {
+	c.mu.Lock()
+	mdm, down := c.modem, c.ended || c.redialing
+	c.mu.Unlock()
+	if down {
+		return false, true
+	}
+	up, err := mdm.Carrier()
+	if err != nil {
+		return true, false
+	}
+	return up, true
+}
+
+// Device returns the modem device currently carrying the call.
+func (c *Call) Device() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.device
+}
+
+// LastInput returns when the user last sent anything to the modem, or the
+// call start time if they have not typed yet.
+func (c *Call) LastInput() time.Time {
+	return time.Unix(0, c.lastInput.Load())
+}
+
+// Hangup ends the call: hangs up the modem unless the carrier is already
+// gone, closes the session log, releases the device and records the call
+// in the dial history. A call that is redialing keeps its device until the
+// redial resolves. It is safe to call more than once; only the first
+// reason is kept.
+func (c *Call) Hangup(reason string) {
+	c.mu.Lock()
+	if c.ended {
+		c.mu.Unlock()
+		return
+	}
+	c.ended = true
+	c.endReason = reason
+	c.out = nil
+	mdm, device, attempts, redialing := c.modem, c.device, c.attempts, c.redialing
+	if c.graceTimer != nil {
+		c.graceTimer.Stop()
+		c.graceTimer = nil
+	}
+	c.mu.Unlock()
+
+	slog.Info("call ended", "call", c.ID, "site", c.Site, "reason", reason)
+	c.logger.Mark("Hangup: " + reason)
+	c.logger.Close()
+	rate := mdm.ConnectRate() // read before the modem is closed
+	switch {
+	case redialing:
+		// The redial owns the modem and device until it resolves.
+		slog.Info("call is redialing, leaving the device to the redial")
+	case c.carrierLost.Load():
+		slog.Info("carrier already lost, skipping hangup")
+		mdm.Close()
+		c.lock.Release(device)
+	default:
+		mdm.Hangup()
+		mdm.Close()
+		c.lock.Release(device)
+	}
+	c.mgr.remove(c)
+	err := c.mgr.dials.Record(DialRecord{
+		Time:     c.Started,
+		Site:     c.Site,
+		User:     c.Owner,
+		Device:   device,
+		Result:   modem.ResultConnect.String(),
+		Attempts: attempts,
+		Rate:     rate,
+		Duration: time.Since(c.Started).Round(time.Second),
+		Ticket:   c.Ticket.ID,
+		Reason:   c.Ticket.Reason,
+	})
+	if err != nil {
+		slog.Error("recording dial history", "call", c.ID, "err", err)
+	}
+	close(c.done)
+}
+
+// Done is closed once the call has ended.
+func (c *Call) Done() <-chan struct{} { return c.done }
+
+// EndReason returns why the call ended, or "" while it is still up.
+func (c *Call) EndReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endReason
+}
+
+// CarrierLost reports whether the call ended because the modem dropped.
+func (c *Call) CarrierLost() bool { return c.carrierLost.Load() }
+
+// GotData reports whether the remote end has sent anything yet.
+func (c *Call) GotData() bool { return c.gotData.Load() }
+
+// DetachedUntil returns when a detached call will be hung up, or the zero
+// time if a terminal is attached.
+func (c *Call) DetachedUntil() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.detachedUntil
+}
+
+// AttachedBy returns the user whose terminal is attached, or "".
+func (c *Call) AttachedBy() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attachedBy
+}
+
+// RecentOutput returns up to the last n bytes the remote end sent.
+func (c *Call) RecentOutput(n int) []byte {
+	b := c.scrollback.Bytes()
+	if len(b) > n {
+		b = b[len(b)-n:]
+	}
+	return b
+}
+
+// LogPath returns the path of the call's session log.
+func (c *Call) LogPath() string { return c.logger.Path() }
+
+// readLoop pumps modem output to the log, scrollback and attached terminal
+// until the modem read fails. A failure while redialing is expected and
+// does not end the call.
+func (c *Call) readLoop(mdm *modem.Modem, done chan struct{}) {
+	defer close(done)
+	rwc := mdm.ReadWriteCloser()
+	buf := make([]byte, 1024)
+	for {
+		n, err := rwc.Read(buf)
+		if n > 0 {
+			c.gotData.Store(true)
+			c.bytesIn.Add(int64(n))
+			c.deliver(buf[:n])
+		}
+		if err != nil {
+			c.mu.Lock()
+			ended := c.ended || c.redialing
+			redial := !ended && c.autoRedial > 0
+			if redial {
+				// Set before readLoop returns so input typed meanwhile is
+				// dropped rather than failing on the dead modem.
+				c.redialing = true
+			}
+			c.mu.Unlock()
+			if !ended {
+				slog.Info("modem read ended", "call", c.ID, "err", err)
+				c.carrierLost.Store(true)
+				if redial {
+					go c.redialAfterCarrierLoss()
+				} else {
+					c.Hangup("carrier lost")
+				}
+			}
+			return
+		}
+	}
+}
+
+func (c *Call) deliver(p []byte) {
+	c.logger.Writer().Write(p)
+	c.scrollback.Write(p)
+
+	// Copy the watchers and terminal under the lock but write after
+	// releasing it, so a slow SSH client cannot stall Attach, Hangup or
+	// anything else that needs the call.
+	c.mu.Lock()
+	watches := slices.Clone(c.watches)
+	out := c.out
+	if c.replaying {
+		c.pending = append(c.pending, p...)
+		if n := len(c.pending) - c.mgr.scrollbackBytes; n > 0 {
+			c.pending = c.pending[n:]
+		}
+	}
+	c.mu.Unlock()
+	for _, w := range watches {
+		w.write(p)
+	}
+	if out != nil {
+		out.Write(p)
+	}
+}
+
+// Manager owns all live calls on the hub so they can be listed and
+// reattached from any SSH session.
+type Manager struct {
+	logDir          string
+	grace           time.Duration
+	scrollbackBytes int
+	autoRedial      int
+	dials           *DialLog
+
+	mu    sync.Mutex
+	calls map[string]*Call
+	seq   int
+}
+
+// NewManager creates a call manager. grace is how long detached calls are
+// held; scrollbackBytes is the per-call replay buffer size; autoRedial is
+// how many times to redial after carrier loss unless the site overrides it.
+// Calls are recorded in dials when they end; dials may be nil.
+func NewManager(logDir string, grace time.Duration, scrollbackBytes, autoRedial int, dials *DialLog) *Manager {
+	return &Manager{
+		logDir:          logDir,
+		grace:           grace,
+		scrollbackBytes: scrollbackBytes,
+		autoRedial:      autoRedial,
+		dials:           dials,
+		calls:           make(map[string]*Call),
+	}
+}
+
+// Start takes ownership of a connected modem and begins pumping its output.
+// attempts is how many times the site was dialed to connect; ticket is
+// recorded in the session log and dial history; priority is what the dial
+// ranked at. On error the modem is hung up and the device released.
+func (m *Manager) Start(owner string, ticket Ticket, priority int, site config.Site, device string, mdm *modem.Modem, lock *modem.DeviceLock, attempts int) (*Call, error) {
+	logger, err := NewLogger(m.logDir, site.Name, device, append([]string{"User", owner}, ticket.headerDetails()...)...)
+	if err != nil {
+		mdm.Hangup()
+		mdm.Close()
+		lock.Release(device)
+		return nil, fmt.Errorf("creating session logger: %w", err)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	c := &Call{
+		ID:         strconv.Itoa(m.seq),
+		Site:       site.Name,
+		Owner:      owner,
+		Ticket:     ticket,
+		Priority:   priority,
+		Started:    time.Now(),
+		mgr:        m,
+		site:       site,
+		device:     device,
+		autoRedial: m.autoRedial,
+		attempts:   attempts,
+		modem:      mdm,
+		lock:       lock,
+		logger:     logger,
+		scrollback: NewScrollback(m.scrollbackBytes),
+		readDone:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if site.AutoRedial != 0 {
+		c.autoRedial = max(site.AutoRedial, 0)
+	}
+	c.lastInput.Store(c.Started.UnixNano())
+	m.calls[c.ID] = c
+	m.mu.Unlock()
+
+	slog.Info("call started", "call", c.ID, "site", site.Name, "user", owner, "device", device, "ticket", ticket.ID, "reason", ticket.Reason)
+	go c.readLoop(mdm, c.readDone)
+	return c, nil
+}
+
+// Get returns the live call with the given ID, or nil.
+func (m *Manager) Get(id string) *Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[id]
+}
+
+// Calls returns all live calls, oldest first.
+func (m *Manager) Calls() []*Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := make([]*Call, 0, len(m.calls))
+	for _, c := range m.calls {
+		calls = append(calls, c)
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Started.Before(calls[j].Started) })
+	return calls
+}
+
+// Dials returns the dial history, which may be nil.
+func (m *Manager) Dials() *DialLog { return m.dials }
+
+// Grace returns how long detached calls are held before hangup.
+func (m *Manager) Grace() time.Duration { return m.grace }
+
+// HangupAll ends every live call, e.g. on hub shutdown.
+func (m *Manager) HangupAll(reason string) {
+	for _, c := range m.Calls() {
+		c.Hangup(reason)
+	}
+}
+
+func (m *Manager) remove(c *Call) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.calls, c.ID)
+}
@@ -0,0 +1,508 @@
+package session
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use by the call reader.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testCall starts a call on a PTY pair. Strings passed to send appear as
+// modem output; drop closes the PTY master to simulate carrier loss.
+func testCall(t *testing.T) (mgr *Manager, call *Call, send func(string), drop func()) {
+	t.Helper()
+	mgr, call, ptmx, drop := testCallPTY(t)
+	// The PTY is in canonical mode, so output must be newline-terminated.
+	send = func(s string) {
+		ptmx.Write([]byte(s + "\n"))
+	}
+	return mgr, call, send, drop
+}
+
+// testCallPTY starts a call on a PTY pair and returns the master side,
+// which plays the remote device.
+func testCallPTY(t *testing.T) (mgr *Manager, call *Call, ptmx *os.File, drop func()) {
+	t.Helper()
+	ptmx, pts, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	t.Cleanup(func() { pts.Close() })
+
+	lock := modem.NewDeviceLock(pts.Name())
+	if _, err := lock.Acquire("site-a"); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	mdm, err := modem.Open(pts.Name())
+	if err != nil {
+		t.Fatalf("modem.Open: %v", err)
+	}
+
+	dir := t.TempDir()
+	dials, err := OpenDialLog(filepath.Join(dir, "dials.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenDialLog: %v", err)
+	}
+	mgr = NewManager(dir, time.Minute, 1024, 0, dials)
+	call, err = mgr.Start("alice", Ticket{}, 0, config.Site{Name: "site-a"}, pts.Name(), mdm, lock, 1)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	drop = func() {
+		ptmx.Close()
+		<-call.Done()
+	}
+	t.Cleanup(drop)
+	return mgr, call, ptmx, drop
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScrollbackKeepsTail(t *testing.T) {
+	s := NewScrollback(8)
+	s.Write([]byte("hello "))
+	if got := string(s.Bytes()); got != "hello " {
+		t.Errorf("Bytes = %q, want %q", got, "hello ")
+	}
+	s.Write([]byte("world"))
+	if got := string(s.Bytes()); got != "lo world" {
+		t.Errorf("Bytes = %q, want %q", got, "lo world")
+	}
+	s.Write([]byte("0123456789"))
+	if got := string(s.Bytes()); got != "23456789" {
+		t.Errorf("Bytes = %q, want %q", got, "23456789")
+	}
+}
+
+func TestCallReattachReplaysScrollback(t *testing.T) {
+	mgr, call, send, _ := testCall(t)
+
+	var first syncBuffer
+	if err := call.Attach("alice", &first, false); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	send("router>")
+	waitFor(t, func() bool { return strings.Contains(first.String(), "router>") })
+
+	call.Detach(time.Minute)
+	if call.DetachedUntil().IsZero() {
+		t.Error("expected detached call to report a deadline")
+	}
+	send("%LINK-DOWN")
+	waitFor(t, func() bool { return strings.Contains(string(call.scrollback.Bytes()), "%LINK-DOWN") })
+	if strings.Contains(first.String(), "%LINK-DOWN") {
+		t.Error("detached terminal should not receive output")
+	}
+
+	var second syncBuffer
+	if err := call.Attach("bob", &second, true); err != nil {
+		t.Fatalf("reattach: %v", err)
+	}
+	if got := second.String(); !strings.Contains(got, "router>") || !strings.Contains(got, "%LINK-DOWN") {
+		t.Errorf("replayed scrollback = %q, want both outputs", got)
+	}
+	if !call.DetachedUntil().IsZero() {
+		t.Error("expected reattached call to clear detach deadline")
+	}
+	if call.AttachedBy() != "bob" {
+		t.Errorf("AttachedBy = %q, want bob", call.AttachedBy())
+	}
+	if mgr.Get(call.ID) != call {
+		t.Error("expected manager to track live call")
+	}
+}
+
+// stallWriter blocks its first write until release is closed.
+type stallWriter struct {
+	syncBuffer
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.entered)
+		<-w.release
+	})
+	return w.syncBuffer.Write(p)
+}
+
+func TestCallReplayOutsideLock(t *testing.T) {
+	_, call, send, _ := testCall(t)
+	send("before")
+	waitFor(t, func() bool { return strings.Contains(string(call.scrollback.Bytes()), "before") })
+
+	w := &stallWriter{entered: make(chan struct{}), release: make(chan struct{})}
+	attached := make(chan error, 1)
+	go func() { attached <- call.Attach("bob", w, true) }()
+	<-w.entered
+
+	// The call stays usable while the client is slow to take the replay.
+	got := make(chan string, 1)
+	go func() { got <- call.AttachedBy() }()
+	select {
+	case <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("call locked while replaying scrollback")
+	}
+	if err := call.Attach("carol", &syncBuffer{}, false); err == nil {
+		t.Error("second attach during replay should fail")
+	}
+
+	// Output arriving meanwhile follows the replay rather than being lost.
+	send("during")
+	waitFor(t, func() bool {
+		call.mu.Lock()
+		defer call.mu.Unlock()
+		return bytes.Contains(call.pending, []byte("during"))
+	})
+	close(w.release)
+	if err := <-attached; err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	out := w.String()
+	if i, j := strings.Index(out, "before"), strings.Index(out, "during"); i < 0 || j < i {
+		t.Errorf("terminal got %q, want the replay then the new output", out)
+	}
+	if call.AttachedBy() != "bob" {
+		t.Errorf("AttachedBy = %q, want bob", call.AttachedBy())
+	}
+}
+
+func TestCallDetachTwice(t *testing.T) {
+	_, call, _, _ := testCall(t)
+
+	call.Detach(50 * time.Millisecond)
+	call.Detach(time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	if call.EndReason() != "" {
+		t.Errorf("call ended by the first grace timer: %q", call.EndReason())
+	}
+}
+
+func TestCallSecondAttachRejected(t *testing.T) {
+	_, call, _, _ := testCall(t)
+
+	var a, b syncBuffer
+	if err := call.Attach("alice", &a, false); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if err := call.Attach("bob", &b, true); err == nil {
+		t.Error("expected error attaching to an already-attached call")
+	}
+}
+
+func TestCallCarrierLossEndsCall(t *testing.T) {
+	mgr, call, _, drop := testCall(t)
+
+	drop()
+
+	if !call.CarrierLost() {
+		t.Error("expected carrier loss to be recorded")
+	}
+	if call.EndReason() != "carrier lost" {
+		t.Errorf("EndReason = %q, want %q", call.EndReason(), "carrier lost")
+	}
+	if mgr.Get(call.ID) != nil {
+		t.Error("expected ended call to be removed from manager")
+	}
+	if err := call.Attach("alice", &syncBuffer{}, false); err != ErrCallEnded {
+		t.Errorf("Attach after end = %v, want ErrCallEnded", err)
+	}
+}
+
+func TestCallCarrierLossWhileDetached(t *testing.T) {
+	mgr, call, _, drop := testCall(t)
+
+	call.Detach(100 * time.Millisecond)
+	drop()
+	if call.EndReason() != "carrier lost" {
+		t.Errorf("EndReason = %q, want %q", call.EndReason(), "carrier lost")
+	}
+	if mgr.Get(call.ID) != nil {
+		t.Error("expected ended call to be removed from manager")
+	}
+
+	call.mu.Lock()
+	timer := call.graceTimer
+	call.mu.Unlock()
+	if timer != nil {
+		t.Error("grace timer still set after carrier loss")
+	}
+
+	// Nor does the grace period running out end the call a second time.
+	time.Sleep(200 * time.Millisecond)
+	if call.EndReason() != "carrier lost" {
+		t.Errorf("EndReason after grace = %q, want %q", call.EndReason(), "carrier lost")
+	}
+}
+
+func TestWatchWaitsForPrompt(t *testing.T) {
+	_, call, send, _ := testCall(t)
+	watch := call.Watch()
+	defer watch.Close()
+
+	prompt := regexp.MustCompile(`router#\s*$`)
+	if err := watch.Wait(prompt, 50*time.Millisecond, nil); err == nil {
+		t.Fatal("expected timeout before the prompt arrives")
+	}
+
+	go send("show clock\r\n12:00:00\r\nrouter#")
+	if err := watch.Wait(prompt, 2*time.Second, nil); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	watch.Reset()
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := watch.Wait(prompt, time.Second, cancel); err != ErrWaitCancelled {
+		t.Fatalf("Wait after Reset = %v, want ErrWaitCancelled", err)
+	}
+}
+
+// fakeModem answers AT commands on a PTY master: OK to everything, and
+// CONNECT to ATDT.
+func fakeModem(t *testing.T, ptmx *os.File) {
+	t.Helper()
+	connect := make(chan struct{})
+	close(connect)
+	gatedModem(t, ptmx, connect)
+}
+
+// gatedModem is fakeModem, but holds back CONNECT until connect is closed,
+// leaving the dial blocked meanwhile.
+func gatedModem(t *testing.T, ptmx *os.File, connect <-chan struct{}) {
+	t.Helper()
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := ptmx.Read(buf)
+			if err != nil {
+				return
+			}
+			switch cmd := string(buf[:n]); {
+			case strings.Contains(cmd, "ATDT"):
+				<-connect
+				ptmx.Write([]byte("\r\nCONNECT 9600\r\n"))
+			case strings.Contains(cmd, "AT"):
+				ptmx.Write([]byte("\r\nOK\r\n"))
+			}
+		}
+	}()
+}
+
+func TestCallAutoRedialMovesToAnotherDevice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the full modem init sequence")
+	}
+	defer func(d time.Duration) { AutoRedialDelay = d }(AutoRedialDelay)
+	AutoRedialDelay = 10 * time.Millisecond
+
+	ptmxA, ptsA, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptsA.Close()
+	ptmxB, ptsB, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptmxB.Close()
+	defer ptsB.Close()
+	fakeModem(t, ptmxB)
+
+	lock := modem.NewDeviceLock(ptsA.Name(), ptsB.Name())
+	dev, err := lock.Acquire("site-a")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	mdm, err := modem.Open(dev)
+	if err != nil {
+		t.Fatalf("modem.Open: %v", err)
+	}
+	mgr := NewManager(t.TempDir(), time.Minute, 1024, 2, nil)
+	call, err := mgr.Start("alice", Ticket{}, 0, config.Site{Name: "site-a", Phone: "5551234"}, dev, mdm, lock, 1)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer call.Hangup("test done")
+	var out syncBuffer
+	call.Attach("alice", &out, false)
+
+	// Drop the first line for good: its device can no longer be opened, so
+	// the second attempt moves to the other device in the pool.
+	ptmxA.Close()
+	ptsA.Close()
+	// Modem init takes a few seconds of +++ guard time.
+	deadline := time.Now().Add(10 * time.Second)
+	for call.Device() != ptsB.Name() || call.Redialing() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for redial; output:\n%s", out.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if call.CarrierLost() {
+		t.Error("expected carrier restored after redial")
+	}
+	if sites := lock.ActiveSites(); len(sites) != 1 {
+		t.Errorf("expected the first device released, active = %v", sites)
+	}
+	for _, want := range []string{"attempt 1 of 2", "attempt 2 of 2", "Reconnected to site-a"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("terminal output missing %q:\n%s", want, out.String())
+		}
+	}
+	log, _ := os.ReadFile(call.LogPath())
+	if !strings.Contains(string(log), "Reconnected by auto-redial on "+ptsB.Name()) {
+		t.Errorf("session log missing reconnect marker:\n%s", log)
+	}
+}
+
+func TestCallHangupDuringAutoRedial(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the full modem init sequence")
+	}
+	defer func(d time.Duration) { AutoRedialDelay = d }(AutoRedialDelay)
+	AutoRedialDelay = 10 * time.Millisecond
+
+	ptmxA, ptsA, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptsA.Close()
+	ptmxB, ptsB, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptmxB.Close()
+	defer ptsB.Close()
+	connect := make(chan struct{})
+	gatedModem(t, ptmxB, connect)
+
+	lock := modem.NewDeviceLock(ptsA.Name(), ptsB.Name())
+	dev, err := lock.Acquire("site-a")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	mdm, err := modem.Open(dev)
+	if err != nil {
+		t.Fatalf("modem.Open: %v", err)
+	}
+	mgr := NewManager(t.TempDir(), time.Minute, 1024, 2, nil)
+	call, err := mgr.Start("alice", Ticket{}, 0, config.Site{Name: "site-a", Phone: "5551234"}, dev, mdm, lock, 1)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var out syncBuffer
+	call.Attach("alice", &out, false)
+
+	// The first attempt fails on the dead line; the second blocks dialing
+	// on the other device until connect is closed.
+	ptmxA.Close()
+	ptsA.Close()
+	waitFor(t, func() bool { return strings.Contains(out.String(), "attempt 2 of 2") })
+
+	call.Hangup("hung up by alice")
+	// Both lines still belong to the blocked redial.
+	if _, err := lock.Acquire("site-b"); err == nil {
+		t.Fatal("a device was released while the redial still held it")
+	}
+
+	close(connect)
+	deadline := time.Now().Add(15 * time.Second)
+	for len(lock.ActiveSites()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("devices not released after the redial resolved, active = %v", lock.ActiveSites())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := call.Device(); got != ptsA.Name() {
+		t.Errorf("ended call moved to %s", got)
+	}
+	if got, want := call.EndReason(), "hung up by alice"; got != want {
+		t.Errorf("end reason = %q, want %q", got, want)
+	}
+}
+
+func TestCallAutoRedialGivesUp(t *testing.T) {
+	defer func(d time.Duration) { AutoRedialDelay = d }(AutoRedialDelay)
+	AutoRedialDelay = 10 * time.Millisecond
+
+	_, call, _, drop := testCall(t)
+	call.mu.Lock()
+	call.autoRedial = 2
+	call.mu.Unlock()
+
+	// The only line is gone, so both attempts fail.
+	drop()
+	if got, want := call.EndReason(), "carrier lost; auto-redial gave up after 2 attempts"; got != want {
+		t.Errorf("end reason = %q, want %q", got, want)
+	}
+}
+
+func TestCallReconnectedAfterHangup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out two modem hangup sequences")
+	}
+	_, call, _, _ := testCall(t)
+	call.Hangup("test done")
+
+	ptmx, pts, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	defer ptmx.Close()
+	defer pts.Close()
+	mdm, err := modem.Open(pts.Name())
+	if err != nil {
+		t.Fatalf("modem.Open: %v", err)
+	}
+
+	if err := call.reconnected(mdm, pts.Name(), "Reconnected"); err != ErrCallEnded {
+		t.Fatalf("reconnected = %v, want ErrCallEnded", err)
+	}
+	if _, err := mdm.ReadWriteCloser().Write([]byte("x")); err == nil {
+		t.Error("expected the new modem to be closed")
+	}
+	if call.Device() == pts.Name() {
+		t.Error("ended call took the new device")
+	}
+}
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+)
+
+// Keep this slightly above Asterisk's Dial() timeout (120s in extensions.conf)
+// so we can receive final modem result codes like NO CARRIER instead of a
+// premature local TIMEOUT.
+const (
+	DialTimeout  = 125 * time.Second
+	ResetTimeout = 5 * time.Second
+	MaxRetries   = 3
+	RetryDelay   = 2 * time.Second
+)
+
+// AutoRedialDelay is the pause between auto-redial attempts after carrier
+// loss. A variable so tests can shorten it.
+var AutoRedialDelay = 5 * time.Second
+
+// Retryable returns true for dial results that may succeed on retry.
+func Retryable(r modem.DialResult) bool {
+	return r == modem.ResultNoCarrier || r == modem.ResultTimeout
+}
+
+// DialOutcome is the modem's last response to DialSite, with the number of
+// times the number was dialed.
+type DialOutcome struct {
+	modem.DialResponse
+	Attempts int
+}
+
+// DialSite runs the open → init → configure → dial sequence for site on an
+// already-acquired device, retrying transient failures (NO CARRIER,
+// TIMEOUT). On CONNECT the open modem is returned; on any other result the
+// modem is closed and only the response is returned. progress, if non-nil,
+// receives a short description of each step.
+func DialSite(site config.Site, device string, progress func(string)) (*modem.Modem, DialOutcome, error) {
+	report := func(format string, args ...any) {
+		if progress != nil {
+			progress(fmt.Sprintf(format, args...))
+		}
+	}
+
+	var lastResp DialOutcome
+	for attempt := 1; attempt <= MaxRetries; attempt++ {
+		if attempt > 1 {
+			time.Sleep(RetryDelay)
+		}
+
+		// Open device
+		mdm, err := modem.Open(device)
+		if err != nil {
+			return nil, lastResp, fmt.Errorf("failed to open %s: %w", device, err)
+		}
+
+		// Initialize modem (ATE0 + ATZ)
+		report("Initializing modem on %s...", device)
+		if err := mdm.Init(ResetTimeout); err != nil {
+			mdm.Close()
+			return nil, lastResp, fmt.Errorf("modem init failed: %w", err)
+		}
+
+		// Send pre-dial configuration commands if any
+		if len(site.ModemInit) > 0 {
+			if err := mdm.Configure(site.ModemInit, ResetTimeout); err != nil {
+				mdm.Close()
+				return nil, lastResp, fmt.Errorf("modem configure failed: %w", err)
+			}
+		}
+
+		// Dial
+		report("Dialing %s (attempt %d/%d)...", site.Phone, attempt, MaxRetries)
+		dialed, err := mdm.Dial(site.Phone, DialTimeout)
+		resp := DialOutcome{DialResponse: dialed, Attempts: attempt}
+		if err != nil {
+			mdm.Hangup()
+			mdm.Close()
+			return nil, resp, fmt.Errorf("dial error: %w", err)
+		}
+
+		if resp.Result == modem.ResultConnect {
+			return mdm, resp, nil
+		}
+
+		lastResp = resp
+		report("Dial result: %s", resp.Result)
+		slog.Info("dial failed, checking retry", "result", resp.Result, "attempt", attempt, "max", MaxRetries)
+
+		// Clean up before potential retry
+		mdm.Hangup()
+		mdm.Close()
+
+		// Non-retryable results: fail immediately
+		if !Retryable(resp.Result) {
+			return nil, resp, nil
+		}
+
+		if attempt < MaxRetries {
+			slog.Info("retrying dial", "attempt", attempt+1, "max", MaxRetries)
+		}
+	}
+
+	// All retries exhausted
+	return nil, lastResp, nil
+}
@@ -0,0 +1,206 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/modem"
+)
+
+// DialHistoryKeep is how many recent dials are kept per site.
+const DialHistoryKeep = 50
+
+// DialRecord is the outcome of one dial, as kept in the dial history.
+// Calls that connect are recorded when they end: Time is when the call
+// started and Duration how long it was up. Failed dials have no Duration.
+type DialRecord struct {
+	Time     time.Time     `json:"time"`
+	Site     string        `json:"site"`
+	User     string        `json:"user"`
+	Device   string        `json:"device,omitempty"`
+	Result   string        `json:"result"`
+	Attempts int           `json:"attempts"`
+	Rate     int           `json:"rate,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Ticket   string        `json:"ticket,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+// Connected reports whether the dial reached the site.
+func (r DialRecord) Connected() bool { return r.Result == modem.ResultConnect.String() }
+
+// DialStats summarises a site's recent dials.
+type DialStats struct {
+	Dials       int
+	Connects    int
+	LastContact time.Time // end of the last call that connected; zero if none kept
+	Last        *DialRecord
+}
+
+// SuccessRate returns the percentage of recent dials that connected, or -1
+// if the site has not been dialed.
+func (s DialStats) SuccessRate() int {
+	if s.Dials == 0 {
+		return -1
+	}
+	return s.Connects * 100 / s.Dials
+}
+
+// DialLog is the hub's dial history, one JSON record per line, with the
+// most recent DialHistoryKeep records per site held in memory. A nil
+// *DialLog records nothing.
+type DialLog struct {
+	mu     sync.Mutex
+	path   string
+	sites  map[string][]DialRecord // oldest first
+	onDisk int                     // records in the file
+}
+
+// OpenDialLog loads the dial history at path, creating it on first use.
+func OpenDialLog(path string) (*DialLog, error) {
+	d := &DialLog{path: path, sites: make(map[string][]DialRecord)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening dial history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r DialRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue // skip a line torn by a crash
+		}
+		d.add(r)
+		d.onDisk++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading dial history: %w", err)
+	}
+	return d, nil
+}
+
+func (d *DialLog) add(r DialRecord) {
+	recs := append(d.sites[r.Site], r)
+	if len(recs) > DialHistoryKeep {
+		recs = recs[len(recs)-DialHistoryKeep:]
+	}
+	d.sites[r.Site] = recs
+}
+
+func (d *DialLog) kept() int {
+	n := 0
+	for _, recs := range d.sites {
+		n += len(recs)
+	}
+	return n
+}
+
+// Record appends a dial outcome. The file is rewritten with only the kept
+// records once it has grown to twice their number.
+func (d *DialLog) Record(r DialRecord) error {
+	if d == nil {
+		return nil
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.add(r)
+
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return fmt.Errorf("creating dial history dir: %w", err)
+	}
+	if d.onDisk+1 > 2*max(d.kept(), DialHistoryKeep) {
+		return d.rewrite()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("writing dial history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing dial history: %w", err)
+	}
+	d.onDisk++
+	return nil
+}
+
+// rewrite replaces the file with the kept records.
+func (d *DialLog) rewrite() error {
+	tmp := d.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("writing dial history: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	n := 0
+	for _, recs := range d.sites {
+		for _, r := range recs {
+			enc.Encode(r)
+			n++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing dial history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing dial history: %w", err)
+	}
+	d.onDisk = n
+	return os.Rename(tmp, d.path)
+}
+
+// Recent returns up to n of the site's most recent dials, newest first.
+func (d *DialLog) Recent(site string, n int) []DialRecord {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	recs := d.sites[site]
+	out := make([]DialRecord, 0, min(n, len(recs)))
+	for i := len(recs) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, recs[i])
+	}
+	return out
+}
+
+// Stats summarises the site's kept dials.
+func (d *DialLog) Stats(site string) DialStats {
+	var s DialStats
+	if d == nil {
+		return s
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	recs := d.sites[site]
+	for _, r := range recs {
+		s.Dials++
+		if r.Connected() {
+			s.Connects++
+			s.LastContact = r.Time.Add(r.Duration)
+		}
+	}
+	if len(recs) > 0 {
+		last := recs[len(recs)-1]
+		s.Last = &last
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDialLogPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dials.jsonl")
+	d, err := OpenDialLog(path)
+	if err != nil {
+		t.Fatalf("OpenDialLog: %v", err)
+	}
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i, rec := range []DialRecord{
+		{Site: "router1", User: "alice", Result: "NO CARRIER", Attempts: 3},
+		{Site: "router1", User: "bob", Result: "CONNECT", Attempts: 1, Rate: 9600, Duration: 10 * time.Minute},
+		{Site: "switch1", User: "bob", Result: "BUSY", Attempts: 1},
+		{Site: "router1", User: "alice", Result: "BUSY", Attempts: 1},
+	} {
+		rec.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := d.Record(rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	d, err = OpenDialLog(path)
+	if err != nil {
+		t.Fatalf("OpenDialLog: %v", err)
+	}
+	recent := d.Recent("router1", 2)
+	if len(recent) != 2 || recent[0].Result != "BUSY" || recent[1].Result != "CONNECT" {
+		t.Fatalf("Recent = %+v, want BUSY then CONNECT", recent)
+	}
+	if recent[1].Rate != 9600 || recent[1].Duration != 10*time.Minute {
+		t.Errorf("CONNECT record = %+v", recent[1])
+	}
+
+	s := d.Stats("router1")
+	if s.Dials != 3 || s.Connects != 1 || s.SuccessRate() != 33 {
+		t.Errorf("Stats = %+v (rate %d), want 1 of 3", s, s.SuccessRate())
+	}
+	if want := start.Add(time.Hour + 10*time.Minute); !s.LastContact.Equal(want) {
+		t.Errorf("LastContact = %s, want %s", s.LastContact, want)
+	}
+	if s.Last == nil || s.Last.User != "alice" {
+		t.Errorf("Last = %+v", s.Last)
+	}
+	if rate := d.Stats("unknown").SuccessRate(); rate != -1 {
+		t.Errorf("SuccessRate for undialed site = %d, want -1", rate)
+	}
+}
+
+func TestDialLogTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dials.jsonl")
+	d, _ := OpenDialLog(path)
+	for range DialHistoryKeep*3 + 1 {
+		if err := d.Record(DialRecord{Site: "router1", Result: "BUSY"}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if got := len(d.Recent("router1", 1000)); got != DialHistoryKeep {
+		t.Errorf("kept %d records, want %d", got, DialHistoryKeep)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > 2*DialHistoryKeep {
+		t.Errorf("file has %d records, want at most %d", lines, 2*DialHistoryKeep)
+	}
+}
+
+func TestNilDialLog(t *testing.T) {
+	var d *DialLog
+	if err := d.Record(DialRecord{Site: "router1"}); err != nil {
+		t.Errorf("Record: %v", err)
+	}
+	if d.Recent("router1", 5) != nil || d.Stats("router1").Dials != 0 {
+		t.Error("nil DialLog should be empty")
+	}
+}
+
+func TestCallRecordedOnHangup(t *testing.T) {
+	mgr, call, _, drop := testCall(t)
+	drop()
+	recent := mgr.Dials().Recent("site-a", 5)
+	if len(recent) != 1 {
+		t.Fatalf("Recent = %+v, want one record", recent)
+	}
+	r := recent[0]
+	if !r.Connected() || r.User != "alice" || r.Attempts != 1 || !r.Time.Equal(call.Started) {
+		t.Errorf("record = %+v", r)
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HistorySize is how many commands are kept per user and site.
+const HistorySize = 500
+
+// History is one user's line-mode command history for one site, stored as
+// one command per line so it survives across sessions.
+type History struct {
+	mu      sync.Mutex
+	path    string
+	entries []string
+}
+
+// LoadHistory opens the history for user on site under dir, creating it on
+// first use. A missing file is an empty history.
+func LoadHistory(dir, user, site string) (*History, error) {
+	h := &History{path: filepath.Join(dir, safeName(user), safeName(site))}
+
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	if len(h.entries) > HistorySize {
+		h.entries = h.entries[len(h.entries)-HistorySize:]
+	}
+	return h, nil
+}
+
+// Entries returns a copy of the history, oldest first.
+func (h *History) Entries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.entries...)
+}
+
+// Add records a command, skipping blanks and repeats of the last command.
+// The file is appended to, and rewritten once it grows well past
+// HistorySize.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || strings.ContainsAny(line, "\r\n") {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("creating history dir: %w", err)
+	}
+	if len(h.entries) > HistorySize+HistorySize/4 {
+		h.entries = h.entries[len(h.entries)-HistorySize:]
+		return h.rewrite()
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+// rewrite replaces the file with the in-memory entries.
+func (h *History) rewrite() error {
+	tmp := h.path + ".tmp"
+	data := strings.Join(h.entries, "\n") + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// safeName makes a user or site name safe to use as a path element.
+func safeName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_" + s
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestHistoryPersists(t *testing.T) {
+	dir := t.TempDir()
+	h, err := LoadHistory(dir, "alice", "router1")
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	for _, line := range []string{"show ver", "show ver", "  ", "show clock"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("Add(%q): %v", line, err)
+		}
+	}
+
+	h2, err := LoadHistory(dir, "alice", "router1")
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	got := h2.Entries()
+	if len(got) != 2 || got[0] != "show ver" || got[1] != "show clock" {
+		t.Errorf("entries = %q, want [show ver show clock]", got)
+	}
+
+	other, _ := LoadHistory(dir, "bob", "router1")
+	if len(other.Entries()) != 0 {
+		t.Errorf("bob sees alice's history: %q", other.Entries())
+	}
+}
+
+func TestHistoryTrims(t *testing.T) {
+	dir := t.TempDir()
+	h, _ := LoadHistory(dir, "alice", "router1")
+	total := HistorySize*2 + 7
+	for i := range total {
+		h.Add(fmt.Sprintf("cmd %d", i))
+	}
+
+	h2, _ := LoadHistory(dir, "alice", "router1")
+	got := h2.Entries()
+	if len(got) != HistorySize {
+		t.Fatalf("kept %d entries, want %d", len(got), HistorySize)
+	}
+	if want := fmt.Sprintf("cmd %d", total-1); got[len(got)-1] != want {
+		t.Errorf("last entry = %q, want %q", got[len(got)-1], want)
+	}
+}
+
+func TestHistorySafePath(t *testing.T) {
+	dir := t.TempDir()
+	h, _ := LoadHistory(dir, "../evil", "..")
+	if rel, _ := filepath.Rel(dir, h.path); rel != filepath.Join(".._evil", "_..") {
+		t.Errorf("history path %q escapes %q", h.path, dir)
+	}
+}
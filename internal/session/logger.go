@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -16,7 +17,9 @@ type Logger struct {
 
 // NewLogger creates a session log file in logDir with the pattern:
 // {siteName}_{YYYYmmdd-HHMMSS}_{device}.log
-func NewLogger(logDir, siteName, device string) (*Logger, error) {
+// details are extra header fields as name, value pairs, such as "User",
+// "alice"; see ParseLogHeader.
+func NewLogger(logDir, siteName, device string, details ...string) (*Logger, error) {
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return nil, fmt.Errorf("creating log dir: %w", err)
 	}
@@ -32,13 +35,20 @@ func NewLogger(logDir, siteName, device string) (*Logger, error) {
 	}
 
 	// Write header
-	header := fmt.Sprintf("=== Session: %s | Device: %s | Started: %s ===\n",
-		siteName, device, time.Now().Format(time.RFC3339))
+	header := fmt.Sprintf("=== Session: %s | Device: %s", siteName, device)
+	for i := 0; i+1 < len(details); i += 2 {
+		header += fmt.Sprintf(" | %s: %s", details[i], headerValue.Replace(details[i+1]))
+	}
+	header += fmt.Sprintf(" | Started: %s ===\n", time.Now().Format(time.RFC3339))
 	f.WriteString(header)
 
 	return &Logger{file: f, path: path}, nil
 }
 
+// headerValue keeps user-supplied header values, such as a change reason,
+// from breaking the header's one-line format.
+var headerValue = strings.NewReplacer("|", "/", "\r", " ", "\n", " ")
+
 // Writer returns an io.Writer that writes to the log file.
 // Use with io.TeeReader to capture modem→user traffic.
 func (l *Logger) Writer() io.Writer {
@@ -55,6 +65,12 @@ func (l *Logger) Path() string {
 	return l.path
 }
 
+// Mark writes a timestamped event line into the transcript, e.g. when a
+// call is detached or reattached.
+func (l *Logger) Mark(event string) {
+	fmt.Fprintf(l.file, "\n=== %s: %s ===\n", event, time.Now().Format(time.RFC3339))
+}
+
 // Close writes a footer and closes the log file.
 func (l *Logger) Close() error {
 	footer := fmt.Sprintf("\n=== Session ended: %s ===\n", time.Now().Format(time.RFC3339))
@@ -72,3 +72,30 @@ func TestLoggerCreatesDir(t *testing.T) {
 		t.Errorf("expected dir to be created: %v", err)
 	}
 }
+
+func TestListLogs(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(dir, "router1", "/dev/ttyIAX0", "User", "alice")
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	l.Close()
+	// An older log without a user, named by the old pattern only.
+	os.WriteFile(filepath.Join(dir, "core_sw1_20250102-030405_ttyIAX1.log"), []byte("garbage\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "dials.jsonl"), []byte("{}\n"), 0644)
+
+	logs, err := ListLogs(dir)
+	if err != nil {
+		t.Fatalf("ListLogs: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("ListLogs = %+v, want 2 logs", logs)
+	}
+	if e := logs[0]; e.Site != "router1" || e.User != "alice" || e.Device != "/dev/ttyIAX0" || e.Path != l.Path() {
+		t.Errorf("newest = %+v", e)
+	}
+	e := logs[1]
+	if e.Site != "core_sw1" || e.Device != "ttyIAX1" || e.User != "" || e.Started.Year() != 2025 {
+		t.Errorf("fallback = %+v", e)
+	}
+}
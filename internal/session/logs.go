@@ -0,0 +1,103 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogEntry describes one session log in the log directory.
+type LogEntry struct {
+	Path    string
+	Site    string
+	User    string // empty for logs written before users were recorded
+	Device  string
+	Started time.Time
+	Size    int64
+
+	// Header holds every field of the log's header line.
+	Header map[string]string
+}
+
+// ParseLogHeader splits a log's first line,
+// "=== Session: site | Device: dev | User: alice | Started: ... ===", into
+// its fields. ok is false if line is not a session header.
+func ParseLogHeader(line string) (fields map[string]string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "=== Session: ") || !strings.HasSuffix(line, " ===") {
+		return nil, false
+	}
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "=== "), " ===")
+	fields = make(map[string]string)
+	for _, part := range strings.Split(line, " | ") {
+		name, value, found := strings.Cut(part, ": ")
+		if found {
+			fields[name] = value
+		}
+	}
+	return fields, true
+}
+
+// ListLogs lists the session logs in dir, newest first. Other files in the
+// directory, such as the dial history, are skipped.
+func ListLogs(dir string) ([]LogEntry, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading log dir: %w", err)
+	}
+	var out []LogEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".log") {
+			continue
+		}
+		e, err := readLogEntry(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue // removed or unreadable since ReadDir
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.After(out[j].Started) })
+	return out, nil
+}
+
+func readLogEntry(path string) (LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return LogEntry{}, err
+	}
+	e := LogEntry{Path: path, Size: st.Size(), Started: st.ModTime()}
+
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	header, ok := ParseLogHeader(line)
+	if !ok {
+		// Not written by NewLogger; fall back to {site}_{time}_{device}.log.
+		name := strings.TrimSuffix(filepath.Base(path), ".log")
+		parts := strings.Split(name, "_")
+		if len(parts) >= 3 {
+			e.Site = strings.Join(parts[:len(parts)-2], "_")
+			e.Device = parts[len(parts)-1]
+			if t, err := time.ParseInLocation("20060102-150405", parts[len(parts)-2], time.Local); err == nil {
+				e.Started = t
+			}
+		}
+		return e, nil
+	}
+	e.Header = header
+	e.Site, e.Device, e.User = header["Session"], header["Device"], header["User"]
+	if t, err := time.Parse(time.RFC3339, header["Started"]); err == nil {
+		e.Started = t
+	}
+	return e, nil
+}
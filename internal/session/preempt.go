@@ -0,0 +1,107 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// PreemptWarning is how long the holder of a preempted call is warned
+// before it is hung up.
+var PreemptWarning = 60 * time.Second
+
+// Preempt hangs the call up after counting down PreemptWarning in the
+// holder's session, so that by, dialing at a higher priority with ticket,
+// can have its modem. If ctx ends first, because by gave up the dial or
+// disconnected, the preemption is withdrawn and the call carries on. The
+// preemption and any withdrawal are recorded in audit. It reports false if
+// the call has ended or is already being preempted.
+func (c *Call) Preempt(ctx context.Context, by string, ticket Ticket, audit *AuditLog) bool {
+	c.mu.Lock()
+	if c.ended || c.preemptedBy != "" {
+		c.mu.Unlock()
+		return false
+	}
+	c.preemptedBy = by
+	c.preemptAt = time.Now().Add(PreemptWarning)
+	c.mu.Unlock()
+
+	slog.Info("call preempted", "call", c.ID, "site", c.Site, "user", c.Owner, "by", by, "ticket", ticket.ID)
+	c.logger.Mark(fmt.Sprintf("Preempted by %s, hanging up in %s", by, PreemptWarning))
+	err := audit.Record(AuditEvent{
+		Event:  "preempted",
+		User:   c.Owner,
+		Site:   c.Site,
+		Ticket: ticket.ID,
+		Reason: ticket.Reason,
+		By:     by,
+	})
+	if err != nil {
+		slog.Error("recording preemption", "call", c.ID, "err", err)
+	}
+	go c.countdown(ctx, by, ticket, audit)
+	return true
+}
+
+// countdown warns the attached terminal every ten seconds, then every
+// second for the last five, and hangs up when the preemption is due
+// unless it is withdrawn first.
+func (c *Call) countdown(ctx context.Context, by string, ticket Ticket, audit *AuditLog) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for left := int(PreemptWarning / time.Second); left > 0; left-- {
+		if left%10 == 0 || left <= 5 || left == int(PreemptWarning/time.Second) {
+			c.notify(fmt.Sprintf("%s needs this line for a higher-priority call: hanging up in %ds", by, left))
+		}
+		select {
+		case <-c.done:
+			return
+		case <-ctx.Done():
+			c.withdrawPreemption(by, ticket, audit)
+			return
+		case <-tick.C:
+		}
+	}
+	if ctx.Err() != nil {
+		c.withdrawPreemption(by, ticket, audit)
+		return
+	}
+	c.notify("Line preempted by " + by)
+	c.Hangup("preempted by " + by)
+}
+
+// withdrawPreemption tells the holder the call is no longer being hung up,
+// and lets it be preempted again later.
+func (c *Call) withdrawPreemption(by string, ticket Ticket, audit *AuditLog) {
+	c.mu.Lock()
+	if c.ended {
+		c.mu.Unlock()
+		return
+	}
+	c.preemptedBy = ""
+	c.preemptAt = time.Time{}
+	c.mu.Unlock()
+
+	slog.Info("preemption withdrawn", "call", c.ID, "site", c.Site, "user", c.Owner, "by", by)
+	c.logger.Mark("Preemption by " + by + " withdrawn")
+	err := audit.Record(AuditEvent{
+		Event:  "preempt-withdrawn",
+		User:   c.Owner,
+		Site:   c.Site,
+		Ticket: ticket.ID,
+		By:     by,
+	})
+	if err != nil {
+		slog.Error("recording preemption", "call", c.ID, "err", err)
+	}
+	c.notify(by + " withdrew the preemption; the call continues")
+}
+
+// Preempted returns who is preempting the call and when it will be hung
+// up, or "" if nobody is.
+func (c *Call) Preempted() (by string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.preemptedBy, c.preemptAt
+}
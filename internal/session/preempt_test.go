@@ -0,0 +1,106 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreempt(t *testing.T) {
+	old := PreemptWarning
+	PreemptWarning = 2 * time.Second
+	t.Cleanup(func() { PreemptWarning = old })
+
+	_, call, _, _ := testCall(t)
+	var out syncBuffer
+	if err := call.Attach("alice", &out, false); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !call.Preempt(context.Background(), "bob", Ticket{ID: "INC1", Reason: "P1 outage"}, audit) {
+		t.Fatal("Preempt refused a live call")
+	}
+	if call.Preempt(context.Background(), "carol", Ticket{}, audit) {
+		t.Error("a second preemption was accepted")
+	}
+	if by, at := call.Preempted(); by != "bob" || time.Until(at) > PreemptWarning {
+		t.Errorf("Preempted = %q, %v", by, at)
+	}
+
+	select {
+	case <-call.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("preempted call was not hung up")
+	}
+	if got := call.EndReason(); got != "preempted by bob" {
+		t.Errorf("EndReason = %q", got)
+	}
+	for _, want := range []string{"hanging up in 2s", "hanging up in 1s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("session output missing %q:\n%s", want, out.String())
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.Contains(s, `"event":"preempted"`) || !strings.Contains(s, `"by":"bob"`) || !strings.Contains(s, `"ticket":"INC1"`) {
+		t.Errorf("audit log:\n%s", s)
+	}
+}
+
+func TestPreemptWithdrawn(t *testing.T) {
+	old := PreemptWarning
+	PreemptWarning = 30 * time.Second
+	t.Cleanup(func() { PreemptWarning = old })
+
+	_, call, _, _ := testCall(t)
+	var out syncBuffer
+	if err := call.Attach("alice", &out, false); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, withdraw := context.WithCancel(context.Background())
+	if !call.Preempt(ctx, "bob", Ticket{ID: "INC1"}, audit) {
+		t.Fatal("Preempt refused a live call")
+	}
+	withdraw()
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "bob withdrew the preemption") {
+		if time.Now().After(deadline) {
+			t.Fatalf("holder not told of the withdrawal:\n%s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if by, _ := call.Preempted(); by != "" {
+		t.Errorf("still preempted by %q", by)
+	}
+	select {
+	case <-call.Done():
+		t.Fatalf("call hung up after the preemption was withdrawn: %s", call.EndReason())
+	default:
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); !strings.Contains(s, `"event":"preempt-withdrawn"`) {
+		t.Errorf("audit log:\n%s", s)
+	}
+	if !call.Preempt(context.Background(), "carol", Ticket{}, audit) {
+		t.Error("call could not be preempted again after a withdrawal")
+	}
+}
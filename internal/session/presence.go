@@ -0,0 +1,158 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// What a connected SSH user is doing, as shown on the who's-on dashboard.
+const (
+	PresenceMenu      = "menu"
+	PresenceDialing   = "dialing"
+	PresenceConnected = "connected"
+)
+
+// Registry tracks every SSH session connected to the hub, whether or not
+// it has a call up. A nil *Registry tracks nothing.
+type Registry struct {
+	mu       sync.Mutex
+	next     int
+	sessions map[int]*Presence
+}
+
+// NewRegistry creates an empty session registry.
+func NewRegistry() *Registry {
+	return &Registry{sessions: make(map[int]*Presence)}
+}
+
+// Register adds a newly connected SSH session. The caller removes it with
+// Remove when the connection closes.
+func (r *Registry) Register(user, remote string) *Presence {
+	if r == nil {
+		return nil
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	p := &Presence{
+		ID:         r.next,
+		User:       user,
+		Remote:     remote,
+		Since:      now,
+		state:      PresenceMenu,
+		lastActive: now,
+	}
+	r.sessions[p.ID] = p
+	return p
+}
+
+// Remove drops a session that has disconnected.
+func (r *Registry) Remove(p *Presence) {
+	if r == nil || p == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, p.ID)
+}
+
+// List returns a snapshot of every connected session, oldest first.
+func (r *Registry) List() []PresenceInfo {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	all := make([]*Presence, 0, len(r.sessions))
+	for _, p := range r.sessions {
+		all = append(all, p)
+	}
+	r.mu.Unlock()
+
+	now := time.Now()
+	out := make([]PresenceInfo, len(all))
+	for i, p := range all {
+		out[i] = p.Info(now)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Presence is one connected SSH session in the registry. Its TUI keeps it
+// up to date. A nil *Presence ignores updates.
+type Presence struct {
+	ID     int
+	User   string
+	Remote string
+	Since  time.Time
+
+	mu         sync.Mutex
+	state      string
+	site       string
+	device     string
+	call       *Call
+	lastActive time.Time
+}
+
+// PresenceInfo is a point-in-time view of a Presence.
+type PresenceInfo struct {
+	ID     int
+	User   string
+	Remote string
+	Since  time.Time
+	State  string
+	Site   string
+	Device string
+	// CallStarted is when the call came up; zero unless connected.
+	CallStarted time.Time
+	// Idle is the time since the user last pressed a key, in the menu or
+	// in the call.
+	Idle time.Duration
+}
+
+// Touch records user activity.
+func (p *Presence) Touch() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.lastActive = time.Now()
+	p.mu.Unlock()
+}
+
+// Set records what the session is doing. call is the connected call, if
+// any; its device and input time take precedence over device and Touch.
+func (p *Presence) Set(state, site, device string, call *Call) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state, p.site, p.device, p.call = state, site, device, call
+}
+
+// Info returns the session's state as of now.
+func (p *Presence) Info(now time.Time) PresenceInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := PresenceInfo{
+		ID:     p.ID,
+		User:   p.User,
+		Remote: p.Remote,
+		Since:  p.Since,
+		State:  p.state,
+		Site:   p.site,
+		Device: p.device,
+	}
+	active := p.lastActive
+	if p.call != nil {
+		info.Device = p.call.Device()
+		info.CallStarted = p.call.Started
+		if in := p.call.LastInput(); in.After(active) {
+			active = in
+		}
+	}
+	info.Idle = max(now.Sub(active), 0)
+	return info
+}
@@ -0,0 +1,57 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	alice := r.Register("alice", "10.0.0.1:5000")
+	bob := r.Register("bob", "10.0.0.2:5000")
+
+	list := r.List()
+	if len(list) != 2 || list[0].User != "alice" || list[1].User != "bob" {
+		t.Fatalf("List = %+v, want alice then bob", list)
+	}
+	if list[0].State != PresenceMenu {
+		t.Errorf("state = %q, want menu", list[0].State)
+	}
+
+	bob.Set(PresenceDialing, "router1", "", nil)
+	r.Remove(alice)
+	list = r.List()
+	if len(list) != 1 || list[0].State != PresenceDialing || list[0].Site != "router1" {
+		t.Errorf("List = %+v, want bob dialing router1", list)
+	}
+}
+
+func TestPresenceIdleAndCall(t *testing.T) {
+	p := NewRegistry().Register("alice", "")
+	now := p.Since.Add(time.Minute)
+	if idle := p.Info(now).Idle; idle != time.Minute {
+		t.Errorf("idle = %s, want 1m", idle)
+	}
+
+	_, call, _, _ := testCall(t)
+	p.Set(PresenceConnected, "site-a", "", call)
+	call.Write([]byte("x"))
+	info := p.Info(time.Now())
+	if info.Device != call.Device() || !info.CallStarted.Equal(call.Started) {
+		t.Errorf("info = %+v, want the call's device and start", info)
+	}
+	if info.Idle > time.Second {
+		t.Errorf("idle = %s, want typing in the call to count", info.Idle)
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	p := r.Register("alice", "")
+	p.Touch()
+	p.Set(PresenceMenu, "", "", nil)
+	r.Remove(p)
+	if r.List() != nil {
+		t.Error("nil registry should be empty")
+	}
+}
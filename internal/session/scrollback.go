@@ -0,0 +1,61 @@
+package session
+
+import "sync"
+
+// Scrollback is a fixed-size ring buffer holding the most recent output of
+// a call, so a reattaching terminal can see what happened while detached.
+type Scrollback struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+	full bool
+	pos  int
+}
+
+// NewScrollback creates a buffer that retains the last size bytes.
+func NewScrollback(size int) *Scrollback {
+	if size < 0 {
+		size = 0
+	}
+	return &Scrollback{buf: make([]byte, size), size: size}
+}
+
+// Write appends p, discarding the oldest bytes once the buffer is full.
+func (s *Scrollback) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(p)
+	if s.size == 0 {
+		return n, nil
+	}
+	if len(p) >= s.size {
+		copy(s.buf, p[len(p)-s.size:])
+		s.pos = 0
+		s.full = true
+		return n, nil
+	}
+	for len(p) > 0 {
+		c := copy(s.buf[s.pos:], p)
+		p = p[c:]
+		s.pos += c
+		if s.pos == s.size {
+			s.pos = 0
+			s.full = true
+		}
+	}
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered output, oldest first.
+func (s *Scrollback) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.full {
+		return append([]byte(nil), s.buf[:s.pos]...)
+	}
+	out := make([]byte, 0, s.size)
+	out = append(out, s.buf[s.pos:]...)
+	return append(out, s.buf[:s.pos]...)
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ticket is the change ticket and reason a user gave for dialing a site.
+type Ticket struct {
+	ID     string
+	Reason string
+}
+
+// Check validates t for a site whose tickets must match rule; see
+// config.Site.TicketRule. A nil rule accepts anything, including nothing.
+func (t Ticket) Check(rule *regexp.Regexp) error {
+	if rule == nil {
+		return nil
+	}
+	if !rule.MatchString(t.ID) {
+		return fmt.Errorf("ticket %q does not match %s", t.ID, rule)
+	}
+	if strings.TrimSpace(t.Reason) == "" {
+		return fmt.Errorf("a reason is required")
+	}
+	return nil
+}
+
+// headerDetails returns the ticket as session log header fields.
+func (t Ticket) headerDetails() []string {
+	var details []string
+	if t.ID != "" {
+		details = append(details, "Ticket", t.ID)
+	}
+	if t.Reason != "" {
+		details = append(details, "Reason", t.Reason)
+	}
+	return details
+}
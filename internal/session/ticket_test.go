@@ -0,0 +1,46 @@
+package session
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTicketCheck(t *testing.T) {
+	rule := regexp.MustCompile(`^CHG[0-9]{4}$`)
+	tests := []struct {
+		ticket Ticket
+		rule   *regexp.Regexp
+		ok     bool
+	}{
+		{Ticket{}, nil, true},
+		{Ticket{ID: "CHG1234", Reason: "replace PSU"}, rule, true},
+		{Ticket{ID: "CHG12", Reason: "replace PSU"}, rule, false},
+		{Ticket{ID: "CHG1234", Reason: "  "}, rule, false},
+	}
+	for _, tt := range tests {
+		if err := tt.ticket.Check(tt.rule); (err == nil) != tt.ok {
+			t.Errorf("%+v.Check(%v) = %v, want ok %v", tt.ticket, tt.rule, err, tt.ok)
+		}
+	}
+}
+
+func TestTicketInLogHeader(t *testing.T) {
+	dir := t.TempDir()
+	ticket := Ticket{ID: "CHG1234", Reason: "PSU swap | rack 4"}
+	l, err := NewLogger(dir, "router1", "/dev/ttyIAX0", append([]string{"User", "alice"}, ticket.headerDetails()...)...)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	l.Close()
+	data, err := os.ReadFile(l.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(data), "\n")
+	fields, ok := ParseLogHeader(header)
+	if !ok || fields["Ticket"] != "CHG1234" || fields["Reason"] != "PSU swap / rack 4" || fields["User"] != "alice" {
+		t.Errorf("header %q parsed as %v", header, fields)
+	}
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// watchBufferBytes bounds how much recent output a Watch keeps for matching.
+const watchBufferBytes = 4096
+
+// Watch collects a call's output so callers can wait for a prompt, e.g. to
+// pace pasted lines or drive a chat script.
+type Watch struct {
+	call   *Call
+	mu     sync.Mutex
+	buf    []byte
+	notify chan struct{}
+}
+
+// Watch starts collecting output from the call. Close it when done.
+func (c *Call) Watch() *Watch {
+	w := &Watch{call: c, notify: make(chan struct{}, 1)}
+	c.mu.Lock()
+	c.watches = append(c.watches, w)
+	c.mu.Unlock()
+	return w
+}
+
+// Close stops collecting output.
+func (w *Watch) Close() {
+	c := w.call
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.watches {
+		if other == w {
+			c.watches = append(c.watches[:i], c.watches[i+1:]...)
+			break
+		}
+	}
+}
+
+// Reset discards output collected so far, so the next Wait only matches
+// output that arrives afterwards.
+func (w *Watch) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = w.buf[:0]
+}
+
+// Output returns the output collected since the last Reset.
+func (w *Watch) Output() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
+
+// ErrWaitCancelled is returned by Wait when its cancel channel is closed.
+var ErrWaitCancelled = errors.New("wait cancelled")
+
+// Wait blocks until the collected output matches re, the timeout expires,
+// the call ends, or cancel is closed. A nil cancel never fires.
+func (w *Watch) Wait(re *regexp.Regexp, timeout time.Duration, cancel <-chan struct{}) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		w.mu.Lock()
+		matched := re.Match(w.buf)
+		w.mu.Unlock()
+		if matched {
+			return nil
+		}
+		select {
+		case <-w.notify:
+		case <-timer.C:
+			return fmt.Errorf("timed out after %s waiting for %q", timeout, re)
+		case <-w.call.Done():
+			return fmt.Errorf("call ended waiting for %q", re)
+		case <-cancel:
+			return ErrWaitCancelled
+		}
+	}
+}
+
+func (w *Watch) write(p []byte) {
+	w.mu.Lock()
+	w.buf = append(w.buf, p...)
+	if over := len(w.buf) - watchBufferBytes; over > 0 {
+		w.buf = append(w.buf[:0], w.buf[over:]...)
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
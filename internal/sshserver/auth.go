@@ -0,0 +1,150 @@
+package sshserver
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var errDenied = errors.New("permission denied")
+
+// Permissions extensions describing how a connection logged in. The auth
+// callbacks only set them; the login is recorded by loginMiddleware once
+// the handshake is over, since the public key callback also runs for
+// unsigned queries from anyone who knows the key.
+const (
+	extMethod = "oob-auth-method" // password, publickey or keyboard-interactive
+	extKey    = "oob-auth-key"    // fingerprint of the accepted public key
+	extTOTP   = "oob-auth-totp"   // "true" if a two-factor code was given
+)
+
+// withAuth sets the server's password and public key callbacks. They're set
+// directly on the SSH config rather than through wish's handlers, which can
+// only accept or refuse, so that users enrolled in two-factor login can be
+// sent on to a keyboard-interactive step asking for their code.
+func (s *Server) withAuth(srv *ssh.Server) error {
+	srv.ServerConfigCallback = func(ssh.Context) *gossh.ServerConfig {
+		return &gossh.ServerConfig{
+			PasswordCallback:  s.passwordCallback,
+			PublicKeyCallback: s.publicKeyCallback,
+		}
+	}
+	return nil
+}
+
+// passwordCallback validates credentials against the user store.
+func (s *Server) passwordCallback(conn gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+	username := conn.User()
+	ok, err := s.store.Authenticate(username, string(password))
+	if err != nil {
+		slog.Error("auth error", "user", username, "err", err)
+		return nil, errDenied
+	}
+	if !ok {
+		return nil, errDenied
+	}
+	return s.secondFactor(username, map[string]string{extMethod: "password"})
+}
+
+// publicKeyCallback accepts the user's authorized keys from the user store.
+func (s *Server) publicKeyCallback(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+	username := conn.User()
+	ok, err := s.store.AuthenticateKey(username, key)
+	if err != nil {
+		slog.Error("auth error", "user", username, "err", err)
+		return nil, errDenied
+	}
+	if !ok {
+		return nil, errDenied
+	}
+	return s.secondFactor(username, map[string]string{extMethod: "publickey", extKey: gossh.FingerprintSHA256(key)})
+}
+
+// secondFactor finishes a password or key login, or asks for a two-factor
+// code if the user is enrolled. ext describes the first factor.
+func (s *Server) secondFactor(username string, ext map[string]string) (*gossh.Permissions, error) {
+	if !s.totpEnabled(username) {
+		ext[extTOTP] = "false"
+		return &gossh.Permissions{Extensions: ext}, nil
+	}
+	return nil, &gossh.PartialSuccessError{Next: gossh.ServerAuthCallbacks{
+		KeyboardInteractiveCallback: func(conn gossh.ConnMetadata, challenge gossh.KeyboardInteractiveChallenge) (*gossh.Permissions, error) {
+			if !s.verifyCode(username, challenge) {
+				return nil, errDenied
+			}
+			ext[extTOTP] = "true"
+			return &gossh.Permissions{Extensions: ext}, nil
+		},
+	}}
+}
+
+// keyboardInteractiveAuth logs in clients that only offer
+// keyboard-interactive auth, asking for the password and then, if the user
+// is enrolled, a two-factor code.
+func (s *Server) keyboardInteractiveAuth(ctx ssh.Context, challenge gossh.KeyboardInteractiveChallenge) bool {
+	username := ctx.User()
+	answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+	if err != nil || len(answers) != 1 {
+		return false
+	}
+	ok, err := s.store.Authenticate(username, answers[0])
+	if err != nil {
+		slog.Error("auth error", "user", username, "err", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	enrolled := s.totpEnabled(username)
+	if enrolled && !s.verifyCode(username, challenge) {
+		return false
+	}
+	ctx.Permissions().Extensions = map[string]string{
+		extMethod: "keyboard-interactive",
+		extTOTP:   strconv.FormatBool(enrolled),
+	}
+	return true
+}
+
+// verifyCode asks for a two-factor code, which may also be a recovery code.
+func (s *Server) verifyCode(username string, challenge gossh.KeyboardInteractiveChallenge) bool {
+	answers, err := challenge("", "Two-factor authentication", []string{"Verification code: "}, []bool{true})
+	if err != nil || len(answers) != 1 {
+		return false
+	}
+	ok, err := s.store.VerifyTOTP(username, answers[0])
+	if err != nil {
+		slog.Error("two-factor check failed", "user", username, "err", err)
+		return false
+	}
+	if !ok {
+		slog.Warn("two-factor code rejected", "user", username)
+	}
+	return ok
+}
+
+func (s *Server) totpEnabled(username string) bool {
+	info, err := s.store.Get(username)
+	return err == nil && info.TOTPEnabled
+}
+
+// loginMiddleware records the login when a session starts: it updates the
+// user's last login and logs how they authenticated.
+func (s *Server) loginMiddleware(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		ext := sess.Context().Permissions().Extensions
+		attrs := []any{"user", sess.User(), "remote", sess.RemoteAddr(), "method", ext[extMethod]}
+		if key := ext[extKey]; key != "" {
+			attrs = append(attrs, "key", key)
+		}
+		attrs = append(attrs, "totp", ext[extTOTP] == "true")
+		if err := s.store.UpdateLastLogin(sess.User()); err != nil {
+			slog.Error("updating last login", "user", sess.User(), "err", err)
+		}
+		slog.Info("user authenticated", attrs...)
+		next(sess)
+	}
+}
@@ -0,0 +1,148 @@
+package sshserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/gbm-dev/pots/internal/auth"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// login runs an SSH handshake against the server's auth config and reports
+// whether the client got in.
+func login(t *testing.T, s *Server, user string, methods ...gossh.AuthMethod) bool {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv ssh.Server
+	s.withAuth(&srv)
+	cfg := srv.ServerConfigCallback(nil)
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if conn, _, _, err := gossh.NewServerConn(c, cfg); err == nil {
+			conn.Close()
+		}
+	}()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, _, _, err := gossh.NewClientConn(client, "hub", &gossh.ClientConfig{
+		User:            user,
+		Auth:            methods,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func answer(code string) gossh.AuthMethod {
+	return gossh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range answers {
+			answers[i] = code
+		}
+		return answers, nil
+	})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "secret123")
+	s := &Server{store: store}
+
+	if !login(t, s, "alice", gossh.Password("secret123")) {
+		t.Fatal("password login failed before enrolling")
+	}
+
+	secret, _ := store.BeginTOTP("alice")
+	code, _ := auth.TOTPCode(secret, time.Now().Add(-30*time.Second))
+	if _, err := store.ConfirmTOTP("alice", code); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+
+	if login(t, s, "alice", gossh.Password("secret123")) {
+		t.Error("password alone let an enrolled user in")
+	}
+	if login(t, s, "alice", gossh.Password("secret123"), answer("000000")) {
+		t.Error("wrong code accepted")
+	}
+	if login(t, s, "alice", gossh.Password("wrong"), answer(code)) {
+		t.Error("wrong password accepted")
+	}
+	code, _ = auth.TOTPCode(secret, time.Now())
+	if !login(t, s, "alice", gossh.Password("secret123"), answer(code)) {
+		t.Error("password and code refused")
+	}
+	if login(t, s, "alice", gossh.Password("secret123"), answer(code)) {
+		t.Error("code replayed")
+	}
+}
+
+// connMeta is the connection metadata auth callbacks see.
+type connMeta struct{ user string }
+
+func (c connMeta) User() string          { return c.user }
+func (c connMeta) SessionID() []byte     { return nil }
+func (c connMeta) ClientVersion() []byte { return nil }
+func (c connMeta) ServerVersion() []byte { return nil }
+func (c connMeta) RemoteAddr() net.Addr  { return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5000} }
+func (c connMeta) LocalAddr() net.Addr   { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2222} }
+
+func TestPublicKeyQueryDoesNotRecordLogin(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "secret123")
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddKey("alice", string(gossh.MarshalAuthorizedKey(key))); err != nil {
+		t.Fatalf("AddKey: %v", err)
+	}
+	s := &Server{store: store}
+
+	// The callback runs for a client merely asking whether the key would
+	// do, before any signature: it must not count as a login.
+	perms, err := s.publicKeyCallback(connMeta{"alice"}, key)
+	if err != nil {
+		t.Fatalf("publicKeyCallback: %v", err)
+	}
+	if info, _ := store.Get("alice"); !info.LastLogin.IsZero() {
+		t.Error("last login updated by the auth callback")
+	}
+	if got := perms.Extensions[extMethod]; got != "publickey" {
+		t.Errorf("auth method = %q, want publickey", got)
+	}
+	if got := perms.Extensions[extKey]; got != gossh.FingerprintSHA256(key) {
+		t.Errorf("key = %q, want the fingerprint", got)
+	}
+}
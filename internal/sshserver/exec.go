@@ -0,0 +1,423 @@
+package sshserver
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+	"github.com/gbm-dev/pots/internal/tui"
+)
+
+const execUsage = `Commands:
+  connect <site> [--ticket ID --reason TEXT]
+                      Dial a site and attach to it, skipping the menu (needs ssh -t)
+  run <site> [--prompt REGEX] [--timeout D] [--ticket ID --reason TEXT] < script
+                      Dial a site, send each script line at the prompt, print the output
+  sites [--json]      List the sites you can see, whether they are in use and your role
+  status [--json]     Show modem, trunk and call status
+  sessions [--json]   List users connected to the hub
+`
+
+// execMiddleware runs a command given on the ssh command line, such as
+// "ssh hub sites --json", instead of the menu. connect is checked here and
+// then handed to the TUI; run is handled by runScript.
+func (s *Server) execMiddleware(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		args := sess.Command()
+		if len(args) == 0 {
+			next(sess)
+			return
+		}
+		if args[0] == "connect" {
+			if err := s.checkConnect(sess); err != nil {
+				fmt.Fprintf(sess.Stderr(), "connect: %v\n", err)
+				sess.Exit(1)
+				return
+			}
+			next(sess)
+			return
+		}
+		if args[0] == "run" {
+			sess.Exit(s.runScript(sess.Context(), sess.User(), sess.RemoteAddr().String(), args[1:], sess, sess, sess.Stderr()))
+			return
+		}
+		sess.Exit(s.runCommand(sess.User(), args, sess, sess.Stderr()))
+	}
+}
+
+// checkConnect validates "connect <site>" before the TUI takes over.
+func (s *Server) checkConnect(sess ssh.Session) error {
+	i, _, err := s.parseConnect(sess.Command())
+	if err != nil {
+		return err
+	}
+	if err := s.checkDial(sess.User(), s.deps.Sites[i]); err != nil {
+		return err
+	}
+	if _, _, ok := sess.Pty(); !ok {
+		return fmt.Errorf("needs a terminal; use ssh -t")
+	}
+	if force, _ := s.store.MustChangePassword(sess.User()); force {
+		return fmt.Errorf("password change required; log in without a command first")
+	}
+	return nil
+}
+
+// parseConnect parses "connect <site> [--ticket ID] [--reason TEXT]" and
+// checks the ticket against the site's ticket rule.
+func (s *Server) parseConnect(args []string) (int, session.Ticket, error) {
+	var ticket session.Ticket
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		return 0, ticket, fmt.Errorf("usage: connect <site> [--ticket ID --reason TEXT]")
+	}
+	i, ok := s.site(args[1])
+	if !ok {
+		return 0, ticket, fmt.Errorf("unknown site %q", args[1])
+	}
+	fs := flag.NewFlagSet("connect", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	ticketFlags(fs, &ticket)
+	if err := fs.Parse(args[2:]); err != nil {
+		return 0, ticket, err
+	}
+	if fs.NArg() > 0 {
+		return 0, ticket, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := s.checkTicket(s.deps.Sites[i], ticket); err != nil {
+		return 0, ticket, err
+	}
+	return i, ticket, nil
+}
+
+// ticketFlags adds --ticket and --reason to fs.
+func ticketFlags(fs *flag.FlagSet, ticket *session.Ticket) {
+	fs.StringVar(&ticket.ID, "ticket", "", "change ticket ID")
+	fs.StringVar(&ticket.Reason, "reason", "", "reason for dialing")
+}
+
+// checkTicket checks a ticket given on the command line for site.
+func (s *Server) checkTicket(site config.Site, ticket session.Ticket) error {
+	if err := ticket.Check(site.TicketRule(s.deps.TicketPattern)); err != nil {
+		return fmt.Errorf("%s needs a change ticket: %w; pass --ticket and --reason", site.Name, err)
+	}
+	return nil
+}
+
+// checkDial checks user may dial site. Sites the user can't see are
+// reported as unknown.
+func (s *Server) checkDial(user string, site config.Site) error {
+	switch role := s.access(user).Role(site); {
+	case role == auth.RoleNone:
+		return fmt.Errorf("unknown site %q", site.Name)
+	case role < auth.RoleOperator:
+		slog.Warn("dial denied", "user", user, "site", site.Name)
+		if err := s.deps.Audit.Record(session.AuditEvent{Event: "dial-denied", User: user, Site: site.Name}); err != nil {
+			slog.Error("recording audit event", "event", "dial-denied", "err", err)
+		}
+		return fmt.Errorf("you don't have operator access to %s", site.Name)
+	}
+	return nil
+}
+
+// access returns user's role on each site, hiding every site if it can't
+// be read.
+func (s *Server) access(user string) auth.Access {
+	a, err := s.store.Access(user)
+	if err != nil {
+		slog.Error("loading site access", "user", user, "err", err)
+		return auth.NoAccess
+	}
+	return a
+}
+
+// visible reports whether a user with access can see the named site.
+func (s *Server) visible(access auth.Access, name string) bool {
+	site := config.Site{Name: name}
+	if i, ok := s.site(name); ok {
+		site = s.deps.Sites[i]
+	}
+	return access.Role(site) > auth.RoleNone
+}
+
+// site looks up a configured site by name.
+func (s *Server) site(name string) (int, bool) {
+	i := slices.IndexFunc(s.deps.Sites, func(site config.Site) bool { return site.Name == name })
+	return i, i >= 0
+}
+
+// runCommand runs one non-interactive command for user and returns its exit
+// status. Sites the user can't see are left out of the output, and users
+// who must change their password get nothing until they have.
+func (s *Server) runCommand(user string, args []string, stdout, stderr io.Writer) int {
+	name, flags := args[0], args[1:]
+	if force, _ := s.store.MustChangePassword(user); force {
+		fmt.Fprintf(stderr, "%s: password change required; log in without a command first\n", name)
+		return 1
+	}
+	access := s.access(user)
+	asJSON := slices.Contains(flags, "--json")
+	flags = slices.DeleteFunc(flags, func(f string) bool { return f == "--json" })
+
+	var out any
+	var text func(io.Writer)
+	switch name {
+	case "sites":
+		sites := s.sitesStatus(access)
+		out, text = sites, func(w io.Writer) { writeSites(w, sites) }
+	case "status":
+		st := s.hubStatus(access)
+		out, text = st, func(w io.Writer) { writeStatus(w, st) }
+	case "sessions":
+		sessions := s.sessionsStatus(access)
+		out, text = sessions, func(w io.Writer) { writeSessions(w, sessions) }
+	case "help":
+		fmt.Fprint(stdout, execUsage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command: %s\n%s", name, execUsage)
+		return 2
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(stderr, "%s: unexpected arguments: %s\n", name, strings.Join(flags, " "))
+		return 2
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			return 1
+		}
+		return 0
+	}
+	text(stdout)
+	return 0
+}
+
+// siteStatus is one site in "sites --json".
+type siteStatus struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Group       string     `json:"group,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	InUse       bool       `json:"in_use"`
+	SuccessRate *int       `json:"success_rate,omitempty"` // percent of recent dials; absent if never dialed
+	LastContact *time.Time `json:"last_contact,omitempty"`
+	Unreachable bool       `json:"unreachable,omitempty"` // failing reachability sweeps
+	Access      string     `json:"access"`                // viewer, operator or admin
+}
+
+func (s *Server) sitesStatus(access auth.Access) []siteStatus {
+	active := s.deps.Lock.ActiveSites()
+	out := make([]siteStatus, 0, len(s.deps.Sites))
+	for _, site := range s.deps.Sites {
+		role := access.Role(site)
+		if role == auth.RoleNone {
+			continue
+		}
+		st := siteStatus{
+			Name:        site.Name,
+			Description: site.Description,
+			Group:       site.Group,
+			Tags:        site.Tags,
+			InUse:       slices.Contains(active, site.Name),
+			Unreachable: s.deps.Sweeps.Alerting(site.Name),
+			Access:      role.String(),
+		}
+		stats := s.deps.Calls.Dials().Stats(site.Name)
+		if rate := stats.SuccessRate(); rate >= 0 {
+			st.SuccessRate = &rate
+		}
+		if !stats.LastContact.IsZero() {
+			st.LastContact = &stats.LastContact
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func writeSites(w io.Writer, sites []siteStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SITE\tGROUP\tSTATE\tSUCCESS\tACCESS\tDESCRIPTION")
+	for _, s := range sites {
+		state := "idle"
+		switch {
+		case s.InUse:
+			state = "in use"
+		case s.Unreachable:
+			state = "unreachable"
+		}
+		rate := "-"
+		if s.SuccessRate != nil {
+			rate = fmt.Sprintf("%d%%", *s.SuccessRate)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Group, state, rate, s.Access, s.Description)
+	}
+	tw.Flush()
+}
+
+// hubStatus is the output of "status --json".
+type hubStatus struct {
+	Slmodemd bool           `json:"slmodemd"`
+	DModem   bool           `json:"d_modem"`
+	Trunk    bool           `json:"trunk_registered"`
+	Devices  []deviceStatus `json:"devices"`
+	Queued   []string       `json:"queued"` // sites waiting for a free modem, first in line first
+	Calls    []callStatus   `json:"calls"`
+	Users    int            `json:"users"`
+}
+
+type deviceStatus struct {
+	Device string `json:"device"`
+	Site   string `json:"site,omitempty"` // empty when idle, hiddenSite for sites the user can't see
+}
+
+// hiddenSite stands in for the name of a site the user can't see.
+const hiddenSite = "(restricted)"
+
+type callStatus struct {
+	ID            string     `json:"id"`
+	Site          string     `json:"site"`
+	Owner         string     `json:"owner"`
+	Device        string     `json:"device"`
+	Started       time.Time  `json:"started"`
+	AttachedBy    string     `json:"attached_by,omitempty"`
+	DetachedUntil *time.Time `json:"detached_until,omitempty"`
+}
+
+func (s *Server) hubStatus(access auth.Access) hubStatus {
+	health := s.health()
+	st := hubStatus{
+		Slmodemd: health.ModemReady,
+		DModem:   health.DModemReady,
+		Trunk:    health.Status == tui.SIPRegistered,
+		Devices:  []deviceStatus{},
+		Queued:   []string{},
+		Calls:    []callStatus{},
+		Users:    len(s.deps.Sessions.List()),
+	}
+	for _, site := range s.deps.Lock.Waiting() {
+		if s.visible(access, site) {
+			st.Queued = append(st.Queued, site)
+		}
+	}
+	holders := s.deps.Lock.Holders()
+	for _, dev := range s.deps.Lock.Devices() {
+		site := holders[dev]
+		if site != "" && !s.visible(access, site) {
+			site = hiddenSite
+		}
+		st.Devices = append(st.Devices, deviceStatus{Device: dev, Site: site})
+	}
+	for _, c := range s.deps.Calls.Calls() {
+		if !s.visible(access, c.Site) {
+			continue
+		}
+		cs := callStatus{
+			ID:         c.ID,
+			Site:       c.Site,
+			Owner:      c.Owner,
+			Device:     c.Device(),
+			Started:    c.Started,
+			AttachedBy: c.AttachedBy(),
+		}
+		if until := c.DetachedUntil(); !until.IsZero() {
+			cs.DetachedUntil = &until
+		}
+		st.Calls = append(st.Calls, cs)
+	}
+	return st
+}
+
+func writeStatus(w io.Writer, st hubStatus) {
+	up := func(ok bool) string {
+		if ok {
+			return "up"
+		}
+		return "DOWN"
+	}
+	fmt.Fprintf(w, "slmodemd: %s  d-modem: %s  trunk: %s  users: %d\n\n", up(st.Slmodemd), up(st.DModem), up(st.Trunk), st.Users)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tSITE")
+	for _, d := range st.Devices {
+		site := d.Site
+		if site == "" {
+			site = "idle"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", d.Device, site)
+	}
+	tw.Flush()
+	if len(st.Queued) > 0 {
+		fmt.Fprintf(w, "\nwaiting for a modem: %s\n", strings.Join(st.Queued, ", "))
+	}
+	if len(st.Calls) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CALL\tSITE\tOWNER\tDEVICE\tUP\tATTACHED")
+	now := time.Now()
+	for _, c := range st.Calls {
+		attached := c.AttachedBy
+		if c.DetachedUntil != nil {
+			attached = "detached"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Site, c.Owner, c.Device, now.Sub(c.Started).Round(time.Second), attached)
+	}
+	tw.Flush()
+}
+
+// sessionStatus is one connected user in "sessions --json".
+type sessionStatus struct {
+	User        string     `json:"user"`
+	Remote      string     `json:"remote"`
+	Since       time.Time  `json:"since"`
+	State       string     `json:"state"`
+	Site        string     `json:"site,omitempty"`
+	Device      string     `json:"device,omitempty"`
+	CallStarted *time.Time `json:"call_started,omitempty"`
+	IdleSeconds int        `json:"idle_seconds"`
+}
+
+func (s *Server) sessionsStatus(access auth.Access) []sessionStatus {
+	out := []sessionStatus{}
+	for _, p := range s.deps.Sessions.List() {
+		st := sessionStatus{
+			User:        p.User,
+			Remote:      p.Remote,
+			Since:       p.Since,
+			State:       p.State,
+			Site:        p.Site,
+			Device:      p.Device,
+			IdleSeconds: int(p.Idle.Seconds()),
+		}
+		if st.Site != "" && !s.visible(access, st.Site) {
+			st.Site = hiddenSite
+		}
+		if !p.CallStarted.IsZero() {
+			st.CallStarted = &p.CallStarted
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func writeSessions(w io.Writer, sessions []sessionStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tFROM\tSTATE\tSITE\tDEVICE\tIDLE")
+	for _, p := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.User, p.Remote, p.State, p.Site, p.Device, time.Duration(p.IdleSeconds)*time.Second)
+	}
+	tw.Flush()
+}
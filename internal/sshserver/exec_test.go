@@ -0,0 +1,241 @@
+package sshserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+	"github.com/gbm-dev/pots/internal/tui"
+)
+
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	devs := []string{filepath.Join(dir, "ttyIAX0"), filepath.Join(dir, "ttyIAX1")}
+	for _, d := range devs {
+		os.WriteFile(d, nil, 0600)
+	}
+	dials, err := session.OpenDialLog(filepath.Join(dir, "dials.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dials.Record(session.DialRecord{Site: "router1", User: "alice", Result: "CONNECT"})
+	dials.Record(session.DialRecord{Site: "router1", User: "alice", Result: "BUSY"})
+
+	lock := modem.NewDeviceLock(devs...)
+	lock.Acquire("switch1")
+	sessions := session.NewRegistry()
+	sessions.Register("alice", "10.0.0.1:5000").Set(session.PresenceDialing, "switch1", "", nil)
+	store, err := auth.NewFileStore(filepath.Join(dir, "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"alice", "bob"} {
+		store.Add(u, "secret123")
+		store.SetPassword(u, "secret123") // past the first-login change
+	}
+
+	return &Server{
+		store: store,
+		deps: tui.Deps{
+			Sites: []config.Site{
+				{Name: "router1", Description: "Core router", Group: "NYC", Tags: []string{"nyc"}},
+				{Name: "switch1", Description: "Access switch"},
+			},
+			Lock:     lock,
+			Calls:    session.NewManager(dir, 0, 1024, 0, dials),
+			Sessions: sessions,
+			Store:    store,
+		},
+		health: func() tui.SIPInfo { return tui.SIPInfo{Status: tui.SIPRegistered, ModemReady: true} },
+	}
+}
+
+func run(s *Server, args ...string) (stdout, stderr string, code int) {
+	return runAs(s, "alice", args...)
+}
+
+func runAs(s *Server, user string, args ...string) (stdout, stderr string, code int) {
+	var out, errOut bytes.Buffer
+	code = s.runCommand(user, args, &out, &errOut)
+	return out.String(), errOut.String(), code
+}
+
+func TestExecSitesJSON(t *testing.T) {
+	out, _, code := run(testServer(t), "sites", "--json")
+	if code != 0 {
+		t.Fatalf("exit %d", code)
+	}
+	var sites []siteStatus
+	if err := json.Unmarshal([]byte(out), &sites); err != nil {
+		t.Fatalf("bad JSON %q: %v", out, err)
+	}
+	if len(sites) != 2 || sites[0].Name != "router1" || sites[0].SuccessRate == nil || *sites[0].SuccessRate != 50 {
+		t.Errorf("sites = %+v", sites)
+	}
+	if sites[0].InUse || !sites[1].InUse || sites[1].SuccessRate != nil {
+		t.Errorf("sites = %+v, want switch1 in use and never dialed", sites)
+	}
+}
+
+func TestExecStatusAndSessions(t *testing.T) {
+	s := testServer(t)
+	out, _, code := run(s, "status", "--json")
+	if code != 0 {
+		t.Fatalf("exit %d", code)
+	}
+	var st hubStatus
+	if err := json.Unmarshal([]byte(out), &st); err != nil {
+		t.Fatalf("bad JSON %q: %v", out, err)
+	}
+	if !st.Trunk || !st.Slmodemd || st.DModem || st.Users != 1 || len(st.Devices) != 2 || st.Devices[0].Site != "switch1" || st.Devices[1].Site != "" {
+		t.Errorf("status = %+v", st)
+	}
+
+	out, _, _ = run(s, "status")
+	if !strings.Contains(out, "trunk: up") || !strings.Contains(out, "idle") {
+		t.Errorf("status text:\n%s", out)
+	}
+
+	out, _, code = run(s, "sessions")
+	if code != 0 || !strings.Contains(out, "alice") || !strings.Contains(out, "dialing") {
+		t.Errorf("sessions text (exit %d):\n%s", code, out)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	s := testServer(t)
+	if _, errOut, code := run(s, "reboot"); code != 2 || !strings.Contains(errOut, "unknown command") {
+		t.Errorf("unknown command: exit %d, stderr %q", code, errOut)
+	}
+	if _, _, code := run(s, "sites", "--yaml"); code != 2 {
+		t.Errorf("bad flag: exit %d, want 2", code)
+	}
+}
+
+func TestExecPasswordChangeRequired(t *testing.T) {
+	s := testServer(t)
+	s.store.Reset("alice", "temporary1")
+	for _, cmd := range []string{"sites", "status", "sessions", "help"} {
+		out, errOut, code := run(s, cmd)
+		if code != 1 || out != "" || !strings.Contains(errOut, "password change required") {
+			t.Errorf("%s: exit %d, stdout %q, stderr %q; want refused", cmd, code, out, errOut)
+		}
+	}
+	var errOut bytes.Buffer
+	code := s.runScript(t.Context(), "alice", "10.0.0.1:5000", []string{"router1", "--prompt", "#"}, strings.NewReader("show version\n"), io.Discard, &errOut)
+	if code != 1 || !strings.Contains(errOut.String(), "password change required") {
+		t.Errorf("run: exit %d, stderr %q; want refused", code, errOut.String())
+	}
+}
+
+func TestExecRunErrors(t *testing.T) {
+	s := testServer(t)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "usage: run"},
+		{[]string{"nowhere"}, "unknown site"},
+		{[]string{"router1"}, "has no prompt option"},
+		{[]string{"router1", "--prompt", "("}, "bad --prompt"},
+		{[]string{"router1", "--prompt", "#", "extra"}, "unexpected arguments"},
+	}
+	for _, tt := range tests {
+		var errOut bytes.Buffer
+		code := s.runScript(t.Context(), "alice", "10.0.0.1:5000", tt.args, strings.NewReader(""), io.Discard, &errOut)
+		if code != 2 || !strings.Contains(errOut.String(), tt.want) {
+			t.Errorf("run %q: exit %d, stderr %q; want 2 and %q", tt.args, code, errOut.String(), tt.want)
+		}
+	}
+}
+
+func TestReadScript(t *testing.T) {
+	lines, err := readScript(strings.NewReader("show version\r\n\nshow ip int brief"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"show version", "", "show ip int brief"}; !slices.Equal(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestParseConnectTicket(t *testing.T) {
+	s := testServer(t)
+	s.deps.TicketPattern = regexp.MustCompile(`^CHG[0-9]+$`)
+
+	i, ticket, err := s.parseConnect([]string{"connect", "switch1", "--ticket", "CHG42", "--reason", "replace PSU"})
+	if err != nil || i != 1 || ticket != (session.Ticket{ID: "CHG42", Reason: "replace PSU"}) {
+		t.Errorf("parseConnect = %d, %+v, %v", i, ticket, err)
+	}
+	for _, args := range [][]string{
+		{"connect", "switch1"},
+		{"connect", "switch1", "--ticket", "INC1", "--reason", "x"},
+		{"connect", "switch1", "--ticket", "CHG42"},
+		{"connect", "switch1", "--bogus"},
+		{"connect", "nowhere"},
+		{"connect"},
+	} {
+		if _, _, err := s.parseConnect(args); err == nil {
+			t.Errorf("parseConnect(%q): want error", args)
+		}
+	}
+
+	s.deps.TicketPattern = nil
+	if _, _, err := s.parseConnect([]string{"connect", "switch1"}); err != nil {
+		t.Errorf("no pattern: %v", err)
+	}
+}
+
+func TestExecSiteAccess(t *testing.T) {
+	s := testServer(t)
+	s.store.AddGrant(auth.Grant{Subject: "bob", Target: "tag:nyc", Role: auth.RoleViewer})
+	s.store.SetAccessEnforced(true)
+
+	out, _, _ := runAs(s, "bob", "sites", "--json")
+	var sites []siteStatus
+	if err := json.Unmarshal([]byte(out), &sites); err != nil {
+		t.Fatalf("bad JSON %q: %v", out, err)
+	}
+	if len(sites) != 1 || sites[0].Name != "router1" || sites[0].Access != "viewer" {
+		t.Errorf("bob's sites = %+v, want router1 as viewer", sites)
+	}
+
+	// switch1 is in use but bob can't see it.
+	out, _, _ = runAs(s, "bob", "status", "--json")
+	var st hubStatus
+	if err := json.Unmarshal([]byte(out), &st); err != nil {
+		t.Fatalf("bad JSON %q: %v", out, err)
+	}
+	if st.Devices[0].Site != hiddenSite {
+		t.Errorf("devices = %+v, want switch1 hidden", st.Devices)
+	}
+	if out, _, _ = runAs(s, "bob", "sessions"); strings.Contains(out, "switch1") {
+		t.Errorf("sessions show a hidden site:\n%s", out)
+	}
+
+	for _, tt := range []struct{ site, want string }{
+		{"router1", "operator access"},
+		{"switch1", "unknown site"},
+	} {
+		var errOut bytes.Buffer
+		code := s.runScript(t.Context(), "bob", "10.0.0.2:5000", []string{tt.site, "--prompt", "#"}, strings.NewReader(""), io.Discard, &errOut)
+		if code != 2 || !strings.Contains(errOut.String(), tt.want) {
+			t.Errorf("run %s: exit %d, stderr %q; want %q", tt.site, code, errOut.String(), tt.want)
+		}
+		i, _ := s.site(tt.site)
+		if err := s.checkDial("bob", s.deps.Sites[i]); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkDial %s = %v, want %q", tt.site, err, tt.want)
+		}
+	}
+}
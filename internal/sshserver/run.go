@@ -0,0 +1,171 @@
+package sshserver
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+	"github.com/gbm-dev/pots/internal/tui"
+)
+
+// Exit statuses for "run" beyond the usual 0, 1 (error) and 2 (usage).
+const (
+	exitDialFailed   = 3 // not approved, no free modem, or the site did not answer
+	exitScriptFailed = 4 // login or a prompt timed out, or the call dropped
+)
+
+// recordDial adds a run's dial that didn't connect to the dial history.
+func (s *Server) recordDial(user, site, dev string, ticket session.Ticket, result modem.DialResult, attempts int) {
+	err := s.deps.Calls.Dials().Record(session.DialRecord{
+		Site:     site,
+		User:     user,
+		Device:   dev,
+		Result:   result.String(),
+		Attempts: attempts,
+		Ticket:   ticket.ID,
+		Reason:   ticket.Reason,
+	})
+	if err != nil {
+		slog.Error("recording dial history", "site", site, "err", err)
+	}
+}
+
+// runScript implements "run <site> [--prompt REGEX] [--timeout D]
+// [--ticket ID --reason TEXT]": dial the site, send each line read from
+// stdin once the device shows its prompt, stream the device's output to
+// stdout and hang up. Progress and errors go to stderr. The call is hung up
+// if ctx ends first.
+func (s *Server) runScript(ctx context.Context, user, remote string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(stderr, "usage: run <site> [--prompt REGEX] [--timeout DURATION] [--ticket ID --reason TEXT] < script")
+		return 2
+	}
+	i, ok := s.site(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "run: unknown site %q\n", args[0])
+		return 2
+	}
+	site := s.deps.Sites[i]
+	if err := s.checkDial(user, site); err != nil {
+		fmt.Fprintf(stderr, "run: %v\n", err)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	promptFlag := fs.String("prompt", "", "regex matching the device prompt (default: the site's prompt option)")
+	timeout := fs.Duration("timeout", site.PromptWait(), "how long to wait for each prompt")
+	var ticket session.Ticket
+	ticketFlags(fs, &ticket)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "run: unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return 2
+	}
+	prompt := site.Prompt
+	if *promptFlag != "" {
+		re, err := regexp.Compile(*promptFlag)
+		if err != nil {
+			fmt.Fprintf(stderr, "run: bad --prompt: %v\n", err)
+			return 2
+		}
+		prompt = re
+	}
+	if prompt == nil {
+		fmt.Fprintf(stderr, "run: site %s has no prompt option; pass --prompt\n", site.Name)
+		return 2
+	}
+	if err := s.checkTicket(site, ticket); err != nil {
+		fmt.Fprintf(stderr, "run: %v\n", err)
+		return 2
+	}
+
+	if force, _ := s.store.MustChangePassword(user); force {
+		fmt.Fprintln(stderr, "run: password change required; log in without a command first")
+		return 1
+	}
+	lines, err := readScript(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "run: reading script: %v\n", err)
+		return 1
+	}
+
+	presence := s.deps.Sessions.Register(user, remote)
+	defer s.deps.Sessions.Remove(presence)
+	presence.Set(session.PresenceDialing, site.Name, "", nil)
+
+	progress := func(msg string) { fmt.Fprintf(stderr, "%s: %s\n", site.Name, msg) }
+	if site.Protected {
+		progress("protected site; waiting for a second person to approve")
+		req := s.deps.Approvals.Request(ctx, user, site.Name, ticket)
+		if err := req.Err(); err != nil {
+			fmt.Fprintf(stderr, "run: not approved: %v\n", err)
+			return exitDialFailed
+		}
+		progress("approved by " + req.Approver())
+	}
+
+	dev, err := s.deps.Lock.Acquire(site.Name)
+	if err != nil {
+		fmt.Fprintf(stderr, "run: modem busy: %v\n", err)
+		return exitDialFailed
+	}
+	mdm, resp, err := session.DialSite(site, dev, progress)
+	if err != nil {
+		s.deps.Lock.Release(dev)
+		s.recordDial(user, site.Name, dev, ticket, modem.ResultError, max(resp.Attempts, 1))
+		fmt.Fprintf(stderr, "run: dial: %v\n", err)
+		return exitDialFailed
+	}
+	if resp.Result != modem.ResultConnect {
+		s.deps.Lock.Release(dev)
+		s.recordDial(user, site.Name, dev, ticket, resp.Result, resp.Attempts)
+		fmt.Fprintf(stderr, "run: dial %s: %s\n", site.Name, resp.Result)
+		return exitDialFailed
+	}
+
+	call, err := s.deps.Calls.Start(user, ticket, tui.DialPriority(s.store, user, site), site, dev, mdm, s.deps.Lock, resp.Attempts)
+	if err != nil {
+		fmt.Fprintf(stderr, "run: %v\n", err)
+		return 1
+	}
+	if err := s.deps.Audit.Record(session.AuditEvent{Event: "dial", User: user, Site: site.Name, Ticket: ticket.ID, Reason: ticket.Reason}); err != nil {
+		slog.Error("recording audit event", "event", "dial", "err", err)
+	}
+	defer call.Hangup("batch run finished")
+	go func() {
+		select {
+		case <-ctx.Done():
+			call.Hangup("client disconnected")
+		case <-call.Done():
+		}
+	}()
+	presence.Set(session.PresenceConnected, site.Name, dev, call)
+	progress(fmt.Sprintf("connected on %s", dev))
+
+	if err := session.RunBatch(call, user, site, prompt, *timeout, lines, stdout); err != nil {
+		fmt.Fprintf(stderr, "\nrun: %v\n", err)
+		return exitScriptFailed
+	}
+	return 0
+}
+
+// readScript reads the lines of a batch script, dropping CRs so scripts
+// saved with DOS line endings send the same commands.
+func readScript(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimRight(sc.Text(), "\r"))
+	}
+	return lines, sc.Err()
+}
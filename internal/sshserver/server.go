@@ -12,27 +12,35 @@ import (
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/gbm-dev/pots/internal/auth"
-	"github.com/gbm-dev/pots/internal/config"
-	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
 	"github.com/gbm-dev/pots/internal/tui"
 )
 
 // Server wraps the Wish SSH server.
 type Server struct {
-	srv    *ssh.Server
-	store  auth.UserStore
-	lock   *modem.DeviceLock
-	sites  []config.Site
-	logDir string
+	srv   *ssh.Server
+	deps  tui.Deps
+	store auth.UserStore
+
+	// health checks the modem and trunk for the status command.
+	health func() tui.SIPInfo
 }
 
-// New creates a new SSH server.
-func New(cfg config.AppConfig, store auth.UserStore, lock *modem.DeviceLock, sites []config.Site) (*Server, error) {
+// New creates a new SSH server serving the TUI with the hub-wide deps.
+// The server keeps deps.Sessions up to date, creating it and
+// deps.Approvals if unset.
+func New(deps tui.Deps) (*Server, error) {
+	cfg := deps.Config
+	if deps.Sessions == nil {
+		deps.Sessions = session.NewRegistry()
+	}
+	if deps.Approvals == nil {
+		deps.Approvals = session.NewApprovals(deps.Audit)
+	}
 	s := &Server{
-		store:  store,
-		lock:   lock,
-		sites:  sites,
-		logDir: cfg.LogDir,
+		deps:   deps,
+		store:  deps.Store,
+		health: tui.CheckHealth,
 	}
 
 	// Ensure host key directory exists
@@ -45,9 +53,13 @@ func New(cfg config.AppConfig, store auth.UserStore, lock *modem.DeviceLock, sit
 	srv, err := wish.NewServer(
 		wish.WithAddress(fmt.Sprintf("%s:%d", cfg.SSHAddress, cfg.SSHPort)),
 		wish.WithHostKeyPath(hostKeyPath),
-		wish.WithPasswordAuth(s.passwordAuth),
+		s.withAuth,
+		wish.WithKeyboardInteractiveAuth(s.keyboardInteractiveAuth),
 		wish.WithMiddleware(
 			bubbletea.Middleware(s.teaHandler),
+			windowMiddleware,
+			s.execMiddleware,
+			s.loginMiddleware, // runs first: the last middleware is outermost
 		),
 	)
 	if err != nil {
@@ -69,21 +81,6 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.srv.Shutdown(ctx)
 }
 
-// passwordAuth validates credentials against the user store.
-func (s *Server) passwordAuth(ctx ssh.Context, password string) bool {
-	username := ctx.User()
-	ok, err := s.store.Authenticate(username, password)
-	if err != nil {
-		slog.Error("auth error", "user", username, "err", err)
-		return false
-	}
-	if ok {
-		s.store.UpdateLastLogin(username)
-		slog.Info("user authenticated", "user", username, "remote", ctx.RemoteAddr())
-	}
-	return ok
-}
-
 // teaHandler creates a Bubble Tea program for each SSH session.
 func (s *Server) teaHandler(sshSession ssh.Session) (tea.Model, []tea.ProgramOption) {
 	username := sshSession.User()
@@ -94,8 +91,22 @@ func (s *Server) teaHandler(sshSession ssh.Session) (tea.Model, []tea.ProgramOpt
 		forceChange = false
 	}
 
+	presence := s.deps.Sessions.Register(username, sshSession.RemoteAddr().String())
+	go func() {
+		<-sshSession.Context().Done()
+		s.deps.Sessions.Remove(presence)
+	}()
+
 	renderer := bubbletea.MakeRenderer(sshSession)
-	model := tui.New(username, s.sites, s.lock, s.store, s.logDir, forceChange, renderer)
+	model := tui.New(sshSession.Context(), username, s.deps, presence, forceChange, renderer)
+	if ws, ok := sshSession.(*windowSession); ok {
+		model = model.WithTerminalSize(ws.Size)
+	}
+	if args := sshSession.Command(); len(args) > 0 && args[0] == "connect" {
+		// Checked by execMiddleware.
+		i, ticket, _ := s.parseConnect(args)
+		model = model.Connect(i, ticket)
+	}
 
 	return model, []tea.ProgramOption{tea.WithAltScreen()}
 }
@@ -0,0 +1,58 @@
+package sshserver
+
+import (
+	"sync/atomic"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// windowSession tracks the client's window size for a session. Bubble Tea
+// only hears of window changes between tea.Exec commands, so a call that
+// has the screen reads the size from here instead.
+type windowSession struct {
+	ssh.Session
+	winch chan ssh.Window // the latest change, for the Bubble Tea program
+	size  atomic.Pointer[ssh.Window]
+}
+
+// Pty returns the session's PTY with window changes coming from
+// windowSession rather than from the SSH session.
+func (s *windowSession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	pty, _, ok := s.Session.Pty()
+	if w := s.size.Load(); w != nil {
+		pty.Window = *w
+	}
+	return pty, s.winch, ok
+}
+
+// Size returns the client's current window size.
+func (s *windowSession) Size() (width, height int) {
+	w := s.size.Load()
+	return w.Width, w.Height
+}
+
+// windowMiddleware records window changes as they arrive. Only the latest
+// change is passed on, so the client's requests are not held up while the
+// program is busy with a call.
+func windowMiddleware(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		pty, changes, ok := sess.Pty()
+		if !ok {
+			next(sess)
+			return
+		}
+		ws := &windowSession{Session: sess, winch: make(chan ssh.Window, 1)}
+		ws.size.Store(&pty.Window)
+		go func() {
+			for w := range changes {
+				ws.size.Store(&w)
+				select {
+				case <-ws.winch: // drop a change the program hasn't taken
+				default:
+				}
+				ws.winch <- w
+			}
+		}()
+		next(ws)
+	}
+}
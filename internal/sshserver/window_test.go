@@ -0,0 +1,54 @@
+package sshserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// ptySession is an ssh.Session with a PTY and nothing else.
+type ptySession struct {
+	ssh.Session
+	winch chan ssh.Window
+}
+
+func (s ptySession) Pty() (ssh.Pty, <-chan ssh.Window, bool) {
+	return ssh.Pty{Window: ssh.Window{Width: 80, Height: 24}}, s.winch, true
+}
+
+func TestWindowMiddleware(t *testing.T) {
+	changes := make(chan ssh.Window)
+	var ws *windowSession
+	windowMiddleware(func(sess ssh.Session) { ws = sess.(*windowSession) })(ptySession{winch: changes})
+	if w, h := ws.Size(); w != 80 || h != 24 {
+		t.Errorf("Size = %dx%d, want 80x24", w, h)
+	}
+
+	// Nobody reads the program's channel, as while a call has the screen;
+	// changes still get through and only the latest is kept for later.
+	for _, h := range []int{30, 40, 50} {
+		select {
+		case changes <- ssh.Window{Width: 100, Height: h}:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("window change to height %d blocked", h)
+		}
+	}
+	close(changes)
+	deadline := time.Now().Add(2 * time.Second)
+	for _, h := ws.Size(); h != 50; _, h = ws.Size() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Size height = %d, want 50", h)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, winch, _ := ws.Pty()
+	if w := <-winch; w.Height != 50 {
+		t.Errorf("program got height %d, want the latest, 50", w.Height)
+	}
+	select {
+	case w := <-winch:
+		t.Errorf("stale change %+v kept", w)
+	default:
+	}
+}
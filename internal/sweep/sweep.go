@@ -0,0 +1,356 @@
+// Package sweep dials every site on a schedule to check that its remote
+// modem still answers, so a dead line is found before it is needed.
+package sweep
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// User is the name sweep dials are recorded under in the dial history.
+const User = "sweep"
+
+// ResultNoPrompt is recorded when a site connects but its prompt does not
+// appear in time.
+const ResultNoPrompt = "NO PROMPT"
+
+// Pause between sweep dials, how often to check whether one is due, and
+// how long the alert command may run before it is killed.
+const (
+	gap          = 30 * time.Second
+	tick         = time.Minute
+	alertTimeout = time.Minute
+)
+
+// Config controls when and what the scheduler sweeps.
+type Config struct {
+	Window       Window
+	Tags         []string      // sweep only sites with one of these tags; empty means all
+	Interval     time.Duration // how often each site is swept
+	AlertAfter   int           // consecutive failures before alerting
+	AlertCommand string        // run through sh -c on alert and recovery; optional
+}
+
+// Window is a daily time range in local time. End may be before Start for
+// a window that spans midnight.
+type Window struct {
+	Start, End time.Duration // since midnight
+}
+
+// ParseWindow parses "HH:MM-HH:MM".
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("sweep window %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("sweep window %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("sweep window %q: %w", s, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("sweep window %q is empty", s)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w Window) Contains(t time.Time) bool {
+	y, mo, d := t.Date()
+	since := t.Sub(time.Date(y, mo, d, 0, 0, 0, 0, t.Location()))
+	if w.Start < w.End {
+		return since >= w.Start && since < w.End
+	}
+	return since >= w.Start || since < w.End
+}
+
+func (w Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// Status is the sweep state of one site.
+type Status struct {
+	LastRun  time.Time
+	Result   string
+	Failures int // consecutive failed sweeps
+}
+
+// probeFunc dials site on an acquired device and reports the outcome. An
+// error means the hub could not place the call, which says nothing about
+// the site.
+type probeFunc func(site config.Site, device string) (session.DialRecord, error)
+
+// Scheduler sweeps sites one at a time while the modem pool is idle and
+// the time is inside the window. Results go to the dial history.
+type Scheduler struct {
+	cfg   Config
+	sites []config.Site
+	lock  *modem.DeviceLock
+	dials *session.DialLog
+	probe probeFunc
+	alert func(site string, st Status, recovered bool)
+
+	mu     sync.Mutex
+	status map[string]Status
+}
+
+// New creates a scheduler for the sites matching cfg.Tags, picking up
+// where earlier runs left off from the dial history. Protected sites need
+// approval for each dial, so they are only swept if they ask for it.
+func New(cfg Config, sites []config.Site, lock *modem.DeviceLock, dials *session.DialLog) *Scheduler {
+	s := &Scheduler{
+		cfg:    cfg,
+		lock:   lock,
+		dials:  dials,
+		probe:  probe,
+		status: make(map[string]Status),
+	}
+	s.alert = s.runAlert
+	for _, site := range sites {
+		if site.SkipSweep || site.Protected && !site.ForceSweep || !hasAnyTag(site, cfg.Tags) {
+			continue
+		}
+		s.sites = append(s.sites, site)
+		s.status[site.Name] = statusFromHistory(dials.Recent(site.Name, session.DialHistoryKeep))
+	}
+	return s
+}
+
+func hasAnyTag(site config.Site, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if site.HasTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusFromHistory rebuilds a site's status from its recent dials, newest
+// first.
+func statusFromHistory(recent []session.DialRecord) Status {
+	var st Status
+	for _, r := range recent {
+		if r.User != User {
+			continue
+		}
+		if st.LastRun.IsZero() {
+			st.LastRun, st.Result = r.Time, r.Result
+		}
+		if r.Connected() {
+			break
+		}
+		st.Failures++
+	}
+	return st
+}
+
+// Sites returns the number of sites being swept.
+func (s *Scheduler) Sites() int { return len(s.sites) }
+
+// Status returns a site's sweep status; ok is false if it is not swept.
+func (s *Scheduler) Status(site string) (st Status, ok bool) {
+	if s == nil {
+		return Status{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok = s.status[site]
+	return st, ok
+}
+
+// Alerting reports whether a site has failed enough sweeps in a row to
+// raise an alert.
+func (s *Scheduler) Alerting(site string) bool {
+	st, ok := s.Status(site)
+	return ok && s.cfg.AlertAfter > 0 && st.Failures >= s.cfg.AlertAfter
+}
+
+// Run sweeps until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	slog.Info("sweep scheduler started", "sites", len(s.sites), "window", s.cfg.Window, "interval", s.cfg.Interval)
+	for {
+		wait := tick
+		if site, ok := s.due(time.Now()); ok && s.poolIdle() {
+			s.sweep(site)
+			wait = gap
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// due returns the site swept longest ago, if one is due and now is inside
+// the window.
+func (s *Scheduler) due(now time.Time) (config.Site, bool) {
+	if !s.cfg.Window.Contains(now) {
+		return config.Site{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next config.Site
+	var oldest time.Time
+	found := false
+	for _, site := range s.sites {
+		last := s.status[site.Name].LastRun
+		if now.Sub(last) < s.cfg.Interval {
+			continue
+		}
+		if !found || last.Before(oldest) {
+			next, oldest, found = site, last, true
+		}
+	}
+	return next, found
+}
+
+// poolIdle reports whether no device is in use, so a sweep never competes
+// with a user for the last free line.
+func (s *Scheduler) poolIdle() bool {
+	return len(s.lock.ActiveSites()) == 0
+}
+
+// sweep dials one site, records the result and alerts on a run of failures
+// or on recovery from one.
+func (s *Scheduler) sweep(site config.Site) {
+	dev, err := s.lock.Acquire(site.Name)
+	if err != nil {
+		return // a user got there first; try again later
+	}
+	rec, err := s.probe(site, dev)
+	s.lock.Release(dev)
+	if err != nil {
+		slog.Warn("sweep could not dial", "site", site.Name, "device", dev, "err", err)
+		s.mu.Lock()
+		st := s.status[site.Name]
+		st.LastRun = time.Now()
+		s.status[site.Name] = st
+		s.mu.Unlock()
+		return
+	}
+
+	rec.Site, rec.User, rec.Device = site.Name, User, dev
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if err := s.dials.Record(rec); err != nil {
+		slog.Error("recording sweep", "site", site.Name, "err", err)
+	}
+
+	s.mu.Lock()
+	st := s.status[site.Name]
+	wasAlerting := s.cfg.AlertAfter > 0 && st.Failures >= s.cfg.AlertAfter
+	st.LastRun, st.Result = rec.Time, rec.Result
+	if rec.Connected() {
+		st.Failures = 0
+	} else {
+		st.Failures++
+	}
+	s.status[site.Name] = st
+	s.mu.Unlock()
+
+	slog.Info("sweep", "site", site.Name, "device", dev, "result", rec.Result, "failures", st.Failures)
+	switch {
+	case s.cfg.AlertAfter > 0 && st.Failures == s.cfg.AlertAfter:
+		s.alert(site.Name, st, false)
+	case wasAlerting && st.Failures == 0:
+		s.alert(site.Name, st, true)
+	}
+}
+
+// runAlert logs the alert and starts the alert command, if any, with the
+// details in OOB_SWEEP_* environment variables. The command runs in the
+// background under alertTimeout so a hung script cannot stall the sweeps.
+func (s *Scheduler) runAlert(site string, st Status, recovered bool) {
+	state := "failing"
+	if recovered {
+		state = "recovered"
+		slog.Info("sweep alert: site reachable again", "site", site)
+	} else {
+		slog.Error("sweep alert: site unreachable", "site", site, "failures", st.Failures, "result", st.Result)
+	}
+	if s.cfg.AlertCommand == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", s.cfg.AlertCommand)
+		cmd.Env = append(os.Environ(),
+			"OOB_SWEEP_SITE="+site,
+			"OOB_SWEEP_STATE="+state,
+			"OOB_SWEEP_RESULT="+st.Result,
+			"OOB_SWEEP_FAILURES="+strconv.Itoa(st.Failures),
+		)
+		cmd.WaitDelay = time.Second // don't wait on children still holding the output
+		if out, err := cmd.CombinedOutput(); err != nil {
+			slog.Error("sweep alert command failed", "site", site, "err", err, "output", strings.TrimSpace(string(out)))
+		}
+	}()
+}
+
+// probe dials the site and, if it has a prompt, sends Enter until the
+// prompt appears, then hangs up. A line that drops before the prompt is
+// recorded as NO CARRIER.
+func probe(site config.Site, device string) (session.DialRecord, error) {
+	start := time.Now()
+	mdm, resp, err := session.DialSite(site, device, nil)
+	if err != nil {
+		return session.DialRecord{}, err
+	}
+	rec := session.DialRecord{Time: start, Result: resp.Result.String(), Attempts: resp.Attempts, Rate: resp.Rate}
+	if resp.Result != modem.ResultConnect {
+		return rec, nil
+	}
+	defer mdm.Close()
+	defer mdm.Hangup()
+
+	if site.Prompt != nil {
+		deadline := time.Now().Add(site.PromptWait())
+		for {
+			if _, err := mdm.ReadWriteCloser().Write([]byte("\r")); err != nil {
+				slog.Info("sweep probe lost the line", "site", site.Name, "err", err)
+				rec.Result = modem.ResultNoCarrier.String()
+				break
+			}
+			if _, err := mdm.Expect(site.Prompt, min(2*time.Second, time.Until(deadline))); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				rec.Result = ResultNoPrompt
+				break
+			}
+		}
+	}
+	rec.Duration = time.Since(start).Round(time.Second)
+	return rec, nil
+}
@@ -0,0 +1,208 @@
+package sweep
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestParseWindow(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2026, 10, 18, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		window string
+		in     []time.Time
+		out    []time.Time
+	}{
+		{"02:00-05:00", []time.Time{day(2, 0), day(4, 59)}, []time.Time{day(1, 59), day(5, 0), day(14, 0)}},
+		{"22:30-04:00", []time.Time{day(22, 30), day(23, 59), day(0, 0), day(3, 59)}, []time.Time{day(4, 0), day(22, 29), day(12, 0)}},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tt.window, err)
+		}
+		if w.String() != tt.window {
+			t.Errorf("String() = %q, want %q", w.String(), tt.window)
+		}
+		for _, at := range tt.in {
+			if !w.Contains(at) {
+				t.Errorf("%s should contain %s", tt.window, at.Format("15:04"))
+			}
+		}
+		for _, at := range tt.out {
+			if w.Contains(at) {
+				t.Errorf("%s should not contain %s", tt.window, at.Format("15:04"))
+			}
+		}
+	}
+
+	for _, bad := range []string{"", "02:00", "2am-5am", "25:00-03:00", "03:00-03:00"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("ParseWindow(%q): expected error", bad)
+		}
+	}
+}
+
+// testScheduler sweeps sites with results from a script of outcomes; an
+// empty result stands for a hub-side dial error.
+func testScheduler(t *testing.T, cfg Config, sites []config.Site, results ...string) (*Scheduler, *session.DialLog, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	dev := filepath.Join(dir, "ttyIAX0")
+	if err := os.WriteFile(dev, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	dials, err := session.OpenDialLog(filepath.Join(dir, "dials.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Window == (Window{}) {
+		cfg.Window = Window{Start: 0, End: 24*time.Hour - time.Minute}
+	}
+	s := New(cfg, sites, modem.NewDeviceLock(dev), dials)
+	s.probe = func(site config.Site, device string) (session.DialRecord, error) {
+		if len(results) == 0 {
+			t.Fatal("unexpected sweep")
+		}
+		r := results[0]
+		results = results[1:]
+		if r == "" {
+			return session.DialRecord{}, errors.New("modem init failed")
+		}
+		return session.DialRecord{Result: r, Attempts: 1}, nil
+	}
+	var alerts []string
+	s.alert = func(site string, st Status, recovered bool) {
+		state := "failing"
+		if recovered {
+			state = "recovered"
+		}
+		alerts = append(alerts, site+" "+state)
+	}
+	return s, dials, &alerts
+}
+
+func TestSweepAlertsAfterConsecutiveFailures(t *testing.T) {
+	site := config.Site{Name: "router1"}
+	s, dials, alerts := testScheduler(t, Config{AlertAfter: 2}, []config.Site{site},
+		"NO CARRIER", "", "BUSY", "NO CARRIER", "CONNECT")
+
+	s.sweep(site)
+	if s.Alerting("router1") || len(*alerts) != 0 {
+		t.Fatal("alerted after one failure")
+	}
+	s.sweep(site) // hub-side error: not counted
+	if st, _ := s.Status("router1"); st.Failures != 1 {
+		t.Errorf("failures = %d after a hub error, want 1", st.Failures)
+	}
+	s.sweep(site)
+	if !s.Alerting("router1") || len(*alerts) != 1 || (*alerts)[0] != "router1 failing" {
+		t.Fatalf("alerts = %q, want one failing alert", *alerts)
+	}
+	s.sweep(site) // still failing: no repeat alert
+	if len(*alerts) != 1 {
+		t.Errorf("alerts = %q, want no repeat", *alerts)
+	}
+	s.sweep(site)
+	if s.Alerting("router1") || len(*alerts) != 2 || (*alerts)[1] != "router1 recovered" {
+		t.Errorf("alerts = %q, want recovery", *alerts)
+	}
+
+	recent := dials.Recent("router1", 10)
+	if len(recent) != 4 || recent[0].User != User || !recent[0].Connected() {
+		t.Errorf("dial history = %+v, want 4 sweep records", recent)
+	}
+}
+
+func TestRunAlertDoesNotBlock(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "alerted")
+	s := &Scheduler{cfg: Config{AlertCommand: "echo $OOB_SWEEP_STATE > " + marker + "; sleep 5"}}
+
+	start := time.Now()
+	s.runAlert("router1", Status{Failures: 2, Result: "BUSY"}, false)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("runAlert blocked for %s", d)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if b, err := os.ReadFile(marker); err == nil && strings.TrimSpace(string(b)) == "failing" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("alert command did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSweepResumesFromHistory(t *testing.T) {
+	site := config.Site{Name: "router1"}
+	s, dials, _ := testScheduler(t, Config{AlertAfter: 2}, []config.Site{site}, "BUSY", "BUSY")
+	s.sweep(site)
+	s.sweep(site)
+	dials.Record(session.DialRecord{Site: "router1", User: "alice", Result: "CONNECT"})
+
+	// User calls don't reset the sweep failure count.
+	again := New(Config{AlertAfter: 2}, []config.Site{site}, s.lock, dials)
+	st, ok := again.Status("router1")
+	if !ok || st.Failures != 2 || st.Result != "BUSY" || !again.Alerting("router1") {
+		t.Errorf("status = %+v, want 2 failures from history", st)
+	}
+}
+
+func TestSweepSkipsProtectedSites(t *testing.T) {
+	sites := []config.Site{
+		{Name: "a"},
+		{Name: "b", Protected: true},
+		{Name: "c", Protected: true, ForceSweep: true},
+	}
+	s, _, _ := testScheduler(t, Config{}, sites)
+	var names []string
+	for _, site := range s.sites {
+		names = append(names, site.Name)
+	}
+	if want := []string{"a", "c"}; !slices.Equal(names, want) {
+		t.Errorf("sweeping %q, want %q", names, want)
+	}
+}
+
+func TestSweepDue(t *testing.T) {
+	sites := []config.Site{
+		{Name: "a", Tags: []string{"nyc"}},
+		{Name: "b", Tags: []string{"chi"}},
+		{Name: "c", Tags: []string{"nyc"}, SkipSweep: true},
+		{Name: "d", Tags: []string{"NYC"}},
+	}
+	window, _ := ParseWindow("02:00-05:00")
+	s, _, _ := testScheduler(t, Config{Window: window, Tags: []string{"nyc"}, Interval: 24 * time.Hour}, sites, "CONNECT", "CONNECT")
+	if s.Sites() != 2 {
+		t.Fatalf("sweeping %d sites, want a and d", s.Sites())
+	}
+
+	night := time.Date(2026, 10, 18, 3, 0, 0, 0, time.Local)
+	if _, ok := s.due(night.Add(6 * time.Hour)); ok {
+		t.Error("due outside the window")
+	}
+	site, ok := s.due(night)
+	if !ok || site.Name != "a" {
+		t.Fatalf("due = %q, want a", site.Name)
+	}
+	s.sweep(site)
+	site, _ = s.due(night.Add(time.Minute))
+	if site.Name != "d" {
+		t.Errorf("due = %q, want d, swept longest ago", site.Name)
+	}
+	s.sweep(site)
+	if _, ok := s.due(night.Add(2 * time.Minute)); ok {
+		t.Error("a site is due again before the interval")
+	}
+}
@@ -0,0 +1,385 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// adminPrompt is an action on the users screen waiting for input.
+type adminPrompt int
+
+const (
+	promptNone   adminPrompt = iota
+	promptAdd                // typing the new username
+	promptRemove             // confirming removal of the selected user
+)
+
+// adminTickMsg refreshes the active sessions screen.
+type adminTickMsg struct{}
+
+func adminTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return adminTickMsg{} })
+}
+
+// AdminModel backs the admin screens: user management and the list of
+// active calls. It is only reachable by users with the admin right, which
+// is checked again before every change in case it was revoked meanwhile.
+// Changes are recorded in the audit log.
+type AdminModel struct {
+	store    auth.UserStore
+	calls    *session.Manager
+	audit    *session.AuditLog
+	username string // the admin, who may not lock or remove themselves
+	theme    Theme
+
+	users  []auth.UserInfo
+	cursor int
+	prompt adminPrompt
+	input  textinput.Model
+
+	notice string
+	err    string
+	// secret is a temporary password, shown until the next key press and
+	// never again.
+	secret string
+}
+
+// NewAdminModel creates the admin screens for username.
+func NewAdminModel(username string, store auth.UserStore, calls *session.Manager, audit *session.AuditLog, theme Theme) AdminModel {
+	in := textinput.New()
+	in.Placeholder = "first.last"
+	in.CharLimit = 32
+	m := AdminModel{
+		store:    store,
+		calls:    calls,
+		audit:    audit,
+		username: username,
+		theme:    theme,
+		input:    in,
+	}
+	m.reload()
+	return m
+}
+
+// reload re-reads the user list, keeping the cursor in range.
+func (m *AdminModel) reload() {
+	users, err := m.store.List()
+	if err != nil {
+		m.err = fmt.Sprintf("loading users: %v", err)
+		return
+	}
+	m.users = users
+	m.cursor = min(m.cursor, max(len(users)-1, 0))
+}
+
+func (m *AdminModel) selected() (auth.UserInfo, bool) {
+	if m.cursor >= len(m.users) {
+		return auth.UserInfo{}, false
+	}
+	return m.users[m.cursor], true
+}
+
+func (m *AdminModel) selectUser(name string) {
+	for i, u := range m.users {
+		if u.Username == name {
+			m.cursor = i
+		}
+	}
+}
+
+// updateUsers handles the users screen. The result of an action stays on
+// screen until the next key.
+func (m AdminModel) updateUsers(msg tea.Msg) (AdminModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if m.prompt == promptAdd {
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+	m.notice, m.err, m.secret = "", "", ""
+
+	switch m.prompt {
+	case promptAdd:
+		switch key.String() {
+		case "enter":
+			m.prompt = promptNone
+			m.input.Blur()
+			m.addUser(strings.TrimSpace(m.input.Value()))
+			return m, nil
+		case "esc":
+			m.prompt = promptNone
+			m.input.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	case promptRemove:
+		m.prompt = promptNone
+		if key.String() == "y" {
+			m.removeSelected()
+		}
+		return m, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.users)-1, 0))
+	case "a":
+		m.prompt = promptAdd
+		m.input.Reset()
+		return m, m.input.Focus()
+	case "l":
+		m.lockSelected(true)
+	case "u":
+		m.lockSelected(false)
+	case "r":
+		m.resetSelected()
+	case "x":
+		if u, ok := m.selected(); ok {
+			if u.Username == m.username {
+				m.err = "you cannot remove yourself"
+			} else {
+				m.prompt = promptRemove
+			}
+		}
+	case "s":
+		return m, tea.Batch(navigate(StateAdminSessions), adminTick())
+	case "esc", "q":
+		return m, navigate(StateMenu)
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// navigate asks the root model to switch screens.
+func navigate(s State) tea.Cmd {
+	return func() tea.Msg { return AdminNavigateMsg{To: s} }
+}
+
+// authorized reports whether the admin still holds the admin right,
+// setting the error if not.
+func (m *AdminModel) authorized() bool {
+	ok, err := m.store.HasRight(m.username, auth.RightAdmin)
+	if err != nil {
+		slog.Error("right check failed", "user", m.username, "right", auth.RightAdmin, "err", err)
+	}
+	if !ok {
+		m.err = "you no longer have the admin right"
+	}
+	return ok
+}
+
+// record writes an admin action against user to the audit log.
+func (m *AdminModel) record(event, user string) {
+	e := session.AuditEvent{Event: event, User: user, By: m.username}
+	if err := m.audit.Record(e); err != nil {
+		slog.Error("recording audit event", "event", e.Event, "err", err)
+	}
+}
+
+func (m *AdminModel) addUser(name string) {
+	if !auth.ValidUsername(name) {
+		m.err = fmt.Sprintf("invalid username %q: must be 2-32 lowercase alphanumeric chars, dots, or hyphens", name)
+		return
+	}
+	pw, err := auth.GenerateTemporaryPassword()
+	if err != nil {
+		m.err = fmt.Sprintf("generating password: %v", err)
+		return
+	}
+	if !m.authorized() {
+		return
+	}
+	if err := m.store.Add(name, pw); err != nil {
+		m.err = fmt.Sprintf("adding user: %v", err)
+		return
+	}
+	m.record("user-added", name)
+	m.reload()
+	m.selectUser(name)
+	m.notice = fmt.Sprintf("User %q created; they must change the password on first login.", name)
+	m.secret = pw
+}
+
+func (m *AdminModel) lockSelected(lock bool) {
+	u, ok := m.selected()
+	if !ok {
+		return
+	}
+	if lock && u.Username == m.username {
+		m.err = "you cannot lock yourself"
+		return
+	}
+	verb, op := "unlocked", m.store.Unlock
+	if lock {
+		verb, op = "locked", m.store.Lock
+	}
+	if !m.authorized() {
+		return
+	}
+	if err := op(u.Username); err != nil {
+		m.err = fmt.Sprintf("%s: %v", u.Username, err)
+		return
+	}
+	m.record("user-"+verb, u.Username)
+	m.reload()
+	m.notice = fmt.Sprintf("User %q %s.", u.Username, verb)
+}
+
+func (m *AdminModel) resetSelected() {
+	u, ok := m.selected()
+	if !ok {
+		return
+	}
+	pw, err := auth.GenerateTemporaryPassword()
+	if err != nil {
+		m.err = fmt.Sprintf("generating password: %v", err)
+		return
+	}
+	if !m.authorized() {
+		return
+	}
+	if err := m.store.Reset(u.Username, pw); err != nil {
+		m.err = fmt.Sprintf("resetting user: %v", err)
+		return
+	}
+	m.record("password-reset", u.Username)
+	m.reload()
+	m.notice = fmt.Sprintf("Password reset for %q; they must change it on next login.", u.Username)
+	m.secret = pw
+}
+
+func (m *AdminModel) removeSelected() {
+	u, ok := m.selected()
+	if !ok {
+		return
+	}
+	if !m.authorized() {
+		return
+	}
+	if err := m.store.Remove(u.Username); err != nil {
+		m.err = fmt.Sprintf("removing user: %v", err)
+		return
+	}
+	m.record("user-removed", u.Username)
+	m.reload()
+	m.notice = fmt.Sprintf("User %q removed.", u.Username)
+}
+
+func (m AdminModel) usersView() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Admin — Users"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "  %-20s %-7s %-17s %-9s %s\n", "USERNAME", "STATUS", "LAST LOGIN", "PASSWORD", "RIGHTS")
+	for i, u := range m.users {
+		status := "active"
+		if u.Locked {
+			status = "locked"
+		}
+		last := "never"
+		if !u.LastLogin.IsZero() {
+			last = u.LastLogin.Local().Format("2006-01-02 15:04")
+		}
+		pw := ""
+		if u.ForceChange {
+			pw = "temporary"
+		}
+		line := fmt.Sprintf("%-20s %-7s %-17s %-9s %s", u.Username, status, last, pw, strings.Join(u.Rights, ","))
+		if i == m.cursor {
+			b.WriteString(m.theme.InputStyle.Render("> " + line))
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	if len(m.users) == 0 {
+		b.WriteString(m.theme.LabelStyle.Render("  No users."))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	switch m.prompt {
+	case promptAdd:
+		fmt.Fprintf(&b, "  New username: %s\n", m.input.View())
+		b.WriteString(m.theme.LabelStyle.Render("  Enter to create · Esc to cancel"))
+	case promptRemove:
+		u, _ := m.selected()
+		b.WriteString(m.theme.WarningStyle.Render(fmt.Sprintf("  Remove %q? y to confirm, any other key to cancel", u.Username)))
+	default:
+		if m.notice != "" {
+			b.WriteString(m.theme.SuccessStyle.Render("  " + m.notice))
+			b.WriteString("\n")
+		}
+		if m.secret != "" {
+			b.WriteString(m.theme.WarningStyle.Render("  Temporary password: " + m.secret))
+			b.WriteString("\n")
+			b.WriteString(m.theme.LabelStyle.Render("  Shown once — note it now."))
+			b.WriteString("\n")
+		}
+		if m.err != "" {
+			b.WriteString(m.theme.ErrorStyle.Render("  " + m.err))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.theme.LabelStyle.Render("  a add · l lock · u unlock · r reset password · x remove · s sessions · esc back"))
+	}
+	return m.theme.BoxStyle.Render(b.String())
+}
+
+// updateSessions handles the active sessions screen, which redraws every
+// second while open.
+func (m AdminModel) updateSessions(msg tea.Msg) (AdminModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case adminTickMsg:
+		return m, adminTick()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "u":
+			return m, navigate(StateAdminUsers)
+		case "ctrl+c":
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m AdminModel) sessionsView() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Admin — Active Sessions"))
+	b.WriteString("\n\n")
+
+	calls := m.calls.Calls()
+	if len(calls) == 0 {
+		b.WriteString(m.theme.LabelStyle.Render("  No calls in progress."))
+		b.WriteString("\n")
+	} else {
+		fmt.Fprintf(&b, "  %-16s %-12s %-14s %-9s %-22s %s\n", "SITE", "OWNER", "DEVICE", "UP", "ATTACHED", "TRAFFIC")
+		now := time.Now()
+		for _, c := range calls {
+			attached := c.AttachedBy()
+			if until := c.DetachedUntil(); !until.IsZero() {
+				attached = fmt.Sprintf("detached, %s left", until.Sub(now).Round(time.Second))
+			}
+			fmt.Fprintf(&b, "  %-16s %-12s %-14s %-9s %-22s ↓%s ↑%s\n",
+				truncate(c.Site, 16), truncate(c.Owner, 12), c.Device(),
+				clock(now.Sub(c.Started)), truncate(attached, 22),
+				humanBytes(c.BytesIn()), humanBytes(c.BytesOut()))
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(m.theme.LabelStyle.Render("  esc back to users"))
+	return m.theme.BoxStyle.Render(b.String())
+}
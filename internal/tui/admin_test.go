@@ -0,0 +1,184 @@
+package tui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// newTestAdmin opens the admin screens as "admin", who holds the admin
+// right if listed in users.
+func newTestAdmin(t *testing.T, users ...string) (AdminModel, *auth.FileStore) {
+	t.Helper()
+	deps, store := newTestDeps(t, users...)
+	if slices.Contains(users, "admin") {
+		store.Grant("admin", auth.RightAdmin)
+	}
+	return NewAdminModel("admin", store, deps.Calls, nil, NewTheme(nil)), store
+}
+
+func adminKeys(m AdminModel, ks ...string) AdminModel {
+	for _, k := range ks {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		m, _ = m.updateUsers(msg)
+	}
+	return m
+}
+
+func TestAdmin_AddShowsPasswordOnce(t *testing.T) {
+	m, store := newTestAdmin(t, "admin")
+	m = adminKeys(m, "a", "bob", "enter")
+	if m.err != "" {
+		t.Fatalf("add failed: %s", m.err)
+	}
+	pw := m.secret
+	if pw == "" || !strings.Contains(m.usersView(), pw) {
+		t.Fatal("temporary password not shown")
+	}
+	if ok, _ := store.Authenticate("bob", pw); !ok {
+		t.Error("temporary password does not log in")
+	}
+	if sel, _ := m.selected(); sel.Username != "bob" {
+		t.Errorf("selected %q, want the new user", sel.Username)
+	}
+
+	m = adminKeys(m, "down")
+	if strings.Contains(m.usersView(), pw) {
+		t.Error("temporary password still shown after a key press")
+	}
+}
+
+func TestAdmin_LockResetRemove(t *testing.T) {
+	m, store := newTestAdmin(t, "admin", "bob")
+	m = adminKeys(m, "down", "l")
+	if info, _ := store.Get("bob"); !info.Locked {
+		t.Fatal("bob not locked")
+	}
+	m = adminKeys(m, "u")
+	if info, _ := store.Get("bob"); info.Locked {
+		t.Fatal("bob not unlocked")
+	}
+
+	store.SetPassword("bob", "password2")
+	m = adminKeys(m, "r")
+	if ok, _ := store.Authenticate("bob", m.secret); !ok || m.secret == "" {
+		t.Fatal("reset password does not log in")
+	}
+	if force, _ := store.MustChangePassword("bob"); !force {
+		t.Error("reset should force a password change")
+	}
+
+	m = adminKeys(m, "x", "n")
+	if _, err := store.Get("bob"); err != nil {
+		t.Fatal("removed without confirmation")
+	}
+	m = adminKeys(m, "x", "y")
+	if _, err := store.Get("bob"); err == nil {
+		t.Error("bob not removed")
+	}
+	if len(m.users) != 1 || m.cursor != 0 {
+		t.Errorf("users = %v, cursor %d", m.users, m.cursor)
+	}
+}
+
+func TestAdmin_RecordsActions(t *testing.T) {
+	m, _ := newTestAdmin(t, "admin")
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var err error
+	if m.audit, err = session.OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	m = adminKeys(m, "a", "bob", "enter")
+	m = adminKeys(m, "l", "u", "r", "x", "y")
+	if m.err != "" {
+		t.Fatalf("action failed: %s", m.err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var e session.AuditEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.User != "bob" || e.By != "admin" {
+			t.Errorf("event %+v: want user bob by admin", e)
+		}
+		got = append(got, e.Event)
+	}
+	want := []string{"user-added", "user-locked", "user-unlocked", "password-reset", "user-removed"}
+	if !slices.Equal(got, want) {
+		t.Errorf("audit events = %q, want %q", got, want)
+	}
+}
+
+func TestAdmin_RevokedAdminCannotAct(t *testing.T) {
+	m, store := newTestAdmin(t, "admin", "bob")
+	store.Revoke("admin", auth.RightAdmin)
+
+	m = adminKeys(m, "down", "l")
+	if info, _ := store.Get("bob"); info.Locked || m.err == "" {
+		t.Error("revoked admin locked bob")
+	}
+	m = adminKeys(m, "a", "carol", "enter")
+	if _, err := store.Get("carol"); err == nil || m.secret != "" {
+		t.Error("revoked admin added a user")
+	}
+}
+
+func TestAdmin_CannotLockOrRemoveSelf(t *testing.T) {
+	m, store := newTestAdmin(t, "admin")
+	m = adminKeys(m, "l")
+	if info, _ := store.Get("admin"); info.Locked || m.err == "" {
+		t.Error("admin locked themselves")
+	}
+	m = adminKeys(m, "x", "y")
+	if _, err := store.Get("admin"); err != nil || m.prompt != promptNone {
+		t.Error("admin removed themselves")
+	}
+}
+
+func TestAdmin_InvalidUsername(t *testing.T) {
+	m, store := newTestAdmin(t, "admin")
+	m = adminKeys(m, "a", "Bad User", "enter")
+	if m.err == "" || m.secret != "" {
+		t.Error("invalid username accepted")
+	}
+	if users, _ := store.List(); len(users) != 1 {
+		t.Errorf("users = %v", users)
+	}
+}
+
+func TestMenu_AdminKeyOnlyForAdmins(t *testing.T) {
+	m := newTestMenu(t, menuSites, nil, map[string]bool{})
+	a := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")}
+	if _, cmd := m.Update(a); cmd != nil {
+		t.Error("a opened admin for a non-admin")
+	}
+	m.canAdmin = true
+	_, cmd := m.Update(a)
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	if _, ok := cmd().(AdminRequestMsg); !ok {
+		t.Error("a did not request the admin screens")
+	}
+}
@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// approvalsChangedMsg is sent when a request to dial a protected site is
+// filed or decided anywhere on the hub.
+type approvalsChangedMsg struct{}
+
+// waitApprovals waits for the next change to the approval queue.
+func waitApprovals(approvals *session.Approvals) tea.Cmd {
+	changed := approvals.Changed()
+	return func() tea.Msg {
+		<-changed
+		return approvalsChangedMsg{}
+	}
+}
+
+// approvalDecidedMsg is sent to the DialingModel when its request is
+// approved, rejected, cancelled or expires. id tells it apart from the
+// decision on an earlier request the user has since given up on.
+type approvalDecidedMsg struct {
+	id       string
+	err      error
+	approver string
+}
+
+func waitApproval(r *session.ApprovalRequest) tea.Cmd {
+	return func() tea.Msg {
+		return approvalDecidedMsg{id: r.ID, err: r.Err(), approver: r.Approver()}
+	}
+}
+
+// ApprovalsModel lists pending requests to dial protected sites so an
+// approver can accept or reject them.
+type ApprovalsModel struct {
+	approvals *session.Approvals
+	username  string
+	theme     Theme
+	pending   []*session.ApprovalRequest
+	cursor    int
+	notice    string
+	err       string
+}
+
+// NewApprovalsModel creates the approvals screen for username.
+func NewApprovalsModel(username string, approvals *session.Approvals, theme Theme) ApprovalsModel {
+	m := ApprovalsModel{approvals: approvals, username: username, theme: theme}
+	m.reload()
+	return m
+}
+
+// reload refreshes the list, keeping the cursor in range.
+func (m *ApprovalsModel) reload() {
+	m.pending = m.approvals.Pending()
+	m.cursor = min(m.cursor, max(len(m.pending)-1, 0))
+}
+
+func (m ApprovalsModel) Update(msg tea.Msg) (ApprovalsModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	m.notice, m.err = "", ""
+	switch key.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.pending)-1, 0))
+	case "y", "n":
+		if len(m.pending) == 0 {
+			break
+		}
+		r := m.pending[m.cursor]
+		approve := key.String() == "y"
+		if err := m.approvals.Decide(r.ID, m.username, approve); err != nil {
+			m.err = err.Error()
+		} else if approve {
+			m.notice = fmt.Sprintf("Approved %s's dial to %s.", r.User, r.Site)
+		} else {
+			m.notice = fmt.Sprintf("Rejected %s's dial to %s.", r.User, r.Site)
+		}
+		m.reload()
+	case "esc", "q":
+		return m, func() tea.Msg { return ApprovalsDoneMsg{} }
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m ApprovalsModel) View() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Dial Approvals"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "  %-16s %-16s %-14s %-9s %s\n", "USER", "SITE", "TICKET", "SINCE", "REASON")
+	for i, r := range m.pending {
+		cursor := " "
+		if i == m.cursor {
+			cursor = ">"
+		}
+		ticket := r.Ticket.ID
+		if ticket == "" {
+			ticket = "—"
+		}
+		line := fmt.Sprintf(" %s%-16s %-16s %-14s %-9s %s", cursor, truncate(r.User, 16), truncate(r.Site, 16),
+			truncate(ticket, 14), r.Requested.Format("15:04:05"), truncate(r.Ticket.Reason, 40))
+		if r.User == m.username {
+			line += m.theme.LabelStyle.Render("  (yours)")
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(m.pending) == 0 {
+		b.WriteString("  No requests are waiting.\n")
+	}
+	if m.err != "" {
+		b.WriteString("\n" + m.theme.ErrorStyle.Render("  "+m.err) + "\n")
+	}
+	if m.notice != "" {
+		b.WriteString("\n" + m.theme.SuccessStyle.Render("  "+m.notice) + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(m.theme.LabelStyle.Render("  Updates live · y approve · n reject · esc back"))
+	return m.theme.BoxStyle.Render(b.String())
+}
+
+// othersPending counts the pending requests user could decide.
+func othersPending(approvals *session.Approvals, user string) int {
+	n := 0
+	for _, r := range approvals.Pending() {
+		if r.User != user {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func newApprovalDeps(t *testing.T) Deps {
+	t.Helper()
+	deps, store := newTestDeps(t, "alice", "bob")
+	store.Grant("bob", auth.RightApprove)
+	deps.Sites = []config.Site{{Name: "core1", Protected: true}}
+	deps.Approvals = session.NewApprovals(nil)
+	return deps
+}
+
+func rootKey(m Model, k string) Model {
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	if k == "ctrl+c" {
+		msg = tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	next, _ := m.Update(msg)
+	return next.(Model)
+}
+
+func TestProtectedSiteWaitsForApproval(t *testing.T) {
+	deps := newApprovalDeps(t)
+	alice := New(context.Background(), "alice", deps, nil, false, nil)
+	bob := New(context.Background(), "bob", deps, nil, false, nil)
+	if alice.canApprove || !bob.canApprove {
+		t.Fatalf("canApprove: alice %v, bob %v", alice.canApprove, bob.canApprove)
+	}
+
+	changed := waitApprovals(deps.Approvals)
+	next, _ := alice.Update(DialRequestMsg{SiteIndex: 0})
+	alice = next.(Model)
+	if alice.state != StateDialing || alice.dialing.approval == nil {
+		t.Fatalf("state = %v, want dialing and waiting for approval", alice.state)
+	}
+	if !strings.Contains(alice.View(), "Waiting for a second person") {
+		t.Errorf("dialing view:\n%s", alice.View())
+	}
+
+	next, _ = bob.Update(changed())
+	bob = next.(Model)
+	if !strings.Contains(bob.View(), "1 dial request(s) awaiting approval") {
+		t.Errorf("bob's menu does not show the request:\n%s", bob.View())
+	}
+	next, cmd := bob.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("A")})
+	next, _ = next.Update(cmd())
+	bob = rootKey(next.(Model), "y")
+	if bob.state != StateApprovals || !strings.Contains(bob.View(), "Approved alice's dial to core1") {
+		t.Fatalf("approvals screen:\n%s", bob.View())
+	}
+
+	// alice's wait ends; with no modems the dial itself then fails.
+	next, _ = alice.Update(waitApproval(alice.dialing.approval)())
+	alice = next.(Model)
+	if !strings.Contains(alice.dialing.status, "Approved by bob") {
+		t.Errorf("status = %q, want approved", alice.dialing.status)
+	}
+	if !alice.dialing.done || !strings.Contains(alice.View(), "no modem available") {
+		t.Errorf("dial after approval:\n%s", alice.View())
+	}
+}
+
+func TestApprovalRejectedAndCancelled(t *testing.T) {
+	deps := newApprovalDeps(t)
+	alice := New(context.Background(), "alice", deps, nil, false, nil)
+
+	next, _ := alice.Update(DialRequestMsg{SiteIndex: 0})
+	alice = next.(Model)
+	req := alice.dialing.approval
+	if err := deps.Approvals.Decide(req.ID, "alice", true); err == nil {
+		t.Error("alice approved their own request")
+	}
+	deps.Approvals.Decide(req.ID, "bob", false)
+	next, _ = alice.Update(waitApproval(req)())
+	alice = next.(Model)
+	if !alice.dialing.done || !strings.Contains(alice.View(), "rejected by bob") {
+		t.Errorf("rejected dial:\n%s", alice.View())
+	}
+
+	next, _ = rootKey(alice, "ctrl+c").Update(DialRequestMsg{SiteIndex: 0})
+	alice = next.(Model)
+	req = alice.dialing.approval
+	if alice = rootKey(alice, "ctrl+c"); alice.state != StateMenu {
+		t.Fatalf("ctrl+c: state = %v, want menu", alice.state)
+	}
+	select {
+	case <-req.Done():
+	default:
+		t.Error("ctrl+c did not withdraw the request")
+	}
+	if n := len(deps.Approvals.Pending()); n != 0 {
+		t.Errorf("%d requests still pending", n)
+	}
+}
+
+func TestStaleApprovalDecisionIgnored(t *testing.T) {
+	deps := newApprovalDeps(t)
+	alice := New(context.Background(), "alice", deps, nil, false, nil)
+
+	// alice gives up on a request and files another.
+	next, _ := alice.Update(DialRequestMsg{SiteIndex: 0})
+	stale := waitApproval(next.(Model).dialing.approval)
+	next, _ = rootKey(next.(Model), "ctrl+c").Update(DialRequestMsg{SiteIndex: 0})
+	alice = next.(Model)
+
+	// The first request's cancellation must not fail the second.
+	next, _ = alice.Update(stale())
+	alice = next.(Model)
+	if alice.dialing.done || alice.dialing.approval == nil {
+		t.Fatalf("stale decision ended the new request:\n%s", alice.View())
+	}
+	if n := len(deps.Approvals.Pending()); n != 1 {
+		t.Errorf("%d requests pending, want the new one", n)
+	}
+}
+
+func TestApprovalWithdrawnWhenSessionEnds(t *testing.T) {
+	deps := newApprovalDeps(t)
+	ctx, disconnect := context.WithCancel(context.Background())
+	alice := New(ctx, "alice", deps, nil, false, nil)
+
+	next, _ := alice.Update(DialRequestMsg{SiteIndex: 0})
+	req := next.(Model).dialing.approval
+	disconnect()
+	select {
+	case <-req.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request outlived alice's session")
+	}
+	if n := len(deps.Approvals.Pending()); n != 0 {
+		t.Errorf("%d requests still pending", n)
+	}
+}
@@ -1,24 +1,20 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
 	"github.com/gbm-dev/pots/internal/config"
 	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
 )
 
-// Keep this slightly above Asterisk's Dial() timeout (120s in extensions.conf)
-// so we can receive final modem result codes like NO CARRIER instead of a
-// premature local TIMEOUT.
-const dialTimeout = 125 * time.Second
-const resetTimeout = 5 * time.Second
-const maxRetries = 3
-const retryDelay = 2 * time.Second
-
 // DialingModel shows connection progress with a spinner.
 type DialingModel struct {
 	spinner    spinner.Model
@@ -29,26 +25,109 @@ type DialingModel struct {
 	showDebug  bool
 	err        error
 	done       bool
+	username   string
+	ticket     session.Ticket
+	approval   *session.ApprovalRequest // set while a protected site awaits approval
+	waiter     *modem.Waiter            // place in the modem queue, until the device is taken
+	preempt    preemption
+	preempting *session.Call // the call asked to give up its line, if any
+	withdraw   func()        // withdraws the preemption, if any
+	target     *session.Call // the call P would preempt, refreshed each queue tick
+	targetRank int
+	access     auth.Access     // the user's roles; hides busy lines' sites they can't see
+	sites      []config.Site   // to look up sites for access
+	ctx        context.Context // the SSH session's; ends the wait if it drops
 	lock       *modem.DeviceLock
+	calls      *session.Manager
 	theme      Theme
 }
 
-// NewDialingModel creates a dialing view for the given site.
-func NewDialingModel(site config.Site, lock *modem.DeviceLock, theme Theme) DialingModel {
+// queueTickMsg refreshes the queue position while waiting for a modem.
+type queueTickMsg struct{}
+
+// modemReadyMsg is sent when the DialingModel's waiter gets a device or is
+// cancelled.
+type modemReadyMsg struct{}
+
+// NewDialingModel creates a dialing view for the given site. Failed dials
+// are recorded in the dial history of calls; connected calls are recorded
+// when they end. Waits are abandoned when ctx ends.
+func NewDialingModel(ctx context.Context, site config.Site, username string, ticket session.Ticket, lock *modem.DeviceLock, calls *session.Manager, theme Theme) DialingModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = theme.WarningStyle
 	return DialingModel{
-		spinner: s,
-		site:    site,
-		status:  "Acquiring modem...",
-		lock:    lock,
-		theme:   theme,
+		spinner:  s,
+		site:     site,
+		status:   "Acquiring modem...",
+		username: username,
+		ticket:   ticket,
+		ctx:      ctx,
+		lock:     lock,
+		calls:    calls,
+		theme:    theme,
+	}
+}
+
+// queue claims a free modem or joins the queue for the next one to be
+// hung up. The dial fails if no modem can be had at all.
+func (m *DialingModel) queue() {
+	w, err := m.lock.Wait(m.ctx, m.site.Name, m.preempt.priority)
+	if err != nil {
+		m.done = true
+		m.err = fmt.Errorf("no modem available: %w", err)
+		return
+	}
+	m.waiter = w
+	if w.Position() > 0 {
+		m.status = "All modem lines are busy. Waiting for one to free up... (Ctrl+C to cancel)"
 	}
+	m.refreshTarget()
+}
+
+// cancel gives up a pending approval request or place in the modem queue,
+// and withdraws any preemption the dial asked for.
+func (m DialingModel) cancel() {
+	if m.approval != nil {
+		m.approval.Cancel()
+	}
+	if m.waiter != nil {
+		m.waiter.Cancel()
+	}
+	if m.withdraw != nil {
+		m.withdraw()
+	}
+}
+
+// awaitApproval makes the dial wait until r is approved.
+func (m *DialingModel) awaitApproval(r *session.ApprovalRequest) {
+	m.approval = r
+	m.status = "Waiting for a second person to approve this dial... (Ctrl+C to cancel)"
 }
 
 func (m DialingModel) Init() tea.Cmd {
-	return tea.Batch(m.spinner.Tick, m.acquireAndDial())
+	if m.approval != nil {
+		return tea.Batch(m.spinner.Tick, waitApproval(m.approval))
+	}
+	if m.waiter != nil {
+		return tea.Batch(m.spinner.Tick, m.waitModem())
+	}
+	return nil
+}
+
+// waitModem waits for the waiter to get a device, refreshing the queue
+// position every second meanwhile.
+func (m DialingModel) waitModem() tea.Cmd {
+	ready := m.waiter.Ready()
+	wait := func() tea.Msg {
+		<-ready
+		return modemReadyMsg{}
+	}
+	return tea.Batch(wait, queueTick())
+}
+
+func queueTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return queueTickMsg{} })
 }
 
 func (m DialingModel) Update(msg tea.Msg) (DialingModel, tea.Cmd) {
@@ -62,6 +141,42 @@ func (m DialingModel) Update(msg tea.Msg) (DialingModel, tea.Cmd) {
 		m.status = string(msg)
 		return m, nil
 
+	case approvalDecidedMsg:
+		if m.approval == nil || msg.id != m.approval.ID {
+			return m, nil // a request given up on earlier
+		}
+		if msg.err != nil {
+			m.done = true
+			m.err = fmt.Errorf("not approved: %w", msg.err)
+			return m, nil
+		}
+		m.status = fmt.Sprintf("Approved by %s. Acquiring modem...", msg.approver)
+		m.queue()
+		if m.done {
+			return m, nil
+		}
+		return m, m.waitModem()
+
+	case queueTickMsg:
+		m.refreshTarget()
+		if m.waiter == nil || m.waiter.Position() == 0 {
+			return m, nil
+		}
+		return m, queueTick()
+
+	case modemReadyMsg:
+		dev, ok := m.waiter.Take()
+		if !ok {
+			return m, nil
+		}
+		if m.withdraw != nil {
+			// Spare the preempted call if another line freed up first.
+			m.withdraw()
+		}
+		m.device = dev
+		m.status = "Dialing..."
+		return m, m.dial(dev)
+
 	case DialResultMsg:
 		if msg.Result == modem.ResultConnect {
 			m.status = m.theme.SuccessStyle.Render("CONNECTED")
@@ -84,6 +199,16 @@ func (m DialingModel) Update(msg tea.Msg) (DialingModel, tea.Cmd) {
 			m.showDebug = !m.showDebug
 			return m, nil
 		}
+		if (msg.String() == "p" || msg.String() == "P") && m.preempting == nil && m.queuePosition() == 1 {
+			ctx, withdraw := context.WithCancel(m.ctx)
+			if c := m.target; c != nil && c.Preempt(ctx, m.username, m.ticket, m.preempt.audit) {
+				m.preempting, m.withdraw = c, withdraw
+				m.target = nil
+			} else {
+				withdraw()
+			}
+			return m, nil
+		}
 	}
 
 	return m, nil
@@ -95,6 +220,9 @@ func (m DialingModel) View() string {
 	details := fmt.Sprintf(
 		"  Phone:  %s\n  Baud:   %d\n  Device: %s",
 		m.site.Phone, m.site.BaudRate, m.deviceDisplay())
+	if m.ticket.ID != "" {
+		details += "\n  Ticket: " + m.ticket.ID
+	}
 
 	if m.err != nil {
 		view := header + "\n\n" + details + "\n\n" +
@@ -114,10 +242,50 @@ func (m DialingModel) View() string {
 		return m.theme.BoxStyle.Render(view)
 	}
 
-	return m.theme.BoxStyle.Render(
-		header + "\n\n" + details + "\n\n" +
-			fmt.Sprintf("  %s %s", m.spinner.View(), m.status),
-	)
+	view := header + "\n\n" + details + "\n\n" +
+		fmt.Sprintf("  %s %s", m.spinner.View(), m.status)
+	if pos := m.queuePosition(); pos > 0 {
+		view += "\n\n" + m.theme.WarningStyle.Render(fmt.Sprintf("  Position %d in the queue", pos))
+		if holders := m.holders(); len(holders) > 0 {
+			view += "\n" + m.theme.LabelStyle.Render("  Lines in use:") + "\n" + strings.Join(holders, "\n")
+		}
+		if hint := m.preemptHint(); hint != "" {
+			view += "\n\n" + m.theme.WarningStyle.Render(hint)
+		}
+	}
+	return m.theme.BoxStyle.Render(view)
+}
+
+// queuePosition returns the dial's place in the modem queue, or 0 if it
+// isn't waiting for a modem.
+func (m DialingModel) queuePosition() int {
+	if m.waiter == nil {
+		return 0
+	}
+	return m.waiter.Position()
+}
+
+// holders describes who holds each busy modem device, one line each,
+// naming only sites the user can see.
+func (m DialingModel) holders() []string {
+	calls := make(map[string]*session.Call)
+	for _, c := range m.calls.Calls() {
+		calls[c.Device()] = c
+	}
+	held := m.lock.Holders()
+	var lines []string
+	for _, dev := range m.lock.Devices() {
+		site, ok := held[dev]
+		if !ok {
+			continue
+		}
+		line := fmt.Sprintf("    %s: %s", dev, siteLabel(m.access, m.sites, site))
+		if c := calls[dev]; c != nil {
+			line += fmt.Sprintf(" (%s, since %s)", c.Owner, c.Started.Format("15:04"))
+		}
+		lines = append(lines, line)
+	}
+	return lines
 }
 
 func (m DialingModel) deviceDisplay() string {
@@ -127,83 +295,46 @@ func (m DialingModel) deviceDisplay() string {
 	return m.device
 }
 
-// retryable returns true for dial results that may succeed on retry.
-func retryable(r modem.DialResult) bool {
-	return r == modem.ResultNoCarrier || r == modem.ResultTimeout
-}
-
-// acquireAndDial runs the modem acquire → reset → configure → dial sequence
-// with automatic retries on transient failures (NO CARRIER, TIMEOUT).
-func (m DialingModel) acquireAndDial() tea.Cmd {
+// dial runs the dial sequence on dev, releasing the device again unless the
+// call connects. A call that connects after the SSH session has gone is
+// hung up, since nobody is left to take it.
+func (m DialingModel) dial(dev string) tea.Cmd {
 	return func() tea.Msg {
-		// Step 1: Acquire device
-		dev, err := m.lock.Acquire(m.site.Name)
+		mdm, resp, err := session.DialSite(m.site, dev, nil)
 		if err != nil {
-			return ErrorMsg{Err: fmt.Errorf("modem busy: %w", err), Context: "acquire"}
+			m.lock.Release(dev)
+			m.record(dev, modem.ResultError, max(resp.Attempts, 1))
+			return ErrorMsg{Err: err, Context: "dial"}
 		}
-
-		var lastResp modem.DialResponse
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			if attempt > 1 {
-				time.Sleep(retryDelay)
-			}
-
-			// Open device
-			mdm, err := modem.Open(dev)
-			if err != nil {
-				m.lock.Release()
-				return ErrorMsg{Err: fmt.Errorf("failed to open %s: %w", dev, err), Context: "open"}
-			}
-
-			// Initialize modem (ATE0 + ATZ)
-			if err := mdm.Init(resetTimeout); err != nil {
-				mdm.Close()
-				m.lock.Release()
-				return ErrorMsg{Err: fmt.Errorf("modem init failed: %w", err), Context: "init"}
-			}
-
-			// Send pre-dial configuration commands if any
-			if len(m.site.ModemInit) > 0 {
-				if err := mdm.Configure(m.site.ModemInit, resetTimeout); err != nil {
-					mdm.Close()
-					m.lock.Release()
-					return ErrorMsg{Err: fmt.Errorf("modem configure failed: %w", err), Context: "configure"}
-				}
-			}
-
-			// Dial
-			resp, err := mdm.Dial(m.site.Phone, dialTimeout)
-			if err != nil {
-				mdm.Hangup()
-				mdm.Close()
-				m.lock.Release()
-				return ErrorMsg{Err: fmt.Errorf("dial error: %w", err), Context: "dial"}
-			}
-
-			if resp.Result == modem.ResultConnect {
-				return DialResultMsg{Result: resp.Result, Transcript: resp.Transcript, Modem: mdm, Device: dev}
-			}
-
-			lastResp = resp
-			slog.Info("dial failed, checking retry", "result", resp.Result, "attempt", attempt, "max", maxRetries)
-
-			// Clean up before potential retry
+		if resp.Result == modem.ResultConnect && m.ctx.Err() != nil {
+			slog.Info("session ended while dialing, hanging up", "site", m.site.Name, "user", m.username)
 			mdm.Hangup()
 			mdm.Close()
-
-			// Non-retryable results: fail immediately
-			if !retryable(resp.Result) {
-				m.lock.Release()
-				return DialResultMsg{Result: resp.Result, Transcript: resp.Transcript, Device: dev}
-			}
-
-			if attempt < maxRetries {
-				slog.Info("retrying dial", "attempt", attempt+1, "max", maxRetries)
-			}
+			m.lock.Release(dev)
+			return ErrorMsg{Err: m.ctx.Err(), Context: "dial"}
+		}
+		if resp.Result != modem.ResultConnect {
+			m.lock.Release(dev)
+			m.record(dev, resp.Result, resp.Attempts)
 		}
+		return DialResultMsg{Result: resp.Result, Transcript: resp.Transcript, Modem: mdm, Device: dev, Attempts: resp.Attempts}
+	}
+}
 
-		// All retries exhausted
-		m.lock.Release()
-		return DialResultMsg{Result: lastResp.Result, Transcript: lastResp.Transcript, Device: dev}
+// record adds a dial that didn't connect to the dial history. Errors on
+// the hub side, such as a modem that won't initialise, are recorded as
+// ERROR.
+func (m DialingModel) record(dev string, result modem.DialResult, attempts int) {
+	err := m.calls.Dials().Record(session.DialRecord{
+		Site:     m.site.Name,
+		User:     m.username,
+		Device:   dev,
+		Result:   result.String(),
+		Attempts: attempts,
+		Ticket:   m.ticket.ID,
+		Reason:   m.ticket.Reason,
+	})
+	if err != nil {
+		slog.Error("recording dial history", "site", m.site.Name, "err", err)
 	}
 }
@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/auth"
+)
+
+// escapeHelp describes the escape commands; %[1]s is the escape character.
+const escapeHelp = "\r\nSupported escape sequences (after Enter):\r\n" +
+	" %[1]s.  disconnect\r\n" +
+	" %[1]sr  toggle raw/line mode\r\n" +
+	" %[1]sb  send break\r\n" +
+	" %[1]sm  add a timestamped marker to the session log\r\n" +
+	" %[1]ss  show session statistics\r\n" +
+	" %[1]sp  pick a stored snippet to insert\r\n" +
+	" %[1]s#  hang up and redial\r\n" +
+	" %[1]s?  this help\r\n" +
+	" %[1]s%[1]s  send a literal %[1]s\r\n"
+
+// escapeChar returns the byte that starts escape commands.
+func (t *TerminalSession) escapeChar() byte {
+	if t.opts.EscapeChar == 0 {
+		return auth.DefaultEscapeChar
+	}
+	return t.opts.EscapeChar
+}
+
+// escapeName renders the escape character for display, using caret
+// notation for control characters.
+func (t *TerminalSession) escapeName() string {
+	b := t.escapeChar()
+	if b < ' ' {
+		return "^" + string(rune(b+'@'))
+	}
+	return string(rune(b))
+}
+
+// notice prints a hub message on its own line in the user's terminal.
+func notice(echo io.Writer, format string, args ...any) {
+	fmt.Fprintf(echo, "\r\n*** "+format+" ***\r\n", args...)
+}
+
+// escapeCommand runs the escape command for key b. handled is false when b
+// is not a command, in which case the input passes through unchanged.
+func (t *TerminalSession) escapeCommand(in *inputState, b byte, w io.Writer, echo io.Writer) (handled, quit bool, err error) {
+	switch b {
+	case '.':
+		return true, true, nil
+	case 'r':
+		t.toggleMode(in, echo)
+	case '?':
+		fmt.Fprintf(echo, escapeHelp, t.escapeName())
+	case 'b':
+		if err := t.call.SendBreak(); err != nil {
+			notice(echo, "Break failed: %v", err)
+		} else {
+			notice(echo, "Break sent")
+		}
+	case 'm':
+		t.call.Mark("Marker by " + t.username)
+		notice(echo, "Marker added to session log at %s", time.Now().Format(time.TimeOnly))
+	case 's':
+		notice(echo, "%s", t.stats())
+	case 'p':
+		t.openPicker(in, echo)
+	case '#':
+		t.redial(in, echo)
+	default:
+		return false, false, nil
+	}
+	return true, false, nil
+}
+
+// stats summarizes the call for ~s.
+func (t *TerminalSession) stats() string {
+	mode := "line"
+	if t.raw.Load() {
+		mode = "raw"
+	}
+	now := time.Now()
+	rate := "unknown rate"
+	if r := t.call.ConnectRate(); r > 0 {
+		rate = fmt.Sprintf("%d bps", r)
+	}
+	return fmt.Sprintf("%s via %s at %s — up %s, idle %s, %s mode, %d bytes in / %d bytes out",
+		t.siteName, t.call.Device(), rate,
+		now.Sub(t.call.Started).Round(time.Second),
+		now.Sub(t.call.LastInput()).Round(time.Second),
+		mode, t.call.BytesIn(), t.call.BytesOut())
+}
+
+// redial hangs up and dials the site again, reporting progress inline.
+// The dial runs in the background so input keeps being read while it
+// retries: Ctrl+C or ~. still disconnect, and typing is dropped until the
+// line is back. If the redial fails the call ends, which ends the session.
+func (t *TerminalSession) redial(in *inputState, echo io.Writer) {
+	notice(echo, "Hanging up and redialing %s", t.siteName)
+	in.atLineStart = true
+	report := func(format string, args ...any) {
+		select {
+		case <-t.call.Done():
+			// Hung up meanwhile; the session has already said so.
+		default:
+			notice(echo, format, args...)
+		}
+	}
+	go func() {
+		err := t.call.Redial("requested by "+t.username, func(step string) {
+			report("%s", step)
+		})
+		if err != nil {
+			report("%v", err)
+			return
+		}
+		report("Reconnected to %s", t.siteName)
+	}()
+}
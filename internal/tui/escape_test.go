@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestEscape_LiteralTilde(t *testing.T) {
+	ts := &TerminalSession{}
+	r := strings.NewReader("\r~~home\r")
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(r, &modemBuf, &echoBuf)
+
+	if got, want := modemBuf.String(), "\r~home\r"; got != want {
+		t.Errorf("modem output = %q, want %q", got, want)
+	}
+}
+
+func TestEscape_Help(t *testing.T) {
+	ts := &TerminalSession{}
+	r := strings.NewReader("\r~?")
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(r, &modemBuf, &echoBuf)
+
+	if got := modemBuf.String(); got != "\r" {
+		t.Errorf("modem output = %q, want only the first CR", got)
+	}
+	for _, want := range []string{"~.  disconnect", "~b  send break", "~~  send a literal ~"} {
+		if !strings.Contains(echoBuf.String(), want) {
+			t.Errorf("help missing %q:\n%s", want, echoBuf.String())
+		}
+	}
+}
+
+func TestEscape_CustomChar(t *testing.T) {
+	ts := &TerminalSession{opts: TerminalOptions{EscapeChar: 0x1d}} // ^]
+	// ~. is now ordinary text; ^]. disconnects.
+	r := strings.NewReader("\r~.\r\x1d.after")
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(r, &modemBuf, &echoBuf)
+
+	if got, want := modemBuf.String(), "\r~.\r"; got != want {
+		t.Errorf("modem output = %q, want %q", got, want)
+	}
+	if ts.escapeName() != "^]" {
+		t.Errorf("escapeName = %q, want ^]", ts.escapeName())
+	}
+}
+
+func TestEscape_PickSnippet(t *testing.T) {
+	ts := &TerminalSession{siteName: "router1", username: "alice", opts: TerminalOptions{
+		Site: config.Site{Name: "router1"},
+		Snippets: []Snippet{
+			{Name: "nopage", Text: "terminal length 0\n", Source: "global"},
+			{Name: "ver", Text: "show version\nshow inventory\n", Source: "site"},
+			{Name: "host", Text: "! {{site.Name}} by {{user}}", Source: "mine"},
+		},
+	}}
+	r, pw := io.Pipe()
+	var modem, echo lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(r, &modem, &echo) }()
+
+	// Filter to "ver" and insert it: multi-line snippets are sent paced.
+	pw.Write([]byte("\r~pve\r"))
+	waitUntil(t, func() bool { return modem.String() == "\rshow version\rshow inventory\r" })
+	waitUntil(t, func() bool { return ts.pasting.Load() == nil })
+
+	// Arrow down past nopage to host; a snippet without a newline is
+	// inserted into the line for editing, with variables expanded.
+	pw.Write([]byte("~p\x1b[B\x1b[B\r!\r"))
+	waitUntil(t, func() bool { return strings.HasSuffix(modem.String(), "! router1 by alice!\r") })
+	pw.Close()
+	<-done
+
+	if got := echo.String(); !strings.Contains(got, altScreenOn) || !strings.Contains(got, altScreenOff) {
+		t.Errorf("expected the picker on the alternate screen, got %q", got)
+	}
+}
+
+func TestEscape_PickerCancel(t *testing.T) {
+	ts := &TerminalSession{opts: TerminalOptions{Snippets: []Snippet{{Name: "a", Text: "x"}}}}
+	r := strings.NewReader("\r~pa\x1bok\r")
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(r, &modemBuf, &echoBuf)
+
+	if got, want := modemBuf.String(), "\rok\r"; got != want {
+		t.Errorf("modem output = %q, want %q", got, want)
+	}
+}
+
+func TestEscape_NoSnippets(t *testing.T) {
+	ts := &TerminalSession{}
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(strings.NewReader("\r~p"), &modemBuf, &echoBuf)
+
+	if !strings.Contains(echoBuf.String(), "No stored snippets") {
+		t.Errorf("expected no-snippets notice, got %q", echoBuf.String())
+	}
+}
+
+func TestPausableWriter_HoldsOnlyRecentOutput(t *testing.T) {
+	var out bytes.Buffer
+	p := &pausableWriter{w: &out, limit: 8}
+	p.pause()
+	p.Write([]byte("0123456789"))
+	p.Write([]byte("abc"))
+	if out.Len() != 0 {
+		t.Fatalf("output written while paused: %q", out.String())
+	}
+	p.resume()
+	if got, want := out.String(), "\r\n*** 5 bytes of output dropped while the picker was open ***\r\n56789abc"; got != want {
+		t.Errorf("resumed output = %q, want %q", got, want)
+	}
+
+	out.Reset()
+	p.Write([]byte("live"))
+	if out.String() != "live" {
+		t.Errorf("output after resume = %q", out.String())
+	}
+}
+
+func TestEscape_RedialKeepsReadingInput(t *testing.T) {
+	mdm, dev, remote := testModem(t)
+	lock := modem.NewDeviceLock(dev)
+	if _, err := lock.Acquire("lab"); err != nil {
+		t.Fatal(err)
+	}
+	mgr := session.NewManager(t.TempDir(), 0, 1024, 0, nil)
+	call, err := mgr.Start("alice", session.Ticket{}, 0, config.Site{Name: "lab"}, dev, mdm, lock, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := NewTerminalSession(call, "alice", TerminalOptions{})
+
+	pr, pw := io.Pipe()
+	var echo lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(pr, call, &echo) }()
+
+	// The remote end never answers, so the redial is still dialing when
+	// the user disconnects.
+	pw.Write([]byte("\r~#"))
+	waitUntil(t, call.Redialing)
+	pw.Write([]byte("~."))
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("userToModem: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("~. not read while redialing")
+	}
+
+	call.Hangup("disconnected by alice")
+	remote.Close()
+	for deadline := time.Now().Add(10 * time.Second); !lock.IsAvailable(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("redial did not give up after the hangup")
+		}
+	}
+	if !strings.Contains(echo.String(), "Hanging up and redialing lab") {
+		t.Errorf("echo = %q, want the redial notice", echo.String())
+	}
+}
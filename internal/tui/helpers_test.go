@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"os"
+	"testing"
+
+	"github.com/creack/pty"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// newTestDeps returns the dependencies for a Model over menuSites with no
+// modem devices. users are added to the returned store with "password1".
+func newTestDeps(t *testing.T, users ...string) (Deps, *auth.FileStore) {
+	t.Helper()
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if err := store.Add(u, "password1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Deps{
+		Config: config.AppConfig{UserDataDir: t.TempDir()},
+		Sites:  menuSites,
+		Lock:   modem.NewDeviceLock(),
+		Store:  store,
+		Calls:  session.NewManager(t.TempDir(), 0, 1024, 0, nil),
+	}, store
+}
+
+// testModem opens a modem on a PTY pair. It returns the device path and
+// the master side, which plays the remote end; closing it drops carrier.
+func testModem(t *testing.T) (mdm *modem.Modem, dev string, remote *os.File) {
+	t.Helper()
+	ptmx, pts, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open: %v", err)
+	}
+	t.Cleanup(func() { pts.Close() })
+	mdm, err = modem.Open(pts.Name())
+	if err != nil {
+		t.Fatalf("modem.Open: %v", err)
+	}
+	return mdm, pts.Name(), ptmx
+}
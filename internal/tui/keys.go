@@ -0,0 +1,37 @@
+package tui
+
+import "bufio"
+
+// Escape sequences sent by common terminals for editing keys, without the
+// leading ESC.
+const (
+	seqUp    = "[A"
+	seqDown  = "[B"
+	seqRight = "[C"
+	seqLeft  = "[D"
+)
+
+// readEscSequence reads the rest of a CSI or SS3 sequence after an ESC
+// already read from br, such as "[A" for the up arrow. Like atPasteStart it
+// only looks at input that has already arrived, so a lone ESC key returns "".
+func readEscSequence(br *bufio.Reader) string {
+	if br.Buffered() == 0 {
+		return ""
+	}
+	next, _ := br.Peek(1)
+	if next[0] != '[' && next[0] != 'O' {
+		return ""
+	}
+	seq := []byte{next[0]}
+	br.Discard(1)
+	for br.Buffered() > 0 {
+		b, _ := br.ReadByte()
+		seq = append(seq, b)
+		// CSI parameters are digits and ;, and the final byte is a letter
+		// or ~. SS3 sequences are a single letter.
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	return string(seq)
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// limitWarnings are the countdown points at which a pending idle or
+// duration hangup is announced in the terminal.
+var limitWarnings = []time.Duration{5 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second}
+
+// callLimit describes whichever session limit will trip first.
+type callLimit struct {
+	idle      bool          // idle timeout rather than maximum duration
+	reason    string        // logged as the hangup reason
+	remaining time.Duration // time left before hangup
+}
+
+// nextLimit returns the limit that expires soonest, or false if neither the
+// idle timeout nor the maximum duration applies.
+func nextLimit(now, started, lastInput time.Time, idle, max time.Duration) (callLimit, bool) {
+	var limit callLimit
+	found := false
+	if idle > 0 {
+		limit = callLimit{
+			idle:      true,
+			reason:    fmt.Sprintf("idle timeout (%s)", idle),
+			remaining: idle - now.Sub(lastInput),
+		}
+		found = true
+	}
+	if max > 0 {
+		remaining := max - now.Sub(started)
+		if !found || remaining < limit.remaining {
+			limit = callLimit{
+				reason:    fmt.Sprintf("maximum call duration (%s)", max),
+				remaining: remaining,
+			}
+			found = true
+		}
+	}
+	return limit, found
+}
+
+// warningTier returns the index of the tightest countdown point that
+// remaining has crossed, or -1 if none has been reached yet.
+func warningTier(remaining time.Duration) int {
+	tier := -1
+	for i, w := range limitWarnings {
+		if remaining <= w {
+			tier = i
+		}
+	}
+	return tier
+}
+
+// enforceLimits hangs the call up when the idle timeout or maximum duration
+// expires, printing countdown warnings as each threshold is crossed. Typing
+// resets the idle clock and re-arms the warnings.
+func (t *TerminalSession) enforceLimits(out io.Writer, stop <-chan struct{}) {
+	if t.opts.IdleTimeout <= 0 && t.opts.MaxDuration <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastTier := -1
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.call.Done():
+			return
+		case now := <-ticker.C:
+			limit, ok := nextLimit(now, t.call.Started, t.call.LastInput(), t.opts.IdleTimeout, t.opts.MaxDuration)
+			if !ok {
+				return
+			}
+			if limit.remaining <= 0 {
+				fmt.Fprintf(out, "\r\n*** Hanging up: %s ***\r\n", limit.reason)
+				t.call.Hangup(limit.reason)
+				return
+			}
+			tier := warningTier(limit.remaining)
+			if tier > lastTier {
+				left := limit.remaining.Round(time.Second)
+				if limit.idle {
+					fmt.Fprintf(out, "\r\n*** Idle — hanging up in %s unless you type something ***\r\n", left)
+				} else {
+					fmt.Fprintf(out, "\r\n*** Maximum call duration — hanging up in %s ***\r\n", left)
+				}
+			}
+			lastTier = tier
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextLimit(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		lastInput time.Time
+		idle, max time.Duration
+		wantOK    bool
+		wantIdle  bool
+		wantLeft  time.Duration
+	}{
+		{
+			name:   "no limits",
+			now:    start.Add(time.Hour),
+			wantOK: false,
+		},
+		{
+			name:      "idle only",
+			now:       start.Add(20 * time.Minute),
+			lastInput: start.Add(5 * time.Minute),
+			idle:      30 * time.Minute,
+			wantOK:    true,
+			wantIdle:  true,
+			wantLeft:  15 * time.Minute,
+		},
+		{
+			name:      "max duration sooner than idle",
+			now:       start.Add(110 * time.Minute),
+			lastInput: start.Add(109 * time.Minute),
+			idle:      30 * time.Minute,
+			max:       2 * time.Hour,
+			wantOK:    true,
+			wantIdle:  false,
+			wantLeft:  10 * time.Minute,
+		},
+		{
+			name:      "idle expired",
+			now:       start.Add(31 * time.Minute),
+			lastInput: start,
+			idle:      30 * time.Minute,
+			max:       2 * time.Hour,
+			wantOK:    true,
+			wantIdle:  true,
+			wantLeft:  -time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lastInput := tt.lastInput
+			if lastInput.IsZero() {
+				lastInput = start
+			}
+			got, ok := nextLimit(tt.now, start, lastInput, tt.idle, tt.max)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.idle != tt.wantIdle {
+				t.Errorf("idle = %v, want %v (%s)", got.idle, tt.wantIdle, got.reason)
+			}
+			if got.remaining != tt.wantLeft {
+				t.Errorf("remaining = %s, want %s", got.remaining, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestWarningTier(t *testing.T) {
+	tests := []struct {
+		remaining time.Duration
+		want      int
+	}{
+		{10 * time.Minute, -1},
+		{5 * time.Minute, 0},
+		{90 * time.Second, 0},
+		{time.Minute, 1},
+		{25 * time.Second, 2},
+		{5 * time.Second, 3},
+	}
+	for _, tt := range tests {
+		if got := warningTier(tt.remaining); got != tt.want {
+			t.Errorf("warningTier(%s) = %d, want %d", tt.remaining, got, tt.want)
+		}
+	}
+}
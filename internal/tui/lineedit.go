@@ -0,0 +1,350 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// historySearch is an active Ctrl+R reverse search.
+type historySearch struct {
+	query []byte
+	match int // index into hist of the current match, -1 if none
+}
+
+// passwordPrompt matches remote output asking for a secret, so the reply
+// is not saved to history.
+var passwordPrompt = regexp.MustCompile(`(?i)(password|passphrase|passcode|secret)[^\n]*:\s*$`)
+
+// lineInput handles one key in line mode. Text is echoed locally and sent
+// on Enter, with readline-style editing:
+//
+//	Ctrl+A/Ctrl+E, Home/End    start/end of line
+//	Ctrl+B/Ctrl+F, ←/→         move one character
+//	Backspace, Ctrl+D, Delete  delete before/at the cursor
+//	Ctrl+U/Ctrl+K              delete to start/end of line
+//	Ctrl+W                     delete the word before the cursor
+//	Ctrl+P/Ctrl+N, ↑/↓         previous/next command in history
+//	Ctrl+R                     reverse search through history
+//	Ctrl+C                     disconnect
+func (t *TerminalSession) lineInput(in *inputState, b byte, w io.Writer, echo io.Writer) (bool, error) {
+	if in.search != nil {
+		return false, t.searchKey(in, b, w, echo)
+	}
+
+	switch b {
+	case 0x03: // Ctrl+C: disconnect immediately
+		return true, nil
+	case '\r', '\n':
+		return false, t.submitLine(in, w, echo)
+	case 0x7f, 0x08:
+		in.backspace(echo)
+	case 0x01: // Ctrl+A
+		in.moveTo(echo, 0)
+	case 0x05: // Ctrl+E
+		in.moveTo(echo, len(in.lineBuf))
+	case 0x02: // Ctrl+B
+		in.moveTo(echo, in.cursor-1)
+	case 0x06: // Ctrl+F
+		in.moveTo(echo, in.cursor+1)
+	case 0x04: // Ctrl+D
+		in.deleteAt(echo)
+	case 0x15: // Ctrl+U
+		in.lineBuf = slices.Delete(in.lineBuf, 0, in.cursor)
+		in.cursor = 0
+		in.redraw(echo)
+	case 0x0b: // Ctrl+K
+		in.lineBuf = in.lineBuf[:in.cursor]
+		in.redraw(echo)
+	case 0x17: // Ctrl+W
+		in.deleteWord(echo)
+	case 0x10: // Ctrl+P
+		in.historyMove(echo, -1)
+	case 0x0e: // Ctrl+N
+		in.historyMove(echo, 1)
+	case 0x12: // Ctrl+R
+		in.search = &historySearch{match: -1}
+		in.drawSearch(echo)
+	case 0x1b:
+		// A lone Esc has nothing to do in line mode.
+	default:
+		in.insert(echo, []byte{b})
+	}
+	in.atLineStart = in.atLineStart && len(in.lineBuf) == 0
+	return false, nil
+}
+
+// lineSeq handles an escape sequence, such as an arrow key, in line mode.
+func (t *TerminalSession) lineSeq(in *inputState, seq string, w io.Writer, echo io.Writer) error {
+	if in.escPending {
+		// The escape character was not followed by a command.
+		in.escPending = false
+		if _, err := t.handleInput(in, t.escapeChar(), w, echo); err != nil {
+			return err
+		}
+	}
+	if in.search != nil {
+		in.acceptSearch(echo)
+		return nil
+	}
+
+	switch seq {
+	case seqUp, "OA":
+		in.historyMove(echo, -1)
+	case seqDown, "OB":
+		in.historyMove(echo, 1)
+	case seqLeft, "OD":
+		in.moveTo(echo, in.cursor-1)
+	case seqRight, "OC":
+		in.moveTo(echo, in.cursor+1)
+	case "[H", "OH", "[1~", "[7~":
+		in.moveTo(echo, 0)
+	case "[F", "OF", "[4~", "[8~":
+		in.moveTo(echo, len(in.lineBuf))
+	case "[3~":
+		in.deleteAt(echo)
+	}
+	in.atLineStart = in.atLineStart && len(in.lineBuf) == 0
+	return nil
+}
+
+// submitLine sends the line to the modem and records it in history.
+func (t *TerminalSession) submitLine(in *inputState, w io.Writer, echo io.Writer) error {
+	// Echo the newline locally
+	echo.Write([]byte("\r\n"))
+
+	// Send buffered line + CR to modem (just CR on an empty line)
+	line := string(in.lineBuf)
+	if _, err := io.WriteString(w, line+"\r"); err != nil {
+		return err
+	}
+	t.recordHistory(in, line)
+
+	in.resetLine()
+	in.atLineStart = true
+	return nil
+}
+
+// recordHistory adds line to the session and persistent history, unless it
+// is blank, starts with a space, or answers a password prompt.
+func (t *TerminalSession) recordHistory(in *inputState, line string) {
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, " ") {
+		return
+	}
+	if t.call != nil && passwordPrompt.Match(t.call.RecentOutput(200)) {
+		return
+	}
+	if n := len(in.hist); n == 0 || in.hist[n-1] != line {
+		in.hist = append(in.hist, line)
+	}
+	if t.opts.History != nil {
+		if err := t.opts.History.Add(line); err != nil {
+			slog.Warn("saving command history", "user", t.username, "site", t.siteName, "err", err)
+		}
+	}
+}
+
+// resetLine clears the line after it is sent or discarded.
+func (in *inputState) resetLine() {
+	in.lineBuf = in.lineBuf[:0]
+	in.cursor = 0
+	in.shown = 0
+	in.histPos = len(in.hist)
+	in.draft = nil
+	in.search = nil
+}
+
+// paint rewrites the line on screen as text with the cursor at col,
+// starting from wherever the cursor was last left.
+func (in *inputState) paint(echo io.Writer, text []byte, col int) {
+	var sb strings.Builder
+	if in.shown > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dD", in.shown)
+	}
+	sb.Write(text)
+	sb.WriteString("\x1b[K")
+	if back := len(text) - col; back > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dD", back)
+	}
+	io.WriteString(echo, sb.String())
+	in.shown = col
+}
+
+func (in *inputState) redraw(echo io.Writer) {
+	in.paint(echo, in.lineBuf, in.cursor)
+}
+
+// insert adds text at the cursor. Typing at the end of the line is simply
+// echoed; anything else repaints the line.
+func (in *inputState) insert(echo io.Writer, text []byte) {
+	if in.cursor == len(in.lineBuf) {
+		in.lineBuf = append(in.lineBuf, text...)
+		in.cursor += len(text)
+		in.shown += len(text)
+		echo.Write(text)
+		return
+	}
+	in.lineBuf = slices.Insert(in.lineBuf, in.cursor, text...)
+	in.cursor += len(text)
+	in.redraw(echo)
+}
+
+func (in *inputState) backspace(echo io.Writer) {
+	if in.cursor == 0 {
+		return
+	}
+	if in.cursor == len(in.lineBuf) {
+		in.lineBuf = in.lineBuf[:len(in.lineBuf)-1]
+		in.cursor--
+		in.shown--
+		// Erase character on terminal: backspace, space, backspace
+		echo.Write([]byte{0x08, ' ', 0x08})
+		return
+	}
+	in.lineBuf = slices.Delete(in.lineBuf, in.cursor-1, in.cursor)
+	in.cursor--
+	in.redraw(echo)
+}
+
+func (in *inputState) deleteAt(echo io.Writer) {
+	if in.cursor < len(in.lineBuf) {
+		in.lineBuf = slices.Delete(in.lineBuf, in.cursor, in.cursor+1)
+		in.redraw(echo)
+	}
+}
+
+// deleteWord deletes back to the start of the word before the cursor.
+func (in *inputState) deleteWord(echo io.Writer) {
+	i := in.cursor
+	for i > 0 && in.lineBuf[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && in.lineBuf[i-1] != ' ' {
+		i--
+	}
+	if i == in.cursor {
+		return
+	}
+	in.lineBuf = slices.Delete(in.lineBuf, i, in.cursor)
+	in.cursor = i
+	in.redraw(echo)
+}
+
+// moveTo moves the cursor to pos, clamped to the line.
+func (in *inputState) moveTo(echo io.Writer, pos int) {
+	pos = max(0, min(pos, len(in.lineBuf)))
+	switch {
+	case pos < in.shown:
+		fmt.Fprintf(echo, "\x1b[%dD", in.shown-pos)
+	case pos > in.shown:
+		fmt.Fprintf(echo, "\x1b[%dC", pos-in.shown)
+	}
+	in.cursor = pos
+	in.shown = pos
+}
+
+// historyMove steps through history; stepping past the newest entry
+// returns to the line being typed.
+func (in *inputState) historyMove(echo io.Writer, delta int) {
+	pos := in.histPos + delta
+	if pos < 0 || pos > len(in.hist) {
+		return
+	}
+	if in.histPos == len(in.hist) {
+		in.draft = slices.Clone(in.lineBuf)
+	}
+	in.histPos = pos
+	if pos == len(in.hist) {
+		in.setLine(echo, in.draft)
+	} else {
+		in.setLine(echo, []byte(in.hist[pos]))
+	}
+}
+
+func (in *inputState) setLine(echo io.Writer, text []byte) {
+	in.lineBuf = append(in.lineBuf[:0], text...)
+	in.cursor = len(in.lineBuf)
+	in.redraw(echo)
+}
+
+// searchKey handles a key during Ctrl+R search. Typing narrows the search,
+// Ctrl+R finds the next older match, Enter runs the match, Esc or Ctrl+G
+// cancels, and any other control key keeps the match for editing.
+func (t *TerminalSession) searchKey(in *inputState, b byte, w io.Writer, echo io.Writer) error {
+	s := in.search
+	switch {
+	case b == '\r' || b == '\n':
+		in.acceptSearch(echo)
+		return t.submitLine(in, w, echo)
+	case b == 0x1b || b == 0x07 || b == 0x03:
+		in.search = nil
+		in.redraw(echo)
+		return nil
+	case b == 0x12:
+		in.findHistory(s.match - 1)
+	case b == 0x7f || b == 0x08:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+		}
+		in.findHistory(len(in.hist) - 1)
+	case b >= ' ':
+		s.query = append(s.query, b)
+		start := s.match
+		if start < 0 {
+			start = len(in.hist) - 1
+		}
+		in.findHistory(start)
+	default:
+		in.acceptSearch(echo)
+		return nil
+	}
+	in.drawSearch(echo)
+	return nil
+}
+
+// findHistory sets the search match to the newest entry at or before
+// from containing the query.
+func (in *inputState) findHistory(from int) {
+	s := in.search
+	for i := min(from, len(in.hist)-1); i >= 0; i-- {
+		if strings.Contains(in.hist[i], string(s.query)) {
+			s.match = i
+			return
+		}
+	}
+	if len(s.query) == 0 {
+		s.match = -1
+	}
+	// Otherwise keep the previous match, as readline does.
+}
+
+func (in *inputState) drawSearch(echo io.Writer) {
+	s := in.search
+	label := "reverse-i-search"
+	var match string
+	if s.match >= 0 {
+		match = in.hist[s.match]
+		if !strings.Contains(match, string(s.query)) {
+			label = "failed " + label
+		}
+	} else if len(s.query) > 0 {
+		label = "failed " + label
+	}
+	text := fmt.Sprintf("(%s)`%s': %s", label, s.query, match)
+	in.paint(echo, []byte(text), len(text))
+}
+
+// acceptSearch ends the search, leaving the match on the line for editing.
+func (in *inputState) acceptSearch(echo io.Writer) {
+	s := in.search
+	in.search = nil
+	if s.match >= 0 {
+		in.histPos = len(in.hist)
+		in.lineBuf = append(in.lineBuf[:0], in.hist[s.match]...)
+		in.cursor = len(in.lineBuf)
+	}
+	in.redraw(echo)
+}
@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func lineModeSend(t *testing.T, ts *TerminalSession, input string) string {
+	t.Helper()
+	var modem, echo bytes.Buffer
+	ts.userToModem(strings.NewReader(input), &modem, &echo)
+	return modem.String()
+}
+
+func TestLineEdit_CursorMovement(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"insert after left arrow", "shw\x1b[Do\r", "show\r"},
+		{"ctrl+b and ctrl+f", "sow\x02\x02h\x06\x06 \r", "show \r"},
+		{"home and end", "how\x1b[Hs\x1b[F ver\r", "show ver\r"},
+		{"home and end variants", "how\x1b[1~s\x1bOF!\r", "show!\r"},
+		{"ctrl+a and ctrl+e", "how\x01s\x05 ip\r", "show ip\r"},
+		{"backspace mid-line", "shxow\x1b[D\x1b[D\x7f\r", "show\r"},
+		{"delete key", "shxow\x1b[D\x1b[D\x1b[D\x1b[3~\r", "show\r"},
+		{"ctrl+d deletes under cursor", "shxow\x01\x06\x06\x04\r", "show\r"},
+		{"ctrl+u clears line", "reload\x15show\r", "show\r"},
+		{"ctrl+k kills to end", "show version\x01\x06\x06\x06\x06\x0b\r", "show\r"},
+		{"ctrl+w deletes word", "show running-config\x17ip route\r", "show ip route\r"},
+		{"ctrl+w skips trailing spaces", "show ver  \x17int\r", "show int\r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineModeSend(t, &TerminalSession{}, tt.input); got != tt.want {
+				t.Errorf("modem output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEdit_History(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"up recalls previous", "show ver\r\x1b[A\r", "show ver\rshow ver\r"},
+		{"up twice", "one\rtwo\r\x1b[A\x1b[A\r", "one\rtwo\rone\r"},
+		{"ctrl+p and edit", "show ver\r\x10\x17int\r", "show ver\rshow int\r"},
+		{"down returns to draft", "one\rtw\x1b[A\x1b[Bo\r", "one\rtwo\r"},
+		{"up stops at oldest", "one\r\x1b[A\x1b[A\x1b[A\r", "one\rone\r"},
+		{"leading space not recorded", "one\r secret\r\x1b[A\r", "one\r secret\rone\r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineModeSend(t, &TerminalSession{}, tt.input); got != tt.want {
+				t.Errorf("modem output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEdit_ReverseSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"enter runs match", "show ip route\rshow ver\r\x12ip\r", "show ip route\rshow ver\rshow ip route\r"},
+		{"ctrl+r finds older", "show int e0\rshow int e1\r\x12int\x12\r", "show int e0\rshow int e1\rshow int e0\r"},
+		{"edit match", "show ver\r\x12ver\x05 | inc IOS\r", "show ver\rshow ver | inc IOS\r"},
+		{"esc cancels", "show ver\rsh\x12ver\x1b\r", "show ver\rsh\r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineModeSend(t, &TerminalSession{}, tt.input); got != tt.want {
+				t.Errorf("modem output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEdit_PersistentHistory(t *testing.T) {
+	dir := t.TempDir()
+	h, err := session.LoadHistory(dir, "alice", "router1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineModeSend(t, &TerminalSession{opts: TerminalOptions{History: h}}, "show clock\r")
+
+	// A later session for the same user and site starts with it.
+	h, err = session.LoadHistory(dir, "alice", "router1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := lineModeSend(t, &TerminalSession{opts: TerminalOptions{History: h}}, "\x1b[A\r")
+	if got != "show clock\r" {
+		t.Errorf("modem output = %q, want %q", got, "show clock\r")
+	}
+}
+
+func TestLineEdit_RedrawsMidLineInsert(t *testing.T) {
+	ts := &TerminalSession{}
+	var modem, echo bytes.Buffer
+	ts.userToModem(strings.NewReader("ac\x1b[Db"), &modem, &echo)
+	// Cursor back one, then the rest of the line rewritten and the cursor
+	// put back after the inserted character.
+	if got, want := echo.String(), "ac\x1b[1D\x1b[1Dabc\x1b[K\x1b[1D"; got != want {
+		t.Errorf("echo = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,437 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// maxLogView is how much of a log the viewer loads; longer logs are shown
+// from the end.
+const maxLogView = 4 << 20
+
+// logDateFormat is how dates are shown in the list and matched by date:.
+const logDateFormat = "2006-01-02"
+
+// LogsModel browses past session logs: a filterable list and a viewer with
+// search. It only offers logs the user may read.
+type LogsModel struct {
+	all     []session.LogEntry
+	shown   []session.LogEntry
+	cursor  int
+	filter  textinput.Model
+	editing bool // typing in the filter
+	viewer  *logViewer
+	err     string
+	width   int
+	height  int
+	theme   Theme
+}
+
+// NewLogsModel lists the logs in dir for which readable returns true.
+func NewLogsModel(dir string, readable func(session.LogEntry) bool, width, height int, theme Theme) LogsModel {
+	in := textinput.New()
+	in.Prompt = "Filter: "
+	in.Placeholder = "site:name user:name date:2026-10-18"
+	m := LogsModel{
+		filter: in,
+		width:  width,
+		height: height,
+		theme:  theme,
+	}
+	logs, err := session.ListLogs(dir)
+	if err != nil {
+		m.err = err.Error()
+	}
+	for _, e := range logs {
+		if readable(e) {
+			m.all = append(m.all, e)
+		}
+	}
+	m.applyFilter()
+	return m
+}
+
+// matchLog reports whether e matches every term of a filter. site:, user:
+// and date: terms match those fields by prefix; other words match the site
+// or user anywhere.
+func matchLog(e session.LogEntry, filter string) bool {
+	site, user := strings.ToLower(e.Site), strings.ToLower(e.User)
+	date := e.Started.Local().Format(logDateFormat)
+	for _, term := range strings.Fields(strings.ToLower(filter)) {
+		var ok bool
+		switch {
+		case strings.HasPrefix(term, "site:"):
+			ok = strings.HasPrefix(site, term[len("site:"):])
+		case strings.HasPrefix(term, "user:"):
+			ok = strings.HasPrefix(user, term[len("user:"):])
+		case strings.HasPrefix(term, "date:"):
+			ok = strings.HasPrefix(date, term[len("date:"):])
+		default:
+			ok = strings.Contains(site, term) || strings.Contains(user, term)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *LogsModel) applyFilter() {
+	m.shown = nil
+	for _, e := range m.all {
+		if matchLog(e, m.filter.Value()) {
+			m.shown = append(m.shown, e)
+		}
+	}
+	m.cursor = min(m.cursor, max(len(m.shown)-1, 0))
+}
+
+func (m LogsModel) Init() tea.Cmd { return nil }
+
+func (m LogsModel) Update(msg tea.Msg) (LogsModel, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width, m.height = size.Width, size.Height
+		return m, nil
+	}
+	if m.viewer != nil {
+		return m.updateViewer(msg)
+	}
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.editing {
+		switch key.String() {
+		case "enter":
+			m.editing = false
+			m.filter.Blur()
+			return m, nil
+		case "esc":
+			m.editing = false
+			m.filter.Blur()
+			m.filter.SetValue("")
+			m.applyFilter()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.filter, cmd = m.filter.Update(msg)
+		m.applyFilter()
+		return m, cmd
+	}
+
+	switch key.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.shown)-1, 0))
+	case "pgup":
+		m.cursor = max(m.cursor-m.listRows(), 0)
+	case "pgdown":
+		m.cursor = min(m.cursor+m.listRows(), max(len(m.shown)-1, 0))
+	case "/":
+		m.editing = true
+		return m, m.filter.Focus()
+	case "enter":
+		if m.cursor < len(m.shown) {
+			v, err := openLog(m.shown[m.cursor])
+			if err != nil {
+				m.err = err.Error()
+				return m, nil
+			}
+			m.err = ""
+			m.viewer = v
+		}
+	case "esc", "q":
+		return m, func() tea.Msg { return LogsDoneMsg{} }
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// listRows is how many logs fit on screen.
+func (m LogsModel) listRows() int {
+	return max(m.height-10, 3)
+}
+
+func (m LogsModel) View() string {
+	if m.viewer != nil {
+		return m.viewer.view(m.width, m.viewRows(), m.theme)
+	}
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Session Logs"))
+	b.WriteString("\n\n")
+	if m.editing || m.filter.Value() != "" {
+		b.WriteString("  " + m.filter.View() + "\n\n")
+	}
+	fmt.Fprintf(&b, "  %-16s %-20s %-12s %-14s %s\n", "DATE", "SITE", "USER", "DEVICE", "SIZE")
+	rows := m.listRows()
+	start := max(0, m.cursor-rows+1)
+	for i := start; i < len(m.shown) && i < start+rows; i++ {
+		e := m.shown[i]
+		user := e.User
+		if user == "" {
+			user = "—"
+		}
+		line := fmt.Sprintf("%-16s %-20s %-12s %-14s %s",
+			e.Started.Local().Format(logDateFormat+" 15:04"), truncate(e.Site, 20), truncate(user, 12),
+			truncate(e.Device, 14), humanBytes(e.Size))
+		if i == m.cursor {
+			b.WriteString(m.theme.InputStyle.Render("> " + line))
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	if len(m.shown) == 0 {
+		b.WriteString(m.theme.LabelStyle.Render("  No logs."))
+		b.WriteString("\n")
+	}
+	if m.err != "" {
+		b.WriteString("\n" + m.theme.ErrorStyle.Render("  "+m.err) + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(m.theme.LabelStyle.Render(fmt.Sprintf("  %d of %d · enter view · / filter · esc back", len(m.shown), len(m.all))))
+	return m.theme.BoxStyle.Render(b.String())
+}
+
+// viewRows is how many log lines the viewer shows.
+func (m LogsModel) viewRows() int {
+	return max(m.height-8, 3)
+}
+
+// logViewer is a scrollable, searchable view of one log.
+type logViewer struct {
+	entry     session.LogEntry
+	lines     []string
+	top       int
+	truncated bool // only the end of the log was loaded
+
+	search    textinput.Model
+	searching bool
+	query     string
+	matches   []int // lines containing query
+	match     int   // index into matches of the current match
+}
+
+func openLog(e session.LogEntry) (*logViewer, error) {
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return nil, fmt.Errorf("opening log: %w", err)
+	}
+	defer f.Close()
+	v := &logViewer{entry: e}
+	if e.Size > maxLogView {
+		f.Seek(-maxLogView, io.SeekEnd)
+		v.truncated = true
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxLogView))
+	if err != nil {
+		return nil, fmt.Errorf("reading log: %w", err)
+	}
+	v.lines = cleanLogLines(data)
+	v.search = textinput.New()
+	v.search.Prompt = "/"
+	return v, nil
+}
+
+// cleanLogLines splits raw modem output into printable lines, dropping
+// escape sequences, carriage returns and other control characters.
+func cleanLogLines(data []byte) []string {
+	var lines []string
+	var line strings.Builder
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\n':
+			lines = append(lines, line.String())
+			line.Reset()
+		case c == '\t':
+			line.WriteString("    ")
+		case c == 0x1b:
+			// Skip a CSI sequence up to its final byte, or the one
+			// character after ESC otherwise.
+			if i+1 < len(data) && data[i+1] == '[' {
+				i += 2
+				for i < len(data) && (data[i] < 0x40 || data[i] > 0x7e) {
+					i++
+				}
+			} else {
+				i++
+			}
+		case c < ' ' || c == 0x7f:
+		default:
+			line.WriteByte(c)
+		}
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+func (m LogsModel) updateViewer(msg tea.Msg) (LogsModel, tea.Cmd) {
+	v := m.viewer
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	rows := m.viewRows()
+	last := max(len(v.lines)-rows, 0)
+
+	if v.searching {
+		switch key.String() {
+		case "enter":
+			v.searching = false
+			v.search.Blur()
+			v.find(v.search.Value())
+			v.jump(rows)
+			return m, nil
+		case "esc":
+			v.searching = false
+			v.search.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		v.search, cmd = v.search.Update(msg)
+		return m, cmd
+	}
+
+	switch key.String() {
+	case "up", "k":
+		v.top--
+	case "down", "j", "enter":
+		v.top++
+	case "pgup", "b":
+		v.top -= rows
+	case "pgdown", " ", "f":
+		v.top += rows
+	case "home", "g":
+		v.top = 0
+	case "end", "G":
+		v.top = last
+	case "/":
+		v.searching = true
+		v.search.SetValue("")
+		return m, v.search.Focus()
+	case "n":
+		if len(v.matches) > 0 {
+			v.match = (v.match + 1) % len(v.matches)
+			v.jump(rows)
+		}
+	case "N":
+		if len(v.matches) > 0 {
+			v.match = (v.match - 1 + len(v.matches)) % len(v.matches)
+			v.jump(rows)
+		}
+	case "esc", "q":
+		m.viewer = nil
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	v.top = min(max(v.top, 0), last)
+	return m, nil
+}
+
+// find collects the lines matching query, case-insensitively, starting
+// from the first match at or below the top of the screen.
+func (v *logViewer) find(query string) {
+	v.query, v.matches, v.match = query, nil, 0
+	if query == "" {
+		return
+	}
+	needle := strings.ToLower(query)
+	for i, l := range v.lines {
+		if strings.Contains(strings.ToLower(l), needle) {
+			v.matches = append(v.matches, i)
+		}
+	}
+	for i, line := range v.matches {
+		if line >= v.top {
+			v.match = i
+			break
+		}
+	}
+}
+
+// jump scrolls the current match into view, a few lines from the top.
+func (v *logViewer) jump(rows int) {
+	if len(v.matches) == 0 {
+		return
+	}
+	v.top = min(max(v.matches[v.match]-2, 0), max(len(v.lines)-rows, 0))
+}
+
+func (v *logViewer) view(width, rows int, theme Theme) string {
+	var b strings.Builder
+	user := ""
+	if v.entry.User != "" {
+		user = " by " + v.entry.User
+	}
+	b.WriteString(theme.TitleStyle.Render(fmt.Sprintf("%s%s — %s", v.entry.Site, user, v.entry.Started.Local().Format(logDateFormat+" 15:04"))))
+	b.WriteString("\n")
+
+	highlight := theme.NewStyle().Reverse(true)
+	current := -1
+	if len(v.matches) > 0 {
+		current = v.matches[v.match]
+	}
+	cols := max(width-4, 20)
+	for i := v.top; i < len(v.lines) && i < v.top+rows; i++ {
+		gutter := "  "
+		if i == current {
+			gutter = "> "
+		}
+		b.WriteString(gutter + highlightMatches(truncate(v.lines[i], cols), v.query, highlight.Render) + "\n")
+	}
+	for i := len(v.lines) - v.top; i < rows; i++ {
+		b.WriteString("\n")
+	}
+
+	if v.searching {
+		b.WriteString(v.search.View())
+		return b.String()
+	}
+	status := fmt.Sprintf("lines %d-%d of %d", min(v.top+1, len(v.lines)), min(v.top+rows, len(v.lines)), len(v.lines))
+	if v.query != "" {
+		if len(v.matches) == 0 {
+			status += fmt.Sprintf(" · %q not found", v.query)
+		} else {
+			status += fmt.Sprintf(" · match %d of %d", v.match+1, len(v.matches))
+		}
+	}
+	if v.truncated {
+		status += " · showing the last 4 MiB"
+	}
+	b.WriteString(theme.LabelStyle.Render(status + " · / search · n/N next/prev · g/G top/end · esc back"))
+	return b.String()
+}
+
+// highlightMatches renders each case-insensitive occurrence of query in
+// line with mark.
+func highlightMatches(line, query string, mark func(...string) string) string {
+	if query == "" {
+		return line
+	}
+	lower, needle := strings.ToLower(line), strings.ToLower(query)
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, needle)
+		if i < 0 || len(lower) != len(line) {
+			break
+		}
+		b.WriteString(line[:i])
+		b.WriteString(mark(line[i : i+len(needle)]))
+		line, lower = line[i+len(needle):], lower[i+len(needle):]
+	}
+	b.WriteString(line)
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// writeTestLog writes a log with the header NewLogger would.
+func writeTestLog(t *testing.T, dir, site, user, body string) {
+	t.Helper()
+	header := fmt.Sprintf("=== Session: %s | Device: /dev/ttyIAX0 | User: %s | Started: %s ===\n",
+		site, user, time.Now().Format(time.RFC3339))
+	if err := os.WriteFile(filepath.Join(dir, site+"_"+user+".log"), []byte(header+body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func logSites(m LogsModel) []string {
+	var out []string
+	for _, e := range m.shown {
+		out = append(out, e.Site+"/"+e.User)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestLogs_FilterAndPermissions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestLog(t, dir, "router1", "alice", "")
+	writeTestLog(t, dir, "router1", "bob", "")
+	writeTestLog(t, dir, "switch1", "alice", "")
+	os.WriteFile(filepath.Join(dir, "dials.jsonl"), []byte("{}\n"), 0644)
+
+	mine := func(e session.LogEntry) bool { return e.User == "alice" }
+	m := NewLogsModel(dir, mine, 80, 40, NewTheme(nil))
+	if got, want := logSites(m), []string{"router1/alice", "switch1/alice"}; !slices.Equal(got, want) {
+		t.Fatalf("logs = %q, want only alice's", got)
+	}
+
+	all := func(session.LogEntry) bool { return true }
+	m = NewLogsModel(dir, all, 80, 40, NewTheme(nil))
+	today := time.Now().Format(logDateFormat)
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"router1/alice", "router1/bob", "switch1/alice"}},
+		{"site:router", []string{"router1/alice", "router1/bob"}},
+		{"user:bob", []string{"router1/bob"}},
+		{"alice switch", []string{"switch1/alice"}},
+		{"date:" + today, []string{"router1/alice", "router1/bob", "switch1/alice"}},
+		{"date:1999", nil},
+	}
+	for _, tt := range tests {
+		m.filter.SetValue(tt.filter)
+		m.applyFilter()
+		if got := logSites(m); !slices.Equal(got, tt.want) {
+			t.Errorf("filter %q = %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestLogs_ViewerSearch(t *testing.T) {
+	dir := t.TempDir()
+	var body strings.Builder
+	for i := range 100 {
+		if i == 10 || i == 80 {
+			body.WriteString("\x1b[1m%ERROR\x1b[0m: link down\r\n")
+		} else {
+			body.WriteString("router1# show ip route\r\n")
+		}
+	}
+	writeTestLog(t, dir, "router1", "alice", body.String())
+
+	m := NewLogsModel(dir, func(session.LogEntry) bool { return true }, 80, 30, NewTheme(nil))
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.viewer == nil {
+		t.Fatal("viewer not opened")
+	}
+	press := func(keys ...string) {
+		for _, k := range keys {
+			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+			if k == "enter" {
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			}
+			m, _ = m.Update(msg)
+		}
+	}
+	press("/", "error", "enter")
+	v := m.viewer
+	if len(v.matches) != 2 {
+		t.Fatalf("matches = %v, want 2", v.matches)
+	}
+	if line := v.lines[v.matches[0]]; line != "%ERROR: link down" {
+		t.Errorf("line = %q, want escape codes and CR stripped", line)
+	}
+	view := m.View()
+	if !strings.Contains(view, "> %ERROR: link down") || !strings.Contains(view, "match 1 of 2") {
+		t.Errorf("first match not shown:\n%s", view)
+	}
+	press("n")
+	if v.match != 1 || v.top > v.matches[1] || v.matches[1] >= v.top+m.viewRows() {
+		t.Errorf("n did not scroll to the second match: top %d", v.top)
+	}
+	press("N")
+	if v.match != 0 {
+		t.Errorf("N: match = %d, want 0", v.match)
+	}
+	press("q")
+	if m.viewer != nil {
+		t.Error("q did not close the viewer")
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	mark := func(s ...string) string { return "[" + strings.Join(s, "") + "]" }
+	if got := highlightMatches("Error: error", "ERROR", mark); got != "[Error]: [error]" {
+		t.Errorf("got %q", got)
+	}
+	if got := highlightMatches("fine", "", mark); got != "fine" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -3,25 +3,76 @@ package tui
 import (
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/gbm-dev/pots/internal/auth"
 	"github.com/gbm-dev/pots/internal/config"
 	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+	"github.com/gbm-dev/pots/internal/sweep"
 )
 
+// favoritesGroup is the heading favorites are pinned under, and its key in
+// the collapsed map. otherGroup holds ungrouped sites once any site has a
+// group.
+const (
+	favoritesGroup = "★ Favorites"
+	otherGroup     = "Other"
+)
+
+// detailRows is how many recent dials the details pane lists.
+const detailRows = 5
+
+// menuPrefs is menu state that outlives one MenuModel, so the menu comes
+// back as the user left it after a call.
+type menuPrefs struct {
+	collapsed map[string]bool // groups the user has folded or unfolded
+	details   bool            // show the recent dials pane
+}
+
+func newMenuPrefs() *menuPrefs {
+	return &menuPrefs{collapsed: make(map[string]bool)}
+}
+
 // siteItem implements list.Item for the site selector.
 type siteItem struct {
-	site   config.Site
-	index  int
-	active bool // currently connected by someone
+	site     config.Site
+	index    int
+	active   bool // currently connected by someone
+	favorite bool
+	nested   bool // shown under a group heading
+	stats    session.DialStats
+	alert    int  // failed sweeps in a row, once enough to alert
+	viewOnly bool // the user has the viewer role and can't dial it
+
+	// detached is a call to this site the user may reattach, if any.
+	detached *session.Call
 }
 
 func (i siteItem) Title() string       { return i.site.Name }
 func (i siteItem) Description() string { return i.site.Description }
-func (i siteItem) FilterValue() string { return i.site.Name + " " + i.site.Description }
+
+// FilterValue is the searchable text, then the tags after a unit separator
+// for tag: terms; see filterSites.
+func (i siteItem) FilterValue() string {
+	return i.site.Name + " " + i.site.Description + " " + i.site.Group + "\x1f" + strings.Join(i.site.Tags, ",")
+}
+
+// groupItem is a collapsible group heading in the site list.
+type groupItem struct {
+	name      string
+	sites     int
+	active    int
+	collapsed bool
+}
+
+// FilterValue is empty: headings are left out of the list while filtering.
+func (g groupItem) FilterValue() string { return "" }
 
 // siteDelegate renders site items in the list.
 type siteDelegate struct {
@@ -33,16 +84,22 @@ func (d siteDelegate) Spacing() int                            { return 0 }
 func (d siteDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 
 func (d siteDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	isSelected := index == m.Index()
+
+	if g, ok := item.(groupItem); ok {
+		d.renderGroup(w, g, isSelected)
+		return
+	}
 	si, ok := item.(siteItem)
 	if !ok {
 		return
 	}
 
-	isSelected := index == m.Index()
-
 	// Status indicator
 	status := "  "
-	if si.active {
+	if si.detached != nil {
+		status = d.theme.NewStyle().Foreground(d.theme.ColorWarning).Render("◐ ")
+	} else if si.active {
 		status = d.theme.NewStyle().Foreground(d.theme.ColorSuccess).Render("● ")
 	}
 
@@ -51,20 +108,102 @@ func (d siteDelegate) Render(w io.Writer, m list.Model, index int, item list.Ite
 	if isSelected {
 		cursor = d.theme.NewStyle().Foreground(d.theme.ColorPrimary).Render("> ")
 	}
+	if si.nested {
+		cursor = "  " + cursor
+	}
 
 	// Name
 	var nameStyle lipgloss.Style
-	if isSelected {
+	switch {
+	case isSelected:
 		nameStyle = d.theme.NewStyle().Foreground(d.theme.ColorPrimary).Bold(true)
-	} else {
+	case si.viewOnly:
+		nameStyle = d.theme.NewStyle().Foreground(d.theme.ColorMuted)
+	default:
 		nameStyle = d.theme.NewStyle().Foreground(lipgloss.Color("#CCCCCC"))
 	}
 
 	// Description + baud — dimmed, separated
 	detail := d.theme.NewStyle().Foreground(d.theme.ColorMuted).Render(
 		fmt.Sprintf(" — %s (%d baud)", si.site.Description, si.site.BaudRate))
+	if len(si.site.Tags) > 0 {
+		detail += d.theme.NewStyle().Foreground(d.theme.ColorSecondary).Render(
+			"  " + strings.Join(si.site.Tags, " "))
+	}
+
+	if si.viewOnly {
+		detail += d.theme.NewStyle().Foreground(d.theme.ColorMuted).Render("  view only")
+	}
+	if si.detached != nil {
+		left := time.Until(si.detached.DetachedUntil()).Round(time.Second)
+		detail += d.theme.WarningStyle.Render(
+			fmt.Sprintf("  detached by %s, %s left — enter to reattach", si.detached.Owner, left))
+	}
+
+	name := nameStyle.Render(si.site.Name)
+	if si.favorite && !si.nested {
+		name += d.theme.NewStyle().Foreground(d.theme.ColorWarning).Render(" ★")
+	}
+	name += d.successBadge(si.stats)
+	if si.alert > 0 {
+		detail += d.theme.ErrorStyle.Render(fmt.Sprintf("  ⚠ unreachable: %d sweeps failed", si.alert))
+	} else if !si.stats.LastContact.IsZero() {
+		detail += d.theme.NewStyle().Foreground(d.theme.ColorMuted).Render(
+			"  · reached " + ago(si.stats.LastContact, time.Now()))
+	}
 
-	fmt.Fprintf(w, "%s%s%s%s", cursor, status, nameStyle.Render(si.site.Name), detail)
+	fmt.Fprintf(w, "%s%s%s%s", cursor, status, name, detail)
+}
+
+// successBadge shows the share of recent dials that connected: green from
+// 80%, amber from 50%, red below. Sites never dialed get none.
+func (d siteDelegate) successBadge(s session.DialStats) string {
+	rate := s.SuccessRate()
+	if rate < 0 {
+		return ""
+	}
+	color := d.theme.ColorError
+	switch {
+	case rate >= 80:
+		color = d.theme.ColorSuccess
+	case rate >= 50:
+		color = d.theme.ColorWarning
+	}
+	return " " + d.theme.NewStyle().Foreground(color).Render(fmt.Sprintf("[%d%%]", rate))
+}
+
+// ago formats how long before now t was, coarsely.
+func ago(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+func (d siteDelegate) renderGroup(w io.Writer, g groupItem, isSelected bool) {
+	cursor := "  "
+	style := d.theme.NewStyle().Foreground(d.theme.ColorSecondary).Bold(true)
+	if isSelected {
+		cursor = d.theme.NewStyle().Foreground(d.theme.ColorPrimary).Render("> ")
+		style = style.Foreground(d.theme.ColorPrimary)
+	}
+	arrow := "▾ "
+	if g.collapsed {
+		arrow = "▸ "
+	}
+	count := fmt.Sprintf(" (%d)", g.sites)
+	if g.active > 0 {
+		count = fmt.Sprintf(" (%d, %d in use)", g.sites, g.active)
+	}
+	fmt.Fprintf(w, "%s%s%s", cursor, style.Render(arrow+g.name),
+		d.theme.NewStyle().Foreground(d.theme.ColorMuted).Render(count))
 }
 
 // MenuModel is the site selection view.
@@ -72,33 +211,77 @@ type MenuModel struct {
 	list     list.Model
 	sites    []config.Site
 	lock     *modem.DeviceLock
+	calls    *session.Manager
+	sweeps   *sweep.Scheduler
 	username string
 	sipInfo  SIPInfo
 	theme    Theme
+
+	// access hides sites the user has no role on and marks the ones they
+	// may only view.
+	access auth.Access
+
+	// canReattachAny shows calls detached by other users as reattachable.
+	canReattachAny bool
+
+	// canAdmin offers the admin screens.
+	canAdmin bool
+
+	// canApprove offers the approvals screen; pendingApprovals is how many
+	// requests from others are waiting there.
+	canApprove       bool
+	pendingApprovals int
+
+	favorites map[string]bool // the user's pinned sites
+	prefs     *menuPrefs
+	height    int
 }
 
 // NewMenuModel creates the site selection menu.
-func NewMenuModel(sites []config.Site, username string, lock *modem.DeviceLock, width, height int, theme Theme) MenuModel {
-	activeSite := lock.ActiveSite()
-
-	items := make([]list.Item, len(sites))
-	for i, s := range sites {
-		items[i] = siteItem{site: s, index: i, active: s.Name == activeSite}
+func NewMenuModel(sites []config.Site, username string, lock *modem.DeviceLock, calls *session.Manager, sweeps *sweep.Scheduler, access auth.Access, canReattachAny bool, favorites []string, prefs *menuPrefs, width, height int, theme Theme) MenuModel {
+	m := MenuModel{
+		sites:          sites,
+		lock:           lock,
+		calls:          calls,
+		sweeps:         sweeps,
+		username:       username,
+		access:         access,
+		canReattachAny: canReattachAny,
+		theme:          theme,
+		prefs:          prefs,
+		height:         height,
 	}
+	m.setFavorites(favorites)
 
-	l := list.New(items, siteDelegate{theme: theme}, width, height-4)
+	l := list.New(nil, siteDelegate{theme: theme}, width, m.listHeight())
 	l.Title = "OOB Console Hub"
 	l.Styles.Title = theme.TitleStyle
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
 	l.SetShowHelp(false)
+	l.Filter = filterSites
+	// Left/right fold groups and f toggles a favorite, so paging keeps
+	// only its page keys.
+	l.KeyMap.PrevPage.SetKeys("pgup", "b", "u")
+	l.KeyMap.NextPage.SetKeys("pgdown", "d")
+
+	m.list = l
+	m.list.SetItems(m.buildItems())
+	return m
+}
+
+// setPendingApprovals updates the approvals notice, resizing the list to
+// make room for it.
+func (m *MenuModel) setPendingApprovals(n int) {
+	m.pendingApprovals = n
+	m.list.SetHeight(m.listHeight())
+}
 
-	return MenuModel{
-		list:     l,
-		sites:    sites,
-		lock:     lock,
-		username: username,
-		theme:    theme,
+// setFavorites replaces the pinned sites.
+func (m *MenuModel) setFavorites(favorites []string) {
+	m.favorites = make(map[string]bool, len(favorites))
+	for _, name := range favorites {
+		m.favorites[name] = true
 	}
 }
 
@@ -117,27 +300,86 @@ func (m MenuModel) Update(msg tea.Msg) (MenuModel, tea.Cmd) {
 			break
 		}
 		switch msg.String() {
-		case "enter":
-			if i, ok := m.list.SelectedItem().(siteItem); ok {
+		case "enter", " ":
+			switch i := m.list.SelectedItem().(type) {
+			case groupItem:
+				return m, m.setCollapsed(i.name, !i.collapsed)
+			case siteItem:
+				if msg.String() != "enter" {
+					break
+				}
+				if i.detached != nil {
+					id := i.detached.ID
+					return m, func() tea.Msg { return ReattachRequestMsg{CallID: id} }
+				}
+				if i.viewOnly {
+					break
+				}
 				return m, func() tea.Msg { return DialRequestMsg{SiteIndex: i.index} }
 			}
+			return m, nil
+		case "left", "h":
+			return m, m.setCollapsed(m.selectedGroup(), true)
+		case "right", "l":
+			if g, ok := m.list.SelectedItem().(groupItem); ok {
+				return m, m.setCollapsed(g.name, false)
+			}
+			return m, nil
+		case "i":
+			m.prefs.details = !m.prefs.details
+			m.list.SetHeight(m.listHeight())
+			return m, nil
+		case "f":
+			if i, ok := m.list.SelectedItem().(siteItem); ok {
+				name, fav := i.site.Name, !i.favorite
+				return m, func() tea.Msg { return FavoriteToggleMsg{Site: name, Favorite: fav} }
+			}
+			return m, nil
+		case "p":
+			return m, func() tea.Msg { return PasswordRequestMsg{} }
+		case "w":
+			return m, func() tea.Msg { return WhoRequestMsg{} }
+		case "L":
+			return m, func() tea.Msg { return LogsRequestMsg{} }
+		case "K":
+			return m, func() tea.Msg { return KeysRequestMsg{} }
+		case "T":
+			return m, func() tea.Msg { return TOTPRequestMsg{} }
+		case "a":
+			if m.canAdmin {
+				return m, func() tea.Msg { return AdminRequestMsg{} }
+			}
+			return m, nil
+		case "A":
+			if m.canApprove {
+				return m, func() tea.Msg { return ApprovalsRequestMsg{} }
+			}
+			return m, nil
 		case "q", "ctrl+c":
 			return m, tea.Quit
 		}
 	case tea.WindowSizeMsg:
-		m.list.SetSize(msg.Width, msg.Height-4)
+		m.height = msg.Height
+		m.list.SetSize(msg.Width, m.listHeight())
 	case sipStatusMsg:
 		m.sipInfo = SIPInfo(msg)
 		return m, nil
 	case sipTickMsg:
 		return m, tea.Batch(
+			m.refreshItems(),
 			func() tea.Msg { return checkSIPStatus() },
 			sipTick(),
 		)
 	}
 
+	// Filtering searches every site, so the tree is flattened while a
+	// filter is being typed or applied.
+	wasFiltered := m.list.FilterState() != list.Unfiltered
 	var cmd tea.Cmd
 	m.list, cmd = m.list.Update(msg)
+	if filtered := m.list.FilterState() != list.Unfiltered; filtered != wasFiltered {
+		cmd = tea.Batch(cmd, m.refreshItems())
+	}
 	return m, cmd
 }
 
@@ -168,19 +410,288 @@ func (m MenuModel) View() string {
 
 	// User info
 	parts = append(parts, m.theme.LabelStyle.Render(m.username))
-	parts = append(parts, m.theme.LabelStyle.Render("enter connect · q quit"))
+	keys := "enter connect · f favorite · i details · / filter, tag:name · w who's on · L logs · p password · K keys · T two-factor"
+	if m.canApprove {
+		keys += " · A approvals"
+	}
+	if m.canAdmin {
+		keys += " · a admin"
+	}
+	parts = append(parts, m.theme.LabelStyle.Render(keys+" · q quit"))
 
 	footer := m.theme.StatusBarStyle.Render("  " + strings.Join(parts, "  │  "))
-	return m.list.View() + "\n" + footer
+	view := m.list.View() + "\n"
+	if m.prefs.details {
+		view += m.detailsView() + "\n"
+	}
+	if m.canApprove && m.pendingApprovals > 0 {
+		view += m.theme.WarningStyle.Render(fmt.Sprintf("  %d dial request(s) awaiting approval · press A", m.pendingApprovals)) + "\n"
+	}
+	return view + footer
+}
+
+// listHeight leaves room for the footer and, when shown, the details pane
+// and approvals notice.
+func (m MenuModel) listHeight() int {
+	h := m.height - 4
+	if m.prefs.details {
+		h -= detailRows + 2
+	}
+	if m.canApprove && m.pendingApprovals > 0 {
+		h--
+	}
+	return max(h, 3)
+}
+
+// detailsView lists the selected site's most recent dials.
+func (m MenuModel) detailsView() string {
+	muted := m.theme.NewStyle().Foreground(m.theme.ColorMuted)
+	si, ok := m.list.SelectedItem().(siteItem)
+	if !ok {
+		return m.theme.LabelStyle.Render("  Recent dials") + strings.Repeat("\n", detailRows+1)
+	}
+
+	title := m.theme.LabelStyle.Render("  Recent dials — " + si.site.Name)
+	if st, ok := m.sweeps.Status(si.site.Name); ok && !st.LastRun.IsZero() {
+		title += muted.Render(fmt.Sprintf("  · last sweep %s: %s", ago(st.LastRun, time.Now()), st.Result))
+	}
+	lines := []string{title}
+	recent := m.calls.Dials().Recent(si.site.Name, detailRows)
+	if len(recent) == 0 {
+		lines = append(lines, muted.Render("    never dialed"))
+	}
+	for _, r := range recent {
+		style := m.theme.ErrorStyle
+		if r.Connected() {
+			style = m.theme.SuccessStyle
+		}
+		line := fmt.Sprintf("    %s  %-12s ", r.Time.Format("2006-01-02 15:04"), truncate(r.User, 12))
+		line = muted.Render(line) + style.Render(fmt.Sprintf("%-11s", r.Result))
+		var extra []string
+		if r.Rate > 0 {
+			extra = append(extra, fmt.Sprintf("%d bps", r.Rate))
+		}
+		if r.Attempts > 1 {
+			extra = append(extra, fmt.Sprintf("%d attempts", r.Attempts))
+		}
+		if r.Duration > 0 {
+			extra = append(extra, "up "+r.Duration.String())
+		}
+		lines = append(lines, line+muted.Render(" "+strings.Join(extra, ", ")))
+	}
+	for len(lines) < detailRows+1 {
+		lines = append(lines, "")
+	}
+	return "\n" + strings.Join(lines, "\n")
 }
 
 // refreshItems updates the list items with current active status.
-func (m *MenuModel) refreshItems() {
-	activeSite := m.lock.ActiveSite()
+func (m *MenuModel) refreshItems() tea.Cmd {
+	return m.list.SetItems(m.buildItems())
+}
+
+// setCollapsed folds or unfolds a group, keeping the cursor on its heading.
+func (m *MenuModel) setCollapsed(group string, collapsed bool) tea.Cmd {
+	if group == "" || m.list.FilterState() != list.Unfiltered {
+		return nil
+	}
+	m.prefs.collapsed[group] = collapsed
+	cmd := m.refreshItems()
+	for i, item := range m.list.Items() {
+		if g, ok := item.(groupItem); ok && g.name == group {
+			m.list.Select(i)
+			break
+		}
+	}
+	return cmd
+}
+
+// selectedGroup returns the group heading the cursor is on or under.
+func (m MenuModel) selectedGroup() string {
+	items := m.list.Items()
+	for i := min(m.list.Index(), len(items)-1); i >= 0; i-- {
+		switch it := items[i].(type) {
+		case groupItem:
+			return it.name
+		case siteItem:
+			if !it.nested {
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// selectSite moves the cursor to the named site, if it is shown.
+func (m *MenuModel) selectSite(name string) {
+	for i, item := range m.list.VisibleItems() {
+		if si, ok := item.(siteItem); ok && si.site.Name == name {
+			m.list.Select(i)
+			return
+		}
+	}
+}
+
+// buildItems snapshots active and detached call state into list items.
+// Favorites come first; if any site has a group, sites are listed under
+// collapsible group headings, except while filtering.
+func (m MenuModel) buildItems() []list.Item {
+	active := make(map[string]bool)
+	for _, name := range m.lock.ActiveSites() {
+		active[name] = true
+	}
+
+	detached := make(map[string]*session.Call)
+	for _, c := range m.calls.Calls() {
+		if !c.DetachedUntil().IsZero() {
+			detached[c.Site] = c
+		}
+	}
+
+	dials := m.calls.Dials()
+	item := func(i int) siteItem {
+		s := m.sites[i]
+		var alert int
+		if m.sweeps.Alerting(s.Name) {
+			st, _ := m.sweeps.Status(s.Name)
+			alert = st.Failures
+		}
+		role := m.access.Role(s)
+		it := siteItem{
+			alert:    alert,
+			site:     s,
+			index:    i,
+			active:   active[s.Name],
+			favorite: m.favorites[s.Name],
+			stats:    dials.Stats(s.Name),
+			viewOnly: role < auth.RoleOperator,
+		}
+		if c := detached[s.Name]; c != nil && mayReattach(m.username, role, m.canReattachAny, c) {
+			it.detached = c
+		}
+		return it
+	}
 
-	items := make([]list.Item, len(m.sites))
+	// Sites in file order, grouped, with groups in order of first use.
+	// Sites the user has no role on are left out.
+	var groups []string
+	members := make(map[string][]int)
+	var favorites []int
+	grouped := false
 	for i, s := range m.sites {
-		items[i] = siteItem{site: s, index: i, active: s.Name == activeSite}
+		if m.access.Role(s) == auth.RoleNone {
+			continue
+		}
+		if m.favorites[s.Name] {
+			favorites = append(favorites, i)
+			continue
+		}
+		g := s.Group
+		if g != "" {
+			grouped = true
+		} else {
+			g = otherGroup
+		}
+		if _, ok := members[g]; !ok {
+			groups = append(groups, g)
+		}
+		members[g] = append(members[g], i)
+	}
+	if i := slices.Index(groups, otherGroup); i >= 0 {
+		// Ungrouped sites go last.
+		groups = append(slices.Delete(groups, i, i+1), otherGroup)
+	}
+
+	var items []list.Item
+	if !grouped || m.list.FilterState() != list.Unfiltered {
+		for _, i := range favorites {
+			items = append(items, item(i))
+		}
+		for _, g := range groups {
+			for _, i := range members[g] {
+				items = append(items, item(i))
+			}
+		}
+		return items
+	}
+
+	addGroup := func(name string, indexes []int) {
+		if len(indexes) == 0 {
+			return
+		}
+		g := groupItem{name: name, sites: len(indexes), collapsed: m.isCollapsed(name)}
+		var nested []list.Item
+		for _, i := range indexes {
+			si := item(i)
+			si.nested = true
+			if si.active || si.detached != nil {
+				g.active++
+			}
+			nested = append(nested, si)
+		}
+		items = append(items, g)
+		if !g.collapsed {
+			items = append(items, nested...)
+		}
+	}
+	addGroup(favoritesGroup, favorites)
+	for _, g := range groups {
+		addGroup(g, members[g])
+	}
+	return items
+}
+
+// isCollapsed reports whether a group is folded. Groups the user has not
+// touched start folded when the sites would not fit on one page, except
+// favorites.
+func (m MenuModel) isCollapsed(group string) bool {
+	if c, ok := m.prefs.collapsed[group]; ok {
+		return c
+	}
+	return group != favoritesGroup && len(m.sites) > m.list.Paginator.PerPage
+}
+
+// filterSites is the list's filter. Terms of the form tag:name keep only
+// sites carrying that tag; the rest of the input is matched fuzzily
+// against site name, description and group.
+func filterSites(term string, targets []string) []list.Rank {
+	var tags, words []string
+	for _, f := range strings.Fields(term) {
+		if tag, ok := strings.CutPrefix(f, "tag:"); ok {
+			if tag != "" {
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		words = append(words, f)
+	}
+
+	var indexes []int
+	var texts []string
+	for i, target := range targets {
+		text, tagList, ok := strings.Cut(target, "\x1f")
+		if !ok {
+			continue // group heading
+		}
+		siteTags := strings.Split(tagList, ",")
+		if !slices.ContainsFunc(tags, func(t string) bool {
+			return !slices.ContainsFunc(siteTags, func(s string) bool { return strings.EqualFold(s, t) })
+		}) {
+			indexes = append(indexes, i)
+			texts = append(texts, text)
+		}
+	}
+
+	if len(words) == 0 {
+		ranks := make([]list.Rank, len(indexes))
+		for i, idx := range indexes {
+			ranks[i] = list.Rank{Index: idx}
+		}
+		return ranks
+	}
+	ranks := list.DefaultFilter(strings.Join(words, " "), texts)
+	for i := range ranks {
+		ranks[i].Index = indexes[ranks[i].Index]
 	}
-	m.list.SetItems(items)
+	return ranks
 }
@@ -0,0 +1,188 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+var menuSites = []config.Site{
+	{Name: "nyc-sw1", Description: "NYC switch", Group: "New York", Tags: []string{"nyc", "cisco"}},
+	{Name: "lab", Description: "Lab console"},
+	{Name: "chi-rtr1", Description: "Chicago router", Group: "Chicago", Tags: []string{"chi", "juniper"}},
+	{Name: "nyc-rtr1", Description: "NYC router", Group: "New York", Tags: []string{"nyc", "juniper"}},
+}
+
+func newTestMenu(t *testing.T, sites []config.Site, favorites []string, collapsed map[string]bool) MenuModel {
+	t.Helper()
+	calls := session.NewManager(t.TempDir(), 0, 1024, 0, nil)
+	return NewMenuModel(sites, "alice", modem.NewDeviceLock("/dev/null"), calls, nil, auth.Access{}, false, favorites, &menuPrefs{collapsed: collapsed}, 80, 40, NewTheme(nil))
+}
+
+// itemNames lists the visible items, group headings in brackets.
+func itemNames(m MenuModel) []string {
+	var names []string
+	for _, item := range m.list.Items() {
+		switch it := item.(type) {
+		case groupItem:
+			names = append(names, "["+it.name+"]")
+		case siteItem:
+			names = append(names, it.site.Name)
+		}
+	}
+	return names
+}
+
+func TestMenu_FlatWithoutGroups(t *testing.T) {
+	sites := []config.Site{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	m := newTestMenu(t, sites, []string{"c"}, map[string]bool{})
+	if got, want := itemNames(m), []string{"c", "a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("items = %q, want %q", got, want)
+	}
+}
+
+func TestMenu_GroupsAndFavorites(t *testing.T) {
+	m := newTestMenu(t, menuSites, []string{"nyc-rtr1"}, map[string]bool{})
+	want := []string{"[★ Favorites]", "nyc-rtr1", "[New York]", "nyc-sw1", "[Chicago]", "chi-rtr1", "[Other]", "lab"}
+	if got := itemNames(m); !slices.Equal(got, want) {
+		t.Errorf("items = %q, want %q", got, want)
+	}
+}
+
+func TestMenu_CollapseAndExpand(t *testing.T) {
+	collapsed := map[string]bool{}
+	m := newTestMenu(t, menuSites, nil, collapsed)
+
+	// Cursor on nyc-sw1; left folds its group and selects the heading.
+	m.list.Select(1)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	want := []string{"[New York]", "[Chicago]", "chi-rtr1", "[Other]", "lab"}
+	if got := itemNames(m); !slices.Equal(got, want) {
+		t.Fatalf("items = %q, want %q", got, want)
+	}
+	if m.list.Index() != 0 {
+		t.Errorf("cursor = %d, want heading at 0", m.list.Index())
+	}
+	if !collapsed["New York"] {
+		t.Error("collapse not remembered")
+	}
+
+	// The fold survives a rebuilt menu, and enter unfolds it.
+	m = newTestMenu(t, menuSites, nil, collapsed)
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := itemNames(m); len(got) != 7 {
+		t.Errorf("items = %q, want New York expanded", got)
+	}
+}
+
+func TestMenu_FavoriteKey(t *testing.T) {
+	m := newTestMenu(t, menuSites, nil, map[string]bool{})
+	m.list.Select(1)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	msg, ok := cmd().(FavoriteToggleMsg)
+	if !ok || msg.Site != "nyc-sw1" || !msg.Favorite {
+		t.Errorf("msg = %#v, want favorite nyc-sw1", msg)
+	}
+}
+
+func TestFilterSites(t *testing.T) {
+	var targets []string
+	for _, s := range menuSites {
+		targets = append(targets, siteItem{site: s}.FilterValue())
+	}
+	targets = append(targets, groupItem{name: "New York"}.FilterValue())
+
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"tag:nyc", []string{"nyc-sw1", "nyc-rtr1"}},
+		{"tag:NYC tag:juniper", []string{"nyc-rtr1"}},
+		{"tag:juniper chicago", []string{"chi-rtr1"}},
+		{"tag:nyc lab", nil},
+		{"lab", []string{"lab"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range filterSites(tt.term, targets) {
+			got = append(got, menuSites[r.Index].Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filterSites(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestMenu_FilterFlattensTree(t *testing.T) {
+	m := newTestMenu(t, menuSites, nil, map[string]bool{"New York": true})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if m.list.FilterState() != list.Filtering {
+		t.Fatalf("filter state = %v, want filtering", m.list.FilterState())
+	}
+	// Folded sites are searchable and headings are gone.
+	if got := itemNames(m); len(got) != len(menuSites) {
+		t.Errorf("items = %q, want every site", got)
+	}
+}
+
+func TestMenu_DialHistory(t *testing.T) {
+	dials, err := session.OpenDialLog(filepath.Join(t.TempDir(), "dials.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dials.Record(session.DialRecord{Site: "nyc-sw1", User: "bob", Result: "NO CARRIER", Attempts: 3})
+	dials.Record(session.DialRecord{Site: "nyc-sw1", User: "alice", Result: "CONNECT", Attempts: 1, Rate: 9600, Duration: time.Minute})
+
+	calls := session.NewManager(t.TempDir(), 0, 1024, 0, dials)
+	m := NewMenuModel(menuSites, "alice", modem.NewDeviceLock("/dev/null"), calls, nil, auth.Access{}, false, nil, newMenuPrefs(), 120, 40, NewTheme(nil))
+
+	m.list.Select(1)
+	si := m.list.SelectedItem().(siteItem)
+	if si.site.Name != "nyc-sw1" || si.stats.SuccessRate() != 50 {
+		t.Fatalf("selected %s with rate %d, want nyc-sw1 at 50%%", si.site.Name, si.stats.SuccessRate())
+	}
+	if view := m.View(); !strings.Contains(view, "[50%]") || !strings.Contains(view, "reached just now") {
+		t.Errorf("menu does not show the badge and last contact:\n%s", view)
+	}
+
+	if strings.Contains(m.View(), "Recent dials") {
+		t.Error("details pane shown before i")
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("i")})
+	view := m.View()
+	for _, want := range []string{"Recent dials — nyc-sw1", "NO CARRIER", "3 attempts", "9600 bps", "up 1m0s"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("details pane missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestAgo(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3 * time.Hour, "3h ago"},
+		{72 * time.Hour, "3d ago"},
+	}
+	for _, tt := range tests {
+		if got := ago(now.Add(-tt.d), now); got != tt.want {
+			t.Errorf("ago(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
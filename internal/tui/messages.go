@@ -3,6 +3,7 @@ package tui
 import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
 )
 
 // State represents the current TUI state.
@@ -13,6 +14,14 @@ const (
 	StateMenu
 	StateDialing
 	StateConnected
+	StateAdminUsers
+	StateAdminSessions
+	StateWho
+	StateLogs
+	StateTicket
+	StateApprovals
+	StateKeys
+	StateTOTP
 )
 
 // Messages passed between TUI components.
@@ -22,6 +31,65 @@ type DialRequestMsg struct {
 	SiteIndex int
 }
 
+// TicketEnteredMsg is sent when the user has given a valid change ticket
+// for the site they picked.
+type TicketEnteredMsg struct {
+	SiteIndex int
+	Ticket    session.Ticket
+}
+
+// TicketCancelledMsg is sent when the user backs out of the ticket form.
+type TicketCancelledMsg struct{}
+
+// ReattachRequestMsg is sent when the user picks a detached call to resume.
+type ReattachRequestMsg struct {
+	CallID string
+}
+
+// FavoriteToggleMsg is sent when the user pins or unpins a site.
+type FavoriteToggleMsg struct {
+	Site     string
+	Favorite bool
+}
+
+// WhoRequestMsg is sent when the user opens the who's-on dashboard.
+type WhoRequestMsg struct{}
+
+// WhoDoneMsg is sent when the user leaves the who's-on dashboard.
+type WhoDoneMsg struct{}
+
+// LogsRequestMsg is sent when the user opens the log browser.
+type LogsRequestMsg struct{}
+
+// LogsDoneMsg is sent when the user leaves the log browser.
+type LogsDoneMsg struct{}
+
+// ApprovalsRequestMsg is sent when an approver opens the approvals screen.
+type ApprovalsRequestMsg struct{}
+
+// ApprovalsDoneMsg is sent when the approver leaves the approvals screen.
+type ApprovalsDoneMsg struct{}
+
+// KeysRequestMsg is sent when the user opens their SSH keys screen.
+type KeysRequestMsg struct{}
+
+// KeysDoneMsg is sent when the user leaves the SSH keys screen.
+type KeysDoneMsg struct{}
+
+// TOTPRequestMsg is sent when the user opens their two-factor login screen.
+type TOTPRequestMsg struct{}
+
+// TOTPDoneMsg is sent when the user leaves the two-factor login screen.
+type TOTPDoneMsg struct{}
+
+// AdminRequestMsg is sent when an admin opens the admin screens.
+type AdminRequestMsg struct{}
+
+// AdminNavigateMsg moves between the admin screens and back to the menu.
+type AdminNavigateMsg struct {
+	To State
+}
+
 // ModemAcquiredMsg is sent when a modem device is acquired from the pool.
 type ModemAcquiredMsg struct {
 	Device string
@@ -36,11 +104,19 @@ type DialResultMsg struct {
 	Transcript string
 	Modem      *modem.Modem
 	Device     string
+	Attempts   int
 }
 
+// PasswordRequestMsg is sent when the user asks to change their password.
+type PasswordRequestMsg struct{}
+
 // PasswordChangedMsg is sent after a successful password change.
 type PasswordChangedMsg struct{}
 
+// PasswordCancelledMsg is sent when the user backs out of a voluntary
+// password change.
+type PasswordCancelledMsg struct{}
+
 // ErrorMsg wraps an error for display.
 type ErrorMsg struct {
 	Err     error
@@ -1,15 +1,53 @@
 package tui
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gbm-dev/pots/internal/auth"
 	"github.com/gbm-dev/pots/internal/config"
 	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+	"github.com/gbm-dev/pots/internal/sweep"
 )
 
+// Deps bundles the hub-wide services shared by every TUI session.
+type Deps struct {
+	Config config.AppConfig
+	Sites  []config.Site
+	Lock   *modem.DeviceLock
+	Store  auth.UserStore
+	Calls  *session.Manager
+
+	// Snippets are the site and global snippets from SNIPPETS_PATH.
+	Snippets []config.Snippet
+
+	// Sweeps is the reachability sweep scheduler, nil if sweeps are off.
+	Sweeps *sweep.Scheduler
+
+	// Sessions lists every connected SSH session, for the who's-on
+	// dashboard. The SSH server maintains it.
+	Sessions *session.Registry
+
+	// Audit is the append-only record of access decisions.
+	Audit *session.AuditLog
+
+	// Approvals queues requests to dial protected sites. The SSH server
+	// creates it if unset.
+	Approvals *session.Approvals
+
+	// TicketPattern is the compiled TICKET_PATTERN, nil if sites need no
+	// change ticket unless they ask for one.
+	TicketPattern *regexp.Regexp
+}
+
 // Model is the root Bubble Tea model that manages the TUI state machine.
 type Model struct {
 	state    State
@@ -20,23 +58,73 @@ type Model struct {
 	theme    Theme
 
 	// Dependencies
-	lock  *modem.DeviceLock
-	store auth.UserStore
-	sites []config.Site
+	cfg      config.AppConfig
+	lock     *modem.DeviceLock
+	store    auth.UserStore
+	sites    []config.Site
+	calls    *session.Manager
+	snippets []config.Snippet
+	sweeps   *sweep.Scheduler
+	sessions *session.Registry
+	tickets  *regexp.Regexp // hub-wide change ticket pattern
+
+	approvals *session.Approvals
+	audit     *session.AuditLog
+
+	// presence is this session's entry in sessions, kept in step with
+	// the state.
+	presence *session.Presence
+
+	// ctx ends with the SSH session, withdrawing anything the session
+	// left waiting, such as a place in the modem queue.
+	ctx context.Context
+
+	// access is this user's role on each site.
+	access auth.Access
+
+	// canReattachAny lets this user pick up calls detached by others.
+	canReattachAny bool
+
+	// isAdmin unlocks the admin screens.
+	isAdmin bool
+
+	// canApprove lets this user approve others' dials to protected sites.
+	canApprove bool
+
+	// oneShot quits when the first call ends instead of returning to the
+	// menu, for "ssh hub connect <site>".
+	oneShot bool
+
+	// menuPrefs keeps the menu's layout across calls in this SSH session.
+	menuPrefs *menuPrefs
+
+	// termSize reports the current terminal size while a call has the
+	// screen; nil if unknown.
+	termSize func() (width, height int)
 
 	// Sub-models
-	menu     MenuModel
-	dialing  DialingModel
-	password PasswordModel
+	menu      MenuModel
+	dialing   DialingModel
+	password  PasswordModel
+	admin     AdminModel
+	who       WhoModel
+	logs      LogsModel
+	ticket    TicketModel
+	approving ApprovalsModel
+	keys      KeysModel
+	totp      TOTPModel
 
 	// Active dial state
 	activeModem  *modem.Modem
 	activeDevice string
 	activeSite   config.Site
+	activeTicket session.Ticket
+	activeCall   *session.Call
 }
 
-// New creates the root TUI model.
-func New(username string, sites []config.Site, lock *modem.DeviceLock, store auth.UserStore, logDir string, forcePassword bool, renderer *lipgloss.Renderer) Model {
+// New creates the root TUI model for an SSH session registered in
+// deps.Sessions as presence. ctx is the SSH session's context.
+func New(ctx context.Context, username string, deps Deps, presence *session.Presence, forcePassword bool, renderer *lipgloss.Renderer) Model {
 	state := StateMenu
 	if forcePassword {
 		state = StatePasswordChange
@@ -45,40 +133,128 @@ func New(username string, sites []config.Site, lock *modem.DeviceLock, store aut
 	m := Model{
 		state:    state,
 		username: username,
-		logDir:   logDir,
-		lock:     lock,
-		store:    store,
-		sites:    sites,
-		width:    80,
-		height:   24,
-		theme:    NewTheme(renderer),
+		logDir:   deps.Config.LogDir,
+		cfg:      deps.Config,
+		lock:     deps.Lock,
+		store:    deps.Store,
+		sites:    deps.Sites,
+		calls:    deps.Calls,
+		snippets: deps.Snippets,
+		sweeps:   deps.Sweeps,
+		sessions: deps.Sessions,
+		tickets:  deps.TicketPattern,
+
+		approvals: deps.Approvals,
+		audit:     deps.Audit,
+		presence:  presence,
+		ctx:       ctx,
+		width:     80,
+		height:    24,
+		theme:     NewTheme(renderer),
+
+		menuPrefs: newMenuPrefs(),
 	}
+	m.access = m.loadAccess()
+	m.canReattachAny = m.hasRight(auth.RightReattachAny)
+	m.isAdmin = m.hasRight(auth.RightAdmin)
+	m.canApprove = m.isAdmin || m.hasRight(auth.RightApprove)
 
 	if forcePassword {
-		m.password = NewPasswordModel(username, store, m.theme)
+		m.password = NewPasswordModel(username, m.store, m.theme)
 	} else {
-		m.menu = NewMenuModel(sites, username, lock, m.width, m.height, m.theme)
+		m.menu = m.newMenu()
 	}
 
 	return m
 }
 
+// Connect makes the model dial the site at index in deps.Sites straight
+// away, skipping the menu, and exit when the call ends. The caller checks
+// ticket against the site's ticket rule.
+func (m Model) Connect(index int, ticket session.Ticket) Model {
+	m.oneShot = true
+	m, _ = m.dial(index, ticket)
+	m.syncPresence()
+	return m
+}
+
+// WithTerminalSize lets calls follow window changes, which the model only
+// hears about between them. size reports the client's current terminal
+// size.
+func (m Model) WithTerminalSize(size func() (width, height int)) Model {
+	m.termSize = size
+	return m
+}
+
+// dial switches to the dialing screen for the site at index. Protected
+// sites first wait there for someone to approve the dial; if every modem
+// is busy the dial waits its turn for one, or preempts a lower-priority
+// call.
+func (m Model) dial(index int, ticket session.Ticket) (Model, tea.Cmd) {
+	m.activeSite = m.sites[index]
+	m.activeTicket = ticket
+	m.dialing = NewDialingModel(m.ctx, m.activeSite, m.username, ticket, m.lock, m.calls, m.theme)
+	m.access = m.loadAccess()
+	if m.access.Role(m.activeSite) < auth.RoleOperator {
+		return m.refuseDial()
+	}
+	m.dialing.access, m.dialing.sites = m.access, m.sites
+	m.dialing.preempt = preemption{
+		priority: DialPriority(m.store, m.username, m.activeSite),
+		rank:     m.rankCall,
+		audit:    m.audit,
+	}
+	if m.activeSite.Protected {
+		m.dialing.awaitApproval(m.approvals.Request(m.ctx, m.username, m.activeSite.Name, ticket))
+	} else {
+		m.dialing.queue()
+	}
+	m.state = StateDialing
+	return m, m.dialing.Init()
+}
+
 func (m Model) Init() tea.Cmd {
+	if m.canApprove {
+		return tea.Batch(waitApprovals(m.approvals), m.initState())
+	}
+	return m.initState()
+}
+
+func (m Model) initState() tea.Cmd {
 	switch m.state {
 	case StatePasswordChange:
 		return m.password.Init()
 	case StateMenu:
 		return m.menu.Init()
+	case StateDialing:
+		return m.dialing.Init()
 	default:
 		return nil
 	}
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.presence.Touch()
+	}
+	next, cmd := m.update(msg)
+	next.syncPresence()
+	return next, cmd
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+	case approvalsChangedMsg:
+		switch m.state {
+		case StateMenu:
+			m.menu.setPendingApprovals(othersPending(m.approvals, m.username))
+		case StateApprovals:
+			m.approving.reload()
+		}
+		return m, waitApprovals(m.approvals)
 	}
 
 	switch m.state {
@@ -90,6 +266,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.updateDialing(msg)
 	case StateConnected:
 		return m.updateConnected(msg)
+	case StateAdminUsers, StateAdminSessions:
+		return m.updateAdmin(msg)
+	case StateWho:
+		return m.updateWho(msg)
+	case StateLogs:
+		return m.updateLogs(msg)
+	case StateTicket:
+		return m.updateTicket(msg)
+	case StateApprovals:
+		return m.updateApprovals(msg)
+	case StateKeys:
+		return m.updateKeys(msg)
+	case StateTOTP:
+		return m.updateTOTP(msg)
 	}
 	return m, nil
 }
@@ -104,15 +294,31 @@ func (m Model) View() string {
 		return m.dialing.View()
 	case StateConnected:
 		return "" // terminal mode takes over
+	case StateAdminUsers:
+		return m.admin.usersView()
+	case StateAdminSessions:
+		return m.admin.sessionsView()
+	case StateWho:
+		return m.who.View()
+	case StateLogs:
+		return m.logs.View()
+	case StateTicket:
+		return m.ticket.View()
+	case StateApprovals:
+		return m.approving.View()
+	case StateKeys:
+		return m.keys.View()
+	case StateTOTP:
+		return m.totp.View()
 	default:
 		return ""
 	}
 }
 
-func (m Model) updatePasswordChange(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m Model) updatePasswordChange(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg.(type) {
-	case PasswordChangedMsg:
-		m.menu = NewMenuModel(m.sites, m.username, m.lock, m.width, m.height, m.theme)
+	case PasswordChangedMsg, PasswordCancelledMsg:
+		m.menu = m.newMenu()
 		m.state = StateMenu
 		return m, m.menu.Init()
 	case ErrorMsg:
@@ -126,15 +332,77 @@ func (m Model) updatePasswordChange(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m Model) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m Model) updateMenu(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case DialRequestMsg:
 		if msg.SiteIndex >= 0 && msg.SiteIndex < len(m.sites) {
-			m.activeSite = m.sites[msg.SiteIndex]
-			m.dialing = NewDialingModel(m.activeSite, m.lock, m.theme)
-			m.state = StateDialing
-			return m, m.dialing.Init()
+			site := m.sites[msg.SiteIndex]
+			m.access = m.loadAccess()
+			if rule := site.TicketRule(m.tickets); rule != nil && m.access.Role(site) >= auth.RoleOperator {
+				m.ticket = NewTicketModel(msg.SiteIndex, site, rule, m.theme)
+				m.state = StateTicket
+				return m, m.ticket.Init()
+			}
+			return m.dial(msg.SiteIndex, session.Ticket{})
 		}
+	case ReattachRequestMsg:
+		call := m.calls.Get(msg.CallID)
+		m.access = m.loadAccess()
+		if call == nil || !m.mayReattach(call) {
+			return m, m.menu.refreshItems()
+		}
+		m.activeDevice = call.Device()
+		m.activeSite = m.siteByName(call.Site)
+		m.activeCall = call
+		m.state = StateConnected
+		ts := NewTerminalSession(call, m.username, m.terminalOptions(m.siteByName(call.Site), true))
+		return m, tea.Exec(ts, func(err error) tea.Msg {
+			return TerminalDoneMsg{Err: err}
+		})
+	case PasswordRequestMsg:
+		m.password = NewChangePasswordModel(m.username, m.store, m.theme)
+		m.state = StatePasswordChange
+		return m, m.password.Init()
+	case WhoRequestMsg:
+		m.access = m.loadAccess()
+		m.who = NewWhoModel(m.username, m.sessions, m.access, m.sites, m.theme)
+		m.state = StateWho
+		return m, m.who.Init()
+	case KeysRequestMsg:
+		m.keys = NewKeysModel(m.username, m.store, m.theme)
+		m.state = StateKeys
+		return m, nil
+	case TOTPRequestMsg:
+		m.totp = NewTOTPModel(m.username, m.store, m.theme)
+		m.state = StateTOTP
+		return m, nil
+	case LogsRequestMsg:
+		m.logs = NewLogsModel(m.logDir, m.logReader(), m.width, m.height, m.theme)
+		m.state = StateLogs
+		return m, nil
+	case ApprovalsRequestMsg:
+		if !m.canApprove {
+			return m, nil
+		}
+		m.approving = NewApprovalsModel(m.username, m.approvals, m.theme)
+		m.state = StateApprovals
+		return m, nil
+	case AdminRequestMsg:
+		if !m.hasRight(auth.RightAdmin) {
+			return m, nil
+		}
+		m.admin = NewAdminModel(m.username, m.store, m.calls, m.audit, m.theme)
+		m.state = StateAdminUsers
+		return m, nil
+	case FavoriteToggleMsg:
+		if err := m.store.SetFavorite(m.username, msg.Site, msg.Favorite); err != nil {
+			slog.Error("saving favorite", "user", m.username, "site", msg.Site, "err", err)
+			return m, nil
+		}
+		m.menu.setFavorites(m.favorites())
+		cmd := m.menu.refreshItems()
+		m.menu.selectSite(msg.Site)
+		return m, cmd
 	}
 
 	var cmd tea.Cmd
@@ -142,15 +410,25 @@ func (m Model) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m Model) updateDialing(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m Model) updateDialing(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case DialResultMsg:
 		if msg.Result == modem.ResultConnect {
+			call, err := m.calls.Start(m.username, m.activeTicket, m.dialing.preempt.priority, m.activeSite, msg.Device, msg.Modem, m.lock, msg.Attempts)
+			if err != nil {
+				var cmd tea.Cmd
+				m.dialing, cmd = m.dialing.Update(ErrorMsg{Err: err, Context: "session"})
+				return m, cmd
+			}
+			if err := m.audit.Record(session.AuditEvent{Event: "dial", User: m.username, Site: m.activeSite.Name, Ticket: m.activeTicket.ID, Reason: m.activeTicket.Reason}); err != nil {
+				slog.Error("recording audit event", "event", "dial", "err", err)
+			}
 			m.activeModem = msg.Modem
 			m.activeDevice = msg.Device
+			m.activeCall = call
 			m.state = StateConnected
 
-			ts := NewTerminalSession(msg.Modem, msg.Device, m.activeSite.Name, m.logDir, m.lock)
+			ts := NewTerminalSession(call, m.username, m.terminalOptions(m.activeSite, false))
 			return m, tea.Exec(ts, func(err error) tea.Msg {
 				return TerminalDoneMsg{Err: err}
 			})
@@ -159,6 +437,7 @@ func (m Model) updateDialing(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Keep this transition in the root model to avoid async command hops
 		// when leaving the failed-dial screen.
 		if msg.String() == "ctrl+c" || (m.dialing.done && msg.String() == "enter") {
+			m.dialing.cancel()
 			return m.returnToMenu()
 		}
 	case ErrorMsg:
@@ -170,7 +449,7 @@ func (m Model) updateDialing(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func (m Model) updateConnected(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m Model) updateConnected(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case TerminalDoneMsg:
 		if msg.Err != nil {
@@ -181,14 +460,294 @@ func (m Model) updateConnected(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m Model) returnToMenu() (tea.Model, tea.Cmd) {
-	m.menu = NewMenuModel(m.sites, m.username, m.lock, m.width, m.height, m.theme)
+func (m Model) updateTicket(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case TicketEnteredMsg:
+		return m.dial(msg.SiteIndex, msg.Ticket)
+	case TicketCancelledMsg:
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.ticket, cmd = m.ticket.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateApprovals(msg tea.Msg) (Model, tea.Cmd) {
+	if _, ok := msg.(ApprovalsDoneMsg); ok {
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.approving, cmd = m.approving.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateKeys(msg tea.Msg) (Model, tea.Cmd) {
+	if _, ok := msg.(KeysDoneMsg); ok {
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.keys, cmd = m.keys.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateTOTP(msg tea.Msg) (Model, tea.Cmd) {
+	if _, ok := msg.(TOTPDoneMsg); ok {
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.totp, cmd = m.totp.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateWho(msg tea.Msg) (Model, tea.Cmd) {
+	if _, ok := msg.(WhoDoneMsg); ok {
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.who, cmd = m.who.Update(msg)
+	return m, cmd
+}
+
+func (m Model) updateLogs(msg tea.Msg) (Model, tea.Cmd) {
+	if _, ok := msg.(LogsDoneMsg); ok {
+		return m.returnToMenu()
+	}
+	var cmd tea.Cmd
+	m.logs, cmd = m.logs.Update(msg)
+	return m, cmd
+}
+
+// syncPresence tells the session registry what this user is doing.
+func (m Model) syncPresence() {
+	switch m.state {
+	case StateDialing:
+		m.presence.Set(session.PresenceDialing, m.activeSite.Name, m.dialing.device, nil)
+	case StateConnected:
+		m.presence.Set(session.PresenceConnected, m.activeSite.Name, m.activeDevice, m.activeCall)
+	default:
+		m.presence.Set(session.PresenceMenu, "", "", nil)
+	}
+}
+
+func (m Model) updateAdmin(msg tea.Msg) (Model, tea.Cmd) {
+	if nav, ok := msg.(AdminNavigateMsg); ok {
+		switch nav.To {
+		case StateMenu:
+			return m.returnToMenu()
+		case StateAdminUsers:
+			m.admin.reload()
+		}
+		m.state = nav.To
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.state == StateAdminSessions {
+		m.admin, cmd = m.admin.updateSessions(msg)
+	} else {
+		m.admin, cmd = m.admin.updateUsers(msg)
+	}
+	return m, cmd
+}
+
+func (m Model) returnToMenu() (Model, tea.Cmd) {
+	if m.oneShot {
+		return m, tea.Quit
+	}
+	m.menu = m.newMenu()
 	m.state = StateMenu
 	m.activeModem = nil
 	m.activeDevice = ""
+	m.activeTicket = session.Ticket{}
+	m.activeCall = nil
 
 	return m, tea.Batch(
 		tea.ClearScreen,
 		m.menu.Init(),
 	)
 }
+
+func (m Model) newMenu() MenuModel {
+	menu := NewMenuModel(m.sites, m.username, m.lock, m.calls, m.sweeps, m.access, m.canReattachAny, m.favorites(), m.menuPrefs, m.width, m.height, m.theme)
+	menu.canAdmin = m.isAdmin
+	if m.canApprove {
+		menu.canApprove = true
+		menu.setPendingApprovals(othersPending(m.approvals, m.username))
+	}
+	return menu
+}
+
+// favorites returns the user's pinned sites, or none if they can't be read.
+func (m Model) favorites() []string {
+	info, err := m.store.Get(m.username)
+	if err != nil {
+		slog.Error("loading favorites", "user", m.username, "err", err)
+		return nil
+	}
+	return info.Favorites
+}
+
+// mayReattach reports whether this user can take over a detached call.
+func (m Model) mayReattach(call *session.Call) bool {
+	if call.DetachedUntil().IsZero() {
+		return false
+	}
+	return mayReattach(m.username, m.access.Role(m.siteByName(call.Site)), m.canReattachAny, call)
+}
+
+// mayReattach reports whether user, with role on the call's site, may take
+// over call once it is detached: their own calls, or anyone's with the
+// reattach-any right or as the site's admin.
+func mayReattach(user string, role auth.Role, reattachAny bool, call *session.Call) bool {
+	if role < auth.RoleOperator {
+		return false
+	}
+	return call.Owner == user || reattachAny || role >= auth.RoleAdmin
+}
+
+// logReader returns which session logs this user may read: those of their
+// own calls, or every log with the read-logs right, but only for sites they
+// can see. Site admins read every log for their sites.
+func (m Model) logReader() func(session.LogEntry) bool {
+	all := m.hasRight(auth.RightReadLogs)
+	return func(e session.LogEntry) bool {
+		role := m.access.Role(m.siteByName(e.Site))
+		if role == auth.RoleNone {
+			return false
+		}
+		return all || role >= auth.RoleAdmin || e.User == m.username
+	}
+}
+
+// loadAccess reads the user's site roles, hiding every site if they can't
+// be read. It runs at login for the menu and again before each dial and
+// reattach, so a revoked grant takes effect without a new login.
+func (m Model) loadAccess() auth.Access {
+	a, err := m.store.Access(m.username)
+	if err != nil {
+		slog.Error("loading site access", "user", m.username, "err", err)
+		return auth.NoAccess
+	}
+	return a
+}
+
+// refuseDial fails the dial to the active site for want of the operator
+// role, on the dialing screen so the user sees why.
+func (m Model) refuseDial() (Model, tea.Cmd) {
+	slog.Warn("dial denied", "user", m.username, "site", m.activeSite.Name)
+	if err := m.audit.Record(session.AuditEvent{Event: "dial-denied", User: m.username, Site: m.activeSite.Name}); err != nil {
+		slog.Error("recording audit event", "event", "dial-denied", "err", err)
+	}
+	m.dialing.done = true
+	m.dialing.err = fmt.Errorf("you don't have operator access to %s", m.activeSite.Name)
+	m.state = StateDialing
+	return m, nil
+}
+
+// hasRight checks the store for an optional right, treating errors as "no".
+func (m Model) hasRight(right string) bool {
+	ok, err := m.store.HasRight(m.username, right)
+	if err != nil {
+		slog.Error("right check failed", "user", m.username, "right", right, "err", err)
+		return false
+	}
+	return ok
+}
+
+// siteByName looks up a configured site, returning a bare Site if unknown.
+func (m Model) siteByName(name string) config.Site {
+	return lookupSite(m.sites, name)
+}
+
+// lookupSite finds the site called name, returning a bare Site if unknown.
+func lookupSite(sites []config.Site, name string) config.Site {
+	for _, s := range sites {
+		if s.Name == name {
+			return s
+		}
+	}
+	return config.Site{Name: name}
+}
+
+// hiddenSite stands in for the name of a site the user can't see.
+const hiddenSite = "(restricted)"
+
+// siteLabel returns name, or hiddenSite if access gives no role on it.
+func siteLabel(access auth.Access, sites []config.Site, name string) string {
+	if access.Role(lookupSite(sites, name)) == auth.RoleNone {
+		return hiddenSite
+	}
+	return name
+}
+
+// terminalOptions resolves hub defaults, site overrides and user rights
+// into the settings for a terminal session on site.
+func (m Model) terminalOptions(site config.Site, reattach bool) TerminalOptions {
+	opts := TerminalOptions{
+		Grace:       m.calls.Grace(),
+		Reattach:    reattach,
+		IdleTimeout: m.cfg.IdleTimeout,
+		MaxDuration: m.cfg.MaxCallDuration,
+		RawMode:     site.RawMode,
+		Site:        site,
+		Scrollback:  m.cfg.ScrollbackBytes,
+		Width:       m.width,
+		Height:      m.height,
+		Size:        m.termSize,
+
+		CharDelay:     site.CharDelay,
+		LineDelay:     site.LineDelay,
+		Prompt:        site.Prompt,
+		PromptTimeout: site.PromptWait(),
+		PasteWait:     site.PasteWait,
+	}
+	if site.IdleTimeout > 0 {
+		opts.IdleTimeout = site.IdleTimeout
+	}
+	if site.MaxDuration > 0 {
+		opts.MaxDuration = site.MaxDuration
+	}
+	if m.hasRight(auth.RightNoTimeout) {
+		opts.IdleTimeout = 0
+		opts.MaxDuration = 0
+	}
+	hist, err := session.LoadHistory(filepath.Join(m.cfg.UserDataDir, "history"), m.username, site.Name)
+	if err != nil {
+		slog.Error("loading command history", "user", m.username, "site", site.Name, "err", err)
+	}
+	opts.History = hist
+
+	info, err := m.store.Get(m.username)
+	if err != nil {
+		slog.Error("loading user settings", "user", m.username, "err", err)
+		return opts
+	}
+	if esc, err := auth.ParseEscapeChar(info.EscapeChar); err == nil {
+		opts.EscapeChar = esc
+	}
+	opts.Snippets = m.snippetsFor(site, info.Snippets)
+	return opts
+}
+
+// snippetsFor lists the snippets offered on site: the user's own first,
+// then the site's, then global ones, each name only once.
+func (m Model) snippetsFor(site config.Site, mine map[string]string) []Snippet {
+	var out []Snippet
+	seen := make(map[string]bool)
+	names := slices.Sorted(maps.Keys(mine))
+	for _, name := range names {
+		out = append(out, Snippet{Name: name, Text: mine[name], Source: "mine"})
+		seen[name] = true
+	}
+	for _, s := range config.SnippetsFor(m.snippets, site.Name) {
+		if seen[s.Name] {
+			continue
+		}
+		source := "site"
+		if s.Site == "*" {
+			source = "global"
+		}
+		out = append(out, Snippet{Name: s.Name, Text: s.Text, Source: source})
+	}
+	return out
+}
@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestConnectSkipsMenuAndQuits(t *testing.T) {
+	deps, _ := newTestDeps(t, "alice")
+	reg := session.NewRegistry()
+	deps.Sessions = reg
+	m := New(context.Background(), "alice", deps, reg.Register("alice", ""), false, nil).Connect(2, session.Ticket{})
+	if m.state != StateDialing || m.activeSite.Name != "chi-rtr1" {
+		t.Fatalf("state = %v on %q, want dialing chi-rtr1", m.state, m.activeSite.Name)
+	}
+	if p := reg.List()[0]; p.State != session.PresenceDialing {
+		t.Errorf("presence = %+v, want dialing", p)
+	}
+
+	// With no modem devices the dial fails; leaving the error quits.
+	if !m.dialing.done || m.dialing.err == nil {
+		t.Fatalf("dial with no modems did not fail: %+v", m.dialing)
+	}
+	var model tea.Model = m
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("connect did not quit after the dial failed")
+	}
+}
+
+func TestDialQueuesForBusyModem(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "ttySL0")
+	if err := os.WriteFile(dev, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lock := modem.NewDeviceLock(dev)
+	lock.Acquire("nyc-rtr1")
+	deps, _ := newTestDeps(t, "alice")
+	reg := session.NewRegistry()
+	deps.Lock = lock
+	deps.Sessions = reg
+	m := New(context.Background(), "alice", deps, reg.Register("alice", ""), false, nil)
+	m, _ = m.dial(2, session.Ticket{})
+	if m.dialing.done || m.dialing.queuePosition() != 1 {
+		t.Fatalf("done = %v, position = %d; want queued first", m.dialing.done, m.dialing.queuePosition())
+	}
+	view := m.dialing.View()
+	if !strings.Contains(view, "Position 1") || !strings.Contains(view, "nyc-rtr1") {
+		t.Errorf("view does not show the queue position and holder:\n%s", view)
+	}
+
+	// Ctrl+C leaves the queue, so a released device goes back to idle.
+	var model tea.Model = m
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if got := model.(Model).state; got != StateMenu {
+		t.Fatalf("state = %v after Ctrl+C, want menu", got)
+	}
+	if len(lock.Waiting()) != 0 {
+		t.Errorf("still queued after cancelling: %v", lock.Waiting())
+	}
+
+	// Queued again, the dial takes the device as soon as it is released.
+	m, _ = m.dial(2, session.Ticket{})
+	lock.Release(dev)
+	m.dialing, _ = m.dialing.Update(modemReadyMsg{})
+	if m.dialing.device != dev || lock.Holders()[dev] != "chi-rtr1" {
+		t.Errorf("device = %q held by %q, want %q for chi-rtr1", m.dialing.device, lock.Holders()[dev], dev)
+	}
+}
+
+func TestDialQueueLeftWhenSessionEnds(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "ttySL0")
+	if err := os.WriteFile(dev, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lock := modem.NewDeviceLock(dev)
+	lock.Acquire("nyc-rtr1")
+	deps, _ := newTestDeps(t, "alice", "bob")
+	reg := session.NewRegistry()
+	deps.Lock = lock
+	deps.Sessions = reg
+	ctx, disconnect := context.WithCancel(context.Background())
+	alice := New(ctx, "alice", deps, reg.Register("alice", ""), false, nil)
+	alice, _ = alice.dial(2, session.Ticket{})
+	bob := New(context.Background(), "bob", deps, reg.Register("bob", ""), false, nil)
+	bob, _ = bob.dial(1, session.Ticket{})
+	if bob.dialing.queuePosition() != 2 {
+		t.Fatalf("bob at position %d, want 2", bob.dialing.queuePosition())
+	}
+
+	// Alice's SSH session drops while she is first in line.
+	disconnect()
+	waitUntil(t, func() bool { return bob.dialing.queuePosition() == 1 })
+
+	lock.Release(dev)
+	<-bob.dialing.waiter.Ready()
+	bob.dialing, _ = bob.dialing.Update(modemReadyMsg{})
+	if bob.dialing.device != dev || lock.Holders()[dev] != "lab" {
+		t.Errorf("device = %q held by %q, want %q for lab", bob.dialing.device, lock.Holders()[dev], dev)
+	}
+}
+
+func TestDialErrorRecorded(t *testing.T) {
+	dials, err := session.OpenDialLog(filepath.Join(t.TempDir(), "dials.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := filepath.Join(t.TempDir(), "missing")
+	lock := modem.NewDeviceLock(dev)
+	calls := session.NewManager(t.TempDir(), 0, 1024, 0, dials)
+	d := NewDialingModel(context.Background(), menuSites[1], "alice", session.Ticket{ID: "CHG7"}, lock, calls, NewTheme(nil))
+
+	if msg, ok := d.dial(dev)().(ErrorMsg); !ok {
+		t.Fatalf("dial on a missing device sent %T, want ErrorMsg", msg)
+	}
+	got := dials.Recent("lab", 1)
+	if len(got) != 1 || got[0].Result != "ERROR" || got[0].Device != dev || got[0].Ticket != "CHG7" || got[0].Attempts != 1 {
+		t.Errorf("dial history = %+v, want one ERROR", got)
+	}
+}
+
+func TestSiteAccess(t *testing.T) {
+	deps, store := newTestDeps(t, "alice", "bob")
+	store.AddToGroup("alice", "noc")
+	for _, g := range []auth.Grant{
+		{Subject: "group:noc", Target: "group:New York", Role: auth.RoleViewer},
+		{Subject: "alice", Target: "nyc-rtr1", Role: auth.RoleOperator},
+		{Subject: "alice", Target: "tag:chi", Role: auth.RoleAdmin},
+	} {
+		if err := store.AddGrant(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.SetAccessEnforced(true)
+	logDir := t.TempDir()
+	reg := session.NewRegistry()
+	deps.Config.LogDir = logDir
+	deps.Sessions = reg
+	m := New(context.Background(), "alice", deps, reg.Register("alice", ""), false, nil)
+
+	// lab has no grant and is hidden; nyc-sw1 can only be viewed.
+	want := []string{"[New York]", "nyc-sw1", "nyc-rtr1", "[Chicago]", "chi-rtr1"}
+	if got := itemNames(m.menu); !slices.Equal(got, want) {
+		t.Errorf("menu = %q, want %q", got, want)
+	}
+	m.menu.list.Select(1)
+	if !strings.Contains(m.menu.View(), "view only") {
+		t.Errorf("view-only site not marked:\n%s", m.menu.View())
+	}
+	if _, cmd := m.menu.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Errorf("enter on a view-only site sent %T", cmd())
+	}
+
+	// Dial requests are checked too, e.g. from "connect".
+	m, _ = m.update(DialRequestMsg{SiteIndex: 0})
+	if m.state != StateDialing || !m.dialing.done || !strings.Contains(m.dialing.View(), "operator access") {
+		t.Errorf("dial to a view-only site not refused: state %v\n%s", m.state, m.dialing.View())
+	}
+	if c := New(context.Background(), "alice", deps, reg.Register("alice", ""), false, nil).Connect(1, session.Ticket{}); c.dialing.err == nil {
+		t.Error("connect to a hidden site not refused")
+	}
+
+	// Logs: own logs on visible sites, everyone's as the site's admin.
+	writeTestLog(t, logDir, "nyc-sw1", "alice", "")
+	writeTestLog(t, logDir, "nyc-sw1", "bob", "")
+	writeTestLog(t, logDir, "chi-rtr1", "bob", "")
+	writeTestLog(t, logDir, "lab", "alice", "")
+	logs := NewLogsModel(logDir, m.logReader(), 80, 40, NewTheme(nil))
+	if got, want := logSites(logs), []string{"chi-rtr1/bob", "nyc-sw1/alice"}; !slices.Equal(got, want) {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+
+	// Users with no grant see nothing while access is enforced.
+	if got := itemNames(New(context.Background(), "bob", deps, reg.Register("bob", ""), false, nil).menu); len(got) != 0 {
+		t.Errorf("bob's menu = %q, want empty", got)
+	}
+
+	// A grant revoked after login stops the next dial.
+	m = New(context.Background(), "alice", deps, reg.Register("alice", ""), false, nil)
+	store.RemoveGrant("alice", "nyc-rtr1")
+	m, _ = m.update(DialRequestMsg{SiteIndex: 3})
+	if m.state != StateDialing || !m.dialing.done || !strings.Contains(m.dialing.View(), "operator access") {
+		t.Errorf("dial after revoking the grant not refused: state %v\n%s", m.state, m.dialing.View())
+	}
+}
@@ -11,91 +11,116 @@ import (
 
 const minPasswordLen = 8
 
-// PasswordModel is the first-login password change form.
+// PasswordModel is the password change form, shown on first login and when
+// a user picks change password from the menu.
 type PasswordModel struct {
-	newPassword     textinput.Model
-	confirmPassword textinput.Model
-	focusIndex      int
-	err             string
-	username        string
-	store           auth.UserStore
-	theme           Theme
+	// inputs are the new and confirm fields, preceded by the current
+	// password when the change is voluntary.
+	inputs     []textinput.Model
+	focusIndex int
+	err        string
+	username   string
+	store      auth.UserStore
+	theme      Theme
+
+	// voluntary asks for the current password and lets the user cancel.
+	voluntary bool
 }
 
-// NewPasswordModel creates a password change form.
+func passwordInput(placeholder string) textinput.Model {
+	in := textinput.New()
+	in.Placeholder = placeholder
+	in.EchoMode = textinput.EchoPassword
+	in.EchoCharacter = '*'
+	return in
+}
+
+// NewPasswordModel creates the first-login password change form.
 func NewPasswordModel(username string, store auth.UserStore, theme Theme) PasswordModel {
-	np := textinput.New()
-	np.Placeholder = "New password (min 8 chars)"
-	np.EchoMode = textinput.EchoPassword
-	np.EchoCharacter = '*'
-	np.Focus()
-
-	cp := textinput.New()
-	cp.Placeholder = "Confirm password"
-	cp.EchoMode = textinput.EchoPassword
-	cp.EchoCharacter = '*'
-
-	return PasswordModel{
-		newPassword:     np,
-		confirmPassword: cp,
-		username:        username,
-		store:           store,
-		theme:           theme,
+	m := PasswordModel{
+		inputs: []textinput.Model{
+			passwordInput("New password (min 8 chars)"),
+			passwordInput("Confirm password"),
+		},
+		username: username,
+		store:    store,
+		theme:    theme,
 	}
+	m.inputs[0].Focus()
+	return m
+}
+
+// NewChangePasswordModel creates the form for a user changing their own
+// password, which first asks for the current one.
+func NewChangePasswordModel(username string, store auth.UserStore, theme Theme) PasswordModel {
+	m := NewPasswordModel(username, store, theme)
+	m.inputs[0].Blur()
+	m.inputs = append([]textinput.Model{passwordInput("Current password")}, m.inputs...)
+	m.inputs[0].Focus()
+	m.voluntary = true
+	return m
 }
 
 func (m PasswordModel) Init() tea.Cmd {
 	return textinput.Blink
 }
 
+// focus moves the cursor to field i, wrapping around.
+func (m *PasswordModel) focus(i int) {
+	m.inputs[m.focusIndex].Blur()
+	m.focusIndex = (i + len(m.inputs)) % len(m.inputs)
+	m.inputs[m.focusIndex].Focus()
+}
+
 func (m PasswordModel) Update(msg tea.Msg) (PasswordModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "tab", "shift+tab", "down", "up":
-			if m.focusIndex == 0 {
-				m.focusIndex = 1
-				m.newPassword.Blur()
-				m.confirmPassword.Focus()
-			} else {
-				m.focusIndex = 0
-				m.confirmPassword.Blur()
-				m.newPassword.Focus()
-			}
+		case "tab", "down":
+			m.focus(m.focusIndex + 1)
+			return m, nil
+		case "shift+tab", "up":
+			m.focus(m.focusIndex - 1)
 			return m, nil
 
 		case "enter":
-			if m.focusIndex == 0 {
-				m.focusIndex = 1
-				m.newPassword.Blur()
-				m.confirmPassword.Focus()
+			if m.focusIndex < len(m.inputs)-1 {
+				m.focus(m.focusIndex + 1)
 				return m, nil
 			}
 			return m, m.submit()
 
+		case "esc":
+			if m.voluntary {
+				return m, func() tea.Msg { return PasswordCancelledMsg{} }
+			}
+
 		case "ctrl+c":
 			return m, tea.Quit
 		}
 	}
 
 	var cmd tea.Cmd
-	if m.focusIndex == 0 {
-		m.newPassword, cmd = m.newPassword.Update(msg)
-	} else {
-		m.confirmPassword, cmd = m.confirmPassword.Update(msg)
-	}
+	m.inputs[m.focusIndex], cmd = m.inputs[m.focusIndex].Update(msg)
 	return m, cmd
 }
 
 func (m PasswordModel) View() string {
 	var b strings.Builder
 
-	b.WriteString(m.theme.TitleStyle.Render("Password Change Required"))
-	b.WriteString("\n\n")
-	b.WriteString(m.theme.LabelStyle.Render("  You must set a new password before continuing."))
-	b.WriteString("\n\n")
-	b.WriteString(fmt.Sprintf("  New password:     %s\n", m.newPassword.View()))
-	b.WriteString(fmt.Sprintf("  Confirm password: %s\n", m.confirmPassword.View()))
+	if m.voluntary {
+		b.WriteString(m.theme.TitleStyle.Render("Change Password"))
+		b.WriteString("\n\n")
+		fmt.Fprintf(&b, "  Current password: %s\n", m.inputs[0].View())
+	} else {
+		b.WriteString(m.theme.TitleStyle.Render("Password Change Required"))
+		b.WriteString("\n\n")
+		b.WriteString(m.theme.LabelStyle.Render("  You must set a new password before continuing."))
+		b.WriteString("\n\n")
+	}
+	fields := m.inputs[len(m.inputs)-2:]
+	fmt.Fprintf(&b, "  New password:     %s\n", fields[0].View())
+	fmt.Fprintf(&b, "  Confirm password: %s\n", fields[1].View())
 
 	if m.err != "" {
 		b.WriteString("\n")
@@ -103,16 +128,30 @@ func (m PasswordModel) View() string {
 	}
 
 	b.WriteString("\n\n")
-	b.WriteString(m.theme.LabelStyle.Render("  Tab to switch fields | Enter to submit"))
+	help := "  Tab to switch fields | Enter to submit"
+	if m.voluntary {
+		help += " | Esc to cancel"
+	}
+	b.WriteString(m.theme.LabelStyle.Render(help))
 
 	return m.theme.BoxStyle.Render(b.String())
 }
 
 func (m PasswordModel) submit() tea.Cmd {
 	return func() tea.Msg {
-		pw := m.newPassword.Value()
-		confirm := m.confirmPassword.Value()
-
+		n := len(m.inputs)
+		pw := m.inputs[n-2].Value()
+		confirm := m.inputs[n-1].Value()
+
+		if m.voluntary {
+			ok, err := m.store.Authenticate(m.username, m.inputs[0].Value())
+			if err != nil {
+				return ErrorMsg{Err: fmt.Errorf("checking password: %w", err)}
+			}
+			if !ok {
+				return ErrorMsg{Err: fmt.Errorf("current password is incorrect")}
+			}
+		}
 		if len(pw) < minPasswordLen {
 			return ErrorMsg{Err: fmt.Errorf("password must be at least %d characters", minPasswordLen)}
 		}
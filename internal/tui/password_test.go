@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+)
+
+// typeInto types each field's text followed by Enter and returns the
+// message the form submits.
+func typeInto(m PasswordModel, fields ...string) (PasswordModel, tea.Msg) {
+	var cmd tea.Cmd
+	for _, text := range fields {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+		m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	if cmd == nil {
+		return m, nil
+	}
+	return m, cmd()
+}
+
+func TestChangePassword_RequiresCurrent(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "oldpassword")
+	store.SetPassword("alice", "oldpassword")
+
+	m := NewChangePasswordModel("alice", store, NewTheme(nil))
+	if _, msg := typeInto(m, "wrongpassword", "newpassword", "newpassword"); msg == nil {
+		t.Fatal("no result")
+	} else if _, ok := msg.(ErrorMsg); !ok {
+		t.Errorf("wrong current password: got %#v, want an error", msg)
+	}
+	if ok, _ := store.Authenticate("alice", "oldpassword"); !ok {
+		t.Fatal("password changed without the current one")
+	}
+
+	m = NewChangePasswordModel("alice", store, NewTheme(nil))
+	if _, msg := typeInto(m, "oldpassword", "newpassword", "newpassword"); msg != (PasswordChangedMsg{}) {
+		t.Fatalf("got %#v, want PasswordChangedMsg", msg)
+	}
+	if ok, _ := store.Authenticate("alice", "newpassword"); !ok {
+		t.Error("new password does not log in")
+	}
+}
+
+func TestChangePassword_FromMenuAndCancel(t *testing.T) {
+	deps, store := newTestDeps(t)
+	store.Add("alice", "oldpassword")
+	var model tea.Model = New(context.Background(), "alice", deps, nil, false, nil)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	model, _ = model.Update(cmd())
+	if s := model.(Model).state; s != StatePasswordChange {
+		t.Fatalf("state = %v, want password change", s)
+	}
+
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model, _ = model.Update(cmd())
+	if s := model.(Model).state; s != StateMenu {
+		t.Errorf("state = %v after Esc, want menu", s)
+	}
+}
@@ -0,0 +1,282 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// Bracketed paste: once pasteModeOn is written, the terminal wraps pasted
+// text in pasteStart and pasteEnd so it can be told apart from typing.
+const (
+	pasteModeOn  = "\x1b[?2004h"
+	pasteModeOff = "\x1b[?2004l"
+	pasteStart   = "\x1b[200~"
+	pasteEnd     = "\x1b[201~"
+)
+
+// xterm window title stack, so paste progress can borrow the title bar.
+const (
+	titlePush = "\x1b[22;0t"
+	titlePop  = "\x1b[23;0t"
+)
+
+var errPasteAborted = errors.New("aborted")
+
+// paste is a block of pasted text being sent to the modem at the site's
+// pace by runPaste. Keys typed meanwhile are dropped, except Ctrl+C and Esc
+// which abort it.
+type paste struct {
+	lines    []string // each ends in \r, except possibly the last
+	echo     bool     // line mode: echo each line locally as it is sent
+	echoSkip int      // bytes of the first line already echoed as typing
+	tail     string   // line mode: partial last line, left in the line buffer
+
+	sent  atomic.Int64
+	abort chan struct{}
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (p *paste) cancel() {
+	p.once.Do(func() { close(p.abort) })
+}
+
+// sleep waits for d, returning errPasteAborted if the paste is aborted first.
+func (p *paste) sleep(d time.Duration) error {
+	if d <= 0 {
+		select {
+		case <-p.abort:
+			return errPasteAborted
+		default:
+			return nil
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-p.abort:
+		return errPasteAborted
+	case <-timer.C:
+		return nil
+	}
+}
+
+// atPasteStart reports whether the ESC just read from br begins a bracketed
+// paste, consuming the rest of the marker if so. Terminals send the marker
+// in one write, so a lone ESC key is never held back waiting for more input.
+func atPasteStart(br *bufio.Reader) bool {
+	rest := len(pasteStart) - 1
+	if br.Buffered() < rest {
+		return false
+	}
+	next, err := br.Peek(rest)
+	if err != nil || string(next) != pasteStart[1:] {
+		return false
+	}
+	br.Discard(rest)
+	return true
+}
+
+// readPaste reads pasted text up to the closing marker.
+func readPaste(br *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(b)
+		if b == '~' && strings.HasSuffix(sb.String(), pasteEnd) {
+			return strings.TrimSuffix(sb.String(), pasteEnd), nil
+		}
+	}
+}
+
+// splitPasteLines normalizes newlines to CR and splits text after each CR.
+func splitPasteLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	return strings.SplitAfter(strings.TrimSuffix(text, "\r"), "\r")
+}
+
+// startPaste hands pasted text to a pacer goroutine. In line mode the text
+// continues the line being typed, and a single pasted line is simply added
+// to it for editing.
+func (t *TerminalSession) startPaste(in *inputState, text string, w io.Writer, echo io.Writer) error {
+	if text == "" {
+		return nil
+	}
+	if t.pasting.Load() != nil {
+		notice(echo, "Paste already in progress — Ctrl+C or Esc to abort it")
+		return nil
+	}
+	if in.picker != nil {
+		// Pasting into the picker's filter.
+		for _, b := range []byte(text) {
+			if b >= ' ' {
+				in.picker.filter = append(in.picker.filter, b)
+			}
+		}
+		in.picker.sel = 0
+		t.drawPicker(in.picker, echo)
+		return nil
+	}
+	if in.escPending {
+		in.escPending = false
+		if _, err := t.handleInput(in, t.escapeChar(), w, echo); err != nil {
+			return err
+		}
+	}
+
+	lines := splitPasteLines(text)
+	trailingCR := strings.HasSuffix(text, "\r") || strings.HasSuffix(text, "\n")
+	if trailingCR {
+		lines[len(lines)-1] += "\r"
+	}
+	p := &paste{abort: make(chan struct{}), done: make(chan struct{})}
+
+	if !t.raw.Load() {
+		if in.search != nil {
+			in.acceptSearch(echo)
+		}
+		if len(lines) == 1 && !trailingCR {
+			var printable []byte
+			for _, b := range []byte(lines[0]) {
+				if b >= ' ' {
+					printable = append(printable, b)
+				}
+			}
+			in.insert(echo, printable)
+			in.atLineStart = false
+			return nil
+		}
+		// The typed line becomes the start of the first pasted line; it is
+		// already on screen, so move past it and skip it when echoing.
+		in.moveTo(echo, len(in.lineBuf))
+		p.echo = true
+		p.echoSkip = len(in.lineBuf)
+		lines[0] = string(in.lineBuf) + lines[0]
+		in.resetLine()
+		if !trailingCR {
+			p.tail = lines[len(lines)-1]
+			lines = lines[:len(lines)-1]
+			in.lineBuf = append(in.lineBuf, p.tail...)
+			in.cursor = len(in.lineBuf)
+			in.shown = len(in.lineBuf)
+		}
+	}
+	p.lines = lines
+	in.atLineStart = trailingCR
+
+	t.pasting.Store(p)
+	go t.runPaste(p, w, echo)
+	return nil
+}
+
+// abortPaste stops any paste in progress and waits for its pacer to exit.
+func (t *TerminalSession) abortPaste() {
+	if p := t.pasting.Load(); p != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+// runPaste sends p to the modem, sleeping CharDelay after each character and
+// LineDelay after each line. With PasteWait each line after the first is
+// held until the site's prompt appears. Multi-line pastes show progress in
+// the terminal title and report how they finished.
+func (t *TerminalSession) runPaste(p *paste, w io.Writer, echo io.Writer) {
+	defer close(p.done)
+	defer t.pasting.Store(nil)
+
+	total := len(p.lines)
+	multi := total > 1
+	if multi {
+		notice(echo, "Pasting %d lines — Ctrl+C or Esc to abort", total)
+		fmt.Fprint(echo, titlePush)
+		defer fmt.Fprint(echo, titlePop)
+	}
+
+	var watch *session.Watch
+	if t.opts.PasteWait && t.opts.Prompt != nil {
+		watch = t.call.Watch()
+		defer watch.Close()
+	}
+
+	err := func() error {
+		for i, line := range p.lines {
+			if i > 0 && watch != nil {
+				if err := watch.Wait(t.opts.Prompt, t.opts.PromptTimeout, p.abort); err != nil {
+					if errors.Is(err, session.ErrWaitCancelled) {
+						return errPasteAborted
+					}
+					return fmt.Errorf("waiting for prompt: %w", err)
+				}
+			}
+			if multi {
+				fmt.Fprintf(echo, "\x1b]0;%s — pasting line %d/%d\x07", t.siteName, i+1, total)
+			}
+			if p.echo {
+				shown := strings.TrimSuffix(line, "\r")
+				if i == 0 {
+					shown = shown[min(p.echoSkip, len(shown)):]
+				}
+				io.WriteString(echo, shown+"\r\n")
+			}
+			if watch != nil {
+				watch.Reset()
+			}
+			if err := t.sendPaced(p, line, w); err != nil {
+				return err
+			}
+			p.sent.Add(1)
+			if strings.HasSuffix(line, "\r") {
+				if err := p.sleep(t.opts.LineDelay); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}()
+
+	switch {
+	case errors.Is(err, errPasteAborted):
+		notice(echo, "Paste aborted after %d of %d lines", p.sent.Load(), total)
+	case err != nil:
+		notice(echo, "Paste stopped after %d of %d lines: %v", p.sent.Load(), total, err)
+	case multi:
+		notice(echo, "Paste complete: %d lines", total)
+	}
+	if p.tail != "" {
+		io.WriteString(echo, p.tail)
+	}
+}
+
+// sendPaced writes line to the modem, one character at a time when the site
+// sets a character delay.
+func (t *TerminalSession) sendPaced(p *paste, line string, w io.Writer) error {
+	if t.opts.CharDelay <= 0 {
+		if err := p.sleep(0); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, line)
+		return err
+	}
+	for i := 0; i < len(line); i++ {
+		if _, err := w.Write([]byte{line[i]}); err != nil {
+			return err
+		}
+		if err := p.sleep(t.opts.CharDelay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe for use by the paste pacer goroutine.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPaste_RawModeSendsLines(t *testing.T) {
+	ts := &TerminalSession{opts: TerminalOptions{LineDelay: time.Millisecond}}
+	ts.raw.Store(true)
+	r, pw := io.Pipe()
+	var modem, echo lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(r, &modem, &echo) }()
+
+	pw.Write([]byte(pasteStart + "hostname r1\nint e0\r\n" + pasteEnd))
+	waitUntil(t, func() bool { return modem.String() == "hostname r1\rint e0\r" })
+	waitUntil(t, func() bool { return strings.Contains(echo.String(), "Paste complete: 2 lines") })
+
+	pw.Close()
+	<-done
+}
+
+func TestPaste_LineModeContinuesTypedLine(t *testing.T) {
+	ts := &TerminalSession{}
+	r, pw := io.Pipe()
+	var modem, echo lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(r, &modem, &echo) }()
+
+	pw.Write([]byte("sh"))
+	pw.Write([]byte(pasteStart + "ow ver\nshow cl" + pasteEnd))
+	waitUntil(t, func() bool { return modem.String() == "show ver\r" })
+	waitUntil(t, func() bool { return ts.pasting.Load() == nil })
+
+	// The partial last line stays in the line buffer for editing.
+	pw.Write([]byte("ock\r"))
+	waitUntil(t, func() bool { return modem.String() == "show ver\rshow clock\r" })
+	pw.Close()
+	<-done
+
+	if got := echo.String(); !strings.Contains(got, "show ver\r\n") || !strings.HasSuffix(got, "show clock\r\n") {
+		t.Errorf("echo = %q", got)
+	}
+}
+
+func TestPaste_SingleLineInLineModeIsEditable(t *testing.T) {
+	ts := &TerminalSession{}
+	input := "a" + pasteStart + "bc" + pasteEnd + "\x7f\r"
+	var modem, echo bytes.Buffer
+	ts.userToModem(strings.NewReader(input), &modem, &echo)
+	if got := modem.String(); got != "ab\r" {
+		t.Errorf("modem output = %q, want %q", got, "ab\r")
+	}
+}
+
+func TestPaste_CtrlCAborts(t *testing.T) {
+	ts := &TerminalSession{opts: TerminalOptions{CharDelay: 20 * time.Millisecond}}
+	ts.raw.Store(true)
+	r, pw := io.Pipe()
+	var modem, echo lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(r, &modem, &echo) }()
+
+	text := strings.Repeat("interface loopback0\n", 20)
+	pw.Write([]byte(pasteStart + text + pasteEnd))
+	waitUntil(t, func() bool { return modem.String() != "" })
+	pw.Write([]byte{0x03})
+	waitUntil(t, func() bool { return strings.Contains(echo.String(), "Paste aborted after 0 of 20 lines") })
+
+	// Ctrl+C aborted the paste rather than reaching the device.
+	if got := modem.String(); strings.Contains(got, "\x03") || len(got) >= len(text) {
+		t.Errorf("modem output after abort = %q", got)
+	}
+	pw.Close()
+	<-done
+}
+
+func TestPaste_LoneEscIsNotHeldBack(t *testing.T) {
+	ts := &TerminalSession{}
+	ts.raw.Store(true)
+	r, pw := io.Pipe()
+	var modem lockedBuffer
+	done := make(chan error, 1)
+	go func() { done <- ts.userToModem(r, &modem, io.Discard) }()
+
+	pw.Write([]byte{0x1b})
+	waitUntil(t, func() bool { return modem.String() == "\x1b" })
+	pw.Close()
+	<-done
+}
@@ -0,0 +1,231 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+)
+
+// Snippet is a stored snippet offered by the ~p picker.
+type Snippet struct {
+	Name   string
+	Text   string
+	Source string // where it was defined: "mine", "site" or "global"
+}
+
+// Alternate screen: the picker draws over the session and restores it on
+// exit, like a pager.
+const (
+	altScreenOn  = "\x1b[?1049h"
+	altScreenOff = "\x1b[?1049l"
+)
+
+// pickerRows is how many snippets the picker lists at once.
+const pickerRows = 12
+
+// snippetPicker is the ~p overlay. Typing filters by name, arrows or
+// Ctrl+P/Ctrl+N move the selection, Enter inserts and Esc cancels.
+type snippetPicker struct {
+	items  []Snippet
+	filter []byte
+	sel    int
+}
+
+func (p *snippetPicker) matches() []Snippet {
+	if len(p.filter) == 0 {
+		return p.items
+	}
+	needle := strings.ToLower(string(p.filter))
+	var out []Snippet
+	for _, s := range p.items {
+		if strings.Contains(strings.ToLower(s.Name), needle) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (p *snippetPicker) move(delta int) {
+	n := len(p.matches())
+	if n == 0 {
+		return
+	}
+	p.sel = (p.sel + delta + n) % n
+}
+
+// pausableWriter holds modem output back while the picker covers the
+// screen, so it does not scribble over the overlay. At most limit bytes
+// are held; older output is dropped.
+type pausableWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	limit   int
+	paused  bool
+	held    bytes.Buffer
+	dropped int
+}
+
+func (p *pausableWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return p.w.Write(b)
+	}
+	p.held.Write(b)
+	if n := p.held.Len() - max(p.limit, 0); n > 0 {
+		p.held.Next(n)
+		p.dropped += n
+	}
+	return len(b), nil
+}
+
+func (p *pausableWriter) pause() {
+	p.mu.Lock()
+	p.paused = true
+	p.mu.Unlock()
+}
+
+// writeIfLive writes b unless output is paused; used for redraws that are
+// pointless while the picker covers the screen. It reports whether b was
+// written.
+func (p *pausableWriter) writeIfLive(b []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return false
+	}
+	p.w.Write(b)
+	return true
+}
+
+// resume writes out anything held back and lets output through again.
+func (p *pausableWriter) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = false
+	if p.dropped > 0 {
+		fmt.Fprintf(p.w, "\r\n*** %d bytes of output dropped while the picker was open ***\r\n", p.dropped)
+		p.dropped = 0
+	}
+	if p.held.Len() > 0 {
+		p.w.Write(p.held.Bytes())
+		p.held.Reset()
+	}
+}
+
+// openPicker shows the snippet picker for ~p.
+func (t *TerminalSession) openPicker(in *inputState, echo io.Writer) {
+	if len(t.opts.Snippets) == 0 {
+		notice(echo, "No stored snippets")
+		return
+	}
+	if t.out != nil {
+		t.out.pause()
+	}
+	in.picker = &snippetPicker{items: t.opts.Snippets}
+	fmt.Fprint(echo, altScreenOn)
+	t.drawPicker(in.picker, echo)
+}
+
+func (t *TerminalSession) closePicker(in *inputState, echo io.Writer) {
+	in.picker = nil
+	fmt.Fprint(echo, altScreenOff)
+	if t.out != nil {
+		t.out.resume()
+	}
+	t.drawStatus()
+}
+
+func (t *TerminalSession) drawPicker(p *snippetPicker, echo io.Writer) {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "Snippets for %s — type to filter, ↑/↓ to choose, Enter to insert, Esc to cancel\r\n\r\n", t.siteName)
+	fmt.Fprintf(&sb, "Filter: %s\r\n\r\n", p.filter)
+
+	matches := p.matches()
+	if len(matches) == 0 {
+		sb.WriteString("  (no matches)\r\n")
+	}
+	start := max(0, p.sel-pickerRows+1)
+	for i := start; i < len(matches) && i < start+pickerRows; i++ {
+		s := matches[i]
+		first, _, _ := strings.Cut(s.Text, "\n")
+		line := fmt.Sprintf("%-16s %-6s  %s", s.Name, s.Source, truncate(first, 50))
+		if i == p.sel {
+			fmt.Fprintf(&sb, "\x1b[7m> %s\x1b[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&sb, "  %s\r\n", line)
+		}
+	}
+
+	if p.sel < len(matches) {
+		sb.WriteString("\r\n")
+		text := strings.TrimSuffix(matches[p.sel].Text, "\n")
+		for _, l := range strings.SplitN(text, "\n", 8) {
+			fmt.Fprintf(&sb, "  │ %s\r\n", truncate(l, 70))
+		}
+	}
+	io.WriteString(echo, sb.String())
+}
+
+// pickerKey handles one key while the picker is open.
+func (t *TerminalSession) pickerKey(in *inputState, b byte, w io.Writer, echo io.Writer) error {
+	p := in.picker
+	switch b {
+	case '\r', '\n':
+		matches := p.matches()
+		t.closePicker(in, echo)
+		if p.sel >= len(matches) {
+			return nil
+		}
+		text := config.ExpandSnippet(matches[p.sel].Text, t.opts.Site, t.username, time.Now())
+		return t.startPaste(in, text, w, echo)
+	case 0x1b, 0x03:
+		t.closePicker(in, echo)
+		return nil
+	case 0x10: // Ctrl+P
+		p.move(-1)
+	case 0x0e: // Ctrl+N
+		p.move(1)
+	case 0x7f, 0x08:
+		if len(p.filter) > 0 {
+			p.filter = p.filter[:len(p.filter)-1]
+			p.sel = 0
+		}
+	default:
+		if b < ' ' {
+			return nil
+		}
+		p.filter = append(p.filter, b)
+		p.sel = 0
+	}
+	t.drawPicker(p, echo)
+	return nil
+}
+
+// pickerSeq handles an arrow key while the picker is open.
+func (t *TerminalSession) pickerSeq(in *inputState, seq string, echo io.Writer) {
+	switch seq {
+	case seqUp, "OA":
+		in.picker.move(-1)
+	case seqDown, "OB":
+		in.picker.move(1)
+	default:
+		return
+	}
+	t.drawPicker(in.picker, echo)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
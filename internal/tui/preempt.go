@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// preemption lets a queued dial take the line from a lower-priority call.
+type preemption struct {
+	priority int // the dial's priority
+	// rank returns the priority a live call was dialed at, and whether its
+	// owner is exempt from preemption.
+	rank  func(*session.Call) (priority int, exempt bool)
+	audit *session.AuditLog
+}
+
+// target picks the call to preempt: the lowest-ranked one below the dial's
+// priority that isn't exempt or already being preempted, oldest first on a
+// tie. It returns nil if there is none.
+func (p preemption) target(calls []*session.Call) (*session.Call, int) {
+	if p.rank == nil {
+		return nil, 0
+	}
+	var best *session.Call
+	bestRank := p.priority
+	for _, c := range calls {
+		if by, _ := c.Preempted(); by != "" {
+			continue
+		}
+		rank, exempt := p.rank(c)
+		if !exempt && rank < bestRank {
+			best, bestRank = c, rank
+		}
+	}
+	return best, bestRank
+}
+
+// refreshTarget picks the call a dial first in the queue could preempt.
+// Ranking calls reads the user store, so it is done when the dial queues
+// and on each queue tick rather than on every render.
+func (m *DialingModel) refreshTarget() {
+	m.target, m.targetRank = nil, 0
+	if m.preempting == nil && m.queuePosition() == 1 {
+		m.target, m.targetRank = m.preempt.target(m.calls.Calls())
+	}
+}
+
+// preemptHint describes the call a queued dial could preempt, or what it
+// is waiting for once it has asked.
+func (m DialingModel) preemptHint() string {
+	if m.preempting != nil {
+		_, at := m.preempting.Preempted()
+		return fmt.Sprintf("  Preempting %s (%s): the line frees up in %s",
+			m.preempting.Site, m.preempting.Owner, max(time.Until(at), 0).Round(time.Second))
+	}
+	if m.target == nil || m.queuePosition() != 1 {
+		return ""
+	}
+	return fmt.Sprintf("  Press P to preempt %s (%s, priority %d); your dial has priority %d",
+		m.target.Site, m.target.Owner, m.targetRank, m.preempt.priority)
+}
+
+// DialPriority ranks a dial by user to site at the higher of the two's
+// priority levels.
+func DialPriority(store auth.UserStore, user string, site config.Site) int {
+	info, err := store.Get(user)
+	if err != nil {
+		slog.Error("loading user priority", "user", user, "err", err)
+	}
+	return max(info.Priority, site.Priority)
+}
+
+// rankCall returns the priority c was dialed at and whether its owner has
+// the right not to be preempted.
+func (m Model) rankCall(c *session.Call) (int, bool) {
+	exempt, err := m.store.HasRight(c.Owner, auth.RightNoPreempt)
+	if err != nil {
+		slog.Error("right check failed", "user", c.Owner, "right", auth.RightNoPreempt, "err", err)
+	}
+	return c.Priority, exempt
+}
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestPreemptionTarget(t *testing.T) {
+	calls := []*session.Call{
+		{Site: "edge1", Owner: "carol"},
+		{Site: "lab1", Owner: "dave"},
+		{Site: "lab2", Owner: "erin"},
+		{Site: "core1", Owner: "frank"},
+	}
+	ranks := map[string]int{"carol": 2, "dave": 0, "erin": 0, "frank": 5}
+	p := preemption{
+		priority: 3,
+		rank: func(c *session.Call) (int, bool) {
+			return ranks[c.Owner], c.Owner == "dave"
+		},
+	}
+
+	// dave is exempt, so erin's call is the lowest-ranked one left.
+	if c, rank := p.target(calls); c != calls[2] || rank != 0 {
+		t.Errorf("target = %+v (rank %d), want erin's call", c, rank)
+	}
+	ranks["erin"] = 3
+	if c, _ := p.target(calls); c != calls[0] {
+		t.Errorf("target = %+v, want carol's call", c)
+	}
+	p.priority = 2
+	if c, _ := p.target(calls); c != nil {
+		t.Errorf("target = %+v, want none at or above the dial's priority", c)
+	}
+}
+
+func TestDialPriority(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "password1")
+	store.SetPriority("alice", 4)
+
+	if got := DialPriority(store, "alice", config.Site{Priority: 2}); got != 4 {
+		t.Errorf("user priority: got %d, want 4", got)
+	}
+	if got := DialPriority(store, "alice", config.Site{Priority: 7}); got != 7 {
+		t.Errorf("site priority: got %d, want 7", got)
+	}
+}
+
+func TestRankCallUsesDialedPriority(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("carol", "password1")
+	call := &session.Call{Site: "lab1", Owner: "carol", Priority: 1}
+
+	// Raising carol's priority mid-call doesn't shield the call she placed.
+	store.SetPriority("carol", 9)
+	if rank, exempt := (Model{store: store}).rankCall(call); rank != 1 || exempt {
+		t.Errorf("rankCall = %d, %v; want 1, false", rank, exempt)
+	}
+}
@@ -56,6 +56,11 @@ func checkSIPStatus() tea.Msg {
 	return sipStatusMsg(info)
 }
 
+// CheckHealth runs the same health checks as the menu's status bar.
+func CheckHealth() SIPInfo {
+	return SIPInfo(checkSIPStatus().(sipStatusMsg))
+}
+
 // parseSIPRegistrations extracts registration info from pjsip show registrations output.
 func parseSIPRegistrations(output string) SIPInfo {
 	lines := strings.Split(output, "\n")
@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+)
+
+// keysPrompt is an action on the keys screen waiting for input.
+type keysPrompt int
+
+const (
+	keysPromptNone   keysPrompt = iota
+	keysPromptAdd               // pasting a public key
+	keysPromptRemove            // confirming removal of the selected key
+)
+
+// KeysModel lets users manage the SSH public keys they log in with, and
+// turn password login off once they have one.
+type KeysModel struct {
+	store    auth.UserStore
+	username string
+	theme    Theme
+
+	keys             []auth.AuthorizedKey
+	passwordDisabled bool
+	cursor           int
+	prompt           keysPrompt
+	input            textinput.Model
+
+	notice string
+	err    string
+}
+
+// NewKeysModel creates the keys screen for username.
+func NewKeysModel(username string, store auth.UserStore, theme Theme) KeysModel {
+	in := textinput.New()
+	in.Placeholder = "ssh-ed25519 AAAA... you@laptop"
+	in.CharLimit = 16384
+	in.Width = 60
+	m := KeysModel{store: store, username: username, theme: theme, input: in}
+	m.reload()
+	return m
+}
+
+// reload re-reads the user's keys, keeping the cursor in range.
+func (m *KeysModel) reload() {
+	info, err := m.store.Get(m.username)
+	if err != nil {
+		m.err = fmt.Sprintf("loading keys: %v", err)
+		return
+	}
+	m.keys = info.AuthorizedKeys
+	m.passwordDisabled = info.PasswordDisabled
+	m.cursor = min(m.cursor, max(len(m.keys)-1, 0))
+}
+
+func (m KeysModel) Update(msg tea.Msg) (KeysModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if m.prompt == keysPromptAdd {
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+	m.notice, m.err = "", ""
+
+	switch m.prompt {
+	case keysPromptAdd:
+		switch key.String() {
+		case "enter":
+			m.prompt = keysPromptNone
+			m.input.Blur()
+			m.addKey(m.input.Value())
+			return m, nil
+		case "esc":
+			m.prompt = keysPromptNone
+			m.input.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	case keysPromptRemove:
+		m.prompt = keysPromptNone
+		if key.String() == "y" {
+			m.removeSelected()
+		}
+		return m, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.keys)-1, 0))
+	case "a":
+		m.prompt = keysPromptAdd
+		m.input.Reset()
+		return m, m.input.Focus()
+	case "x":
+		if len(m.keys) > 0 {
+			m.prompt = keysPromptRemove
+		}
+	case "d":
+		m.togglePassword()
+	case "esc", "q":
+		return m, func() tea.Msg { return KeysDoneMsg{} }
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *KeysModel) addKey(line string) {
+	key, err := m.store.AddKey(m.username, line)
+	if err != nil {
+		m.err = err.Error()
+		return
+	}
+	m.reload()
+	m.cursor = len(m.keys) - 1
+	m.notice = "Added key " + key.Fingerprint() + "."
+}
+
+func (m *KeysModel) removeSelected() {
+	if m.cursor >= len(m.keys) {
+		return
+	}
+	fp := m.keys[m.cursor].Fingerprint()
+	if err := m.store.RemoveKey(m.username, fp); err != nil {
+		m.err = err.Error()
+		return
+	}
+	m.reload()
+	m.notice = "Removed key " + fp + "."
+}
+
+func (m *KeysModel) togglePassword() {
+	disable := !m.passwordDisabled
+	if err := m.store.SetPasswordDisabled(m.username, disable); err != nil {
+		m.err = err.Error()
+		return
+	}
+	m.reload()
+	if disable {
+		m.notice = "Password login turned off; log in with one of your keys."
+	} else {
+		m.notice = "Password login turned back on."
+	}
+}
+
+func (m KeysModel) View() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("SSH Keys"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "  %-51s %-20s %-10s %s\n", "FINGERPRINT", "TYPE", "ADDED", "COMMENT")
+	for i, k := range m.keys {
+		line := fmt.Sprintf("%-51s %-20s %-10s %s", k.Fingerprint(), truncate(k.Type(), 20),
+			k.Added.Local().Format("2006-01-02"), truncate(k.Comment, 30))
+		if i == m.cursor {
+			b.WriteString(m.theme.InputStyle.Render("> " + line))
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	if len(m.keys) == 0 {
+		b.WriteString(m.theme.LabelStyle.Render("  No keys. Add one to log in without a password."))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	if m.passwordDisabled {
+		b.WriteString(m.theme.WarningStyle.Render("  Password login: off (keys only)"))
+	} else {
+		b.WriteString(m.theme.LabelStyle.Render("  Password login: on"))
+	}
+	b.WriteString("\n\n")
+
+	switch m.prompt {
+	case keysPromptAdd:
+		fmt.Fprintf(&b, "  Public key: %s\n", m.input.View())
+		b.WriteString(m.theme.LabelStyle.Render("  Paste a line from ~/.ssh/id_ed25519.pub or similar · Enter to add · Esc to cancel"))
+	case keysPromptRemove:
+		b.WriteString(m.theme.WarningStyle.Render(fmt.Sprintf("  Remove key %s? y to confirm, any other key to cancel", m.keys[m.cursor].Fingerprint())))
+	default:
+		if m.notice != "" {
+			b.WriteString(m.theme.SuccessStyle.Render("  " + m.notice))
+			b.WriteString("\n")
+		}
+		if m.err != "" {
+			b.WriteString(m.theme.ErrorStyle.Render("  " + m.err))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.theme.LabelStyle.Render("  a add · x remove · d password login on/off · esc back"))
+	}
+	return m.theme.BoxStyle.Render(b.String())
+}
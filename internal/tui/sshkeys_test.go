@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestKeysScreen(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "password1")
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := gossh.NewPublicKey(pub)
+	line := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))) + " alice@laptop"
+
+	press := func(m KeysModel, k string) KeysModel {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		m, _ = m.Update(msg)
+		return m
+	}
+
+	m := NewKeysModel("alice", store, NewTheme(nil))
+	if m = press(m, "d"); !strings.Contains(m.View(), "add a key before disabling") {
+		t.Errorf("password login turned off with no keys:\n%s", m.View())
+	}
+	m = press(m, "a")
+	m = press(m, line)
+	m = press(m, "enter")
+	if !strings.Contains(m.View(), gossh.FingerprintSHA256(key)) || !strings.Contains(m.View(), "alice@laptop") {
+		t.Fatalf("added key not listed:\n%s", m.View())
+	}
+
+	m = press(m, "d")
+	if !m.passwordDisabled || !strings.Contains(m.View(), "Password login: off") {
+		t.Errorf("password login not turned off:\n%s", m.View())
+	}
+	m = press(m, "x")
+	m = press(m, "y")
+	if len(m.keys) != 1 || !strings.Contains(m.View(), "last key") {
+		t.Errorf("removed the only key with password login off:\n%s", m.View())
+	}
+
+	m = press(m, "d")
+	m = press(m, "x")
+	m = press(m, "y")
+	if len(m.keys) != 0 {
+		t.Errorf("key not removed:\n%s", m.View())
+	}
+	if info, _ := store.Get("alice"); len(info.AuthorizedKeys) != 0 || info.PasswordDisabled {
+		t.Errorf("store = %+v", info)
+	}
+}
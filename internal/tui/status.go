@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusInfo is a snapshot of the call for the status line.
+type statusInfo struct {
+	site         string
+	rate         int // bps, 0 if unknown
+	elapsed      time.Duration
+	idle         time.Duration
+	idleLimit    time.Duration // 0 if there is no idle timeout
+	bytesIn      int64
+	bytesOut     int64
+	raw          bool
+	carrierUp    bool
+	carrierKnown bool // read from DCD rather than inferred from call state
+	pasteSent    int64
+	pasteTotal   int // 0 when no paste is in progress
+}
+
+// formatStatus renders the status line, padded or cut to width.
+func formatStatus(s statusInfo, width int) string {
+	rate := "rate ?"
+	if s.rate > 0 {
+		rate = fmt.Sprintf("%d bps", s.rate)
+	}
+	idle := "idle " + clock(s.idle)
+	if s.idleLimit > 0 {
+		idle += "/" + clock(s.idleLimit)
+	}
+	mode := "LINE"
+	if s.raw {
+		mode = "RAW"
+	}
+	carrier := "○ NO CARRIER"
+	switch {
+	case s.carrierUp && s.carrierKnown:
+		carrier = "● CD"
+	case s.carrierUp:
+		carrier = "● online"
+	}
+
+	parts := []string{
+		s.site,
+		rate,
+		"up " + clock(s.elapsed),
+		fmt.Sprintf("↓%s ↑%s", humanBytes(s.bytesIn), humanBytes(s.bytesOut)),
+		idle,
+		mode,
+		carrier,
+	}
+	if s.pasteTotal > 0 {
+		parts = append(parts, fmt.Sprintf("paste %d/%d", s.pasteSent, s.pasteTotal))
+	}
+
+	line := " " + strings.Join(parts, " │ ")
+	if n := len([]rune(line)); n < width {
+		return line + strings.Repeat(" ", width-n)
+	}
+	return truncate(line, width)
+}
+
+// clock formats d as h:mm:ss, or m:ss under an hour.
+func clock(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// humanBytes formats n bytes compactly, e.g. 340B or 1.2K.
+func humanBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fK", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/(1024*1024))
+	}
+}
+
+// syncWriter serializes writes to the user's terminal, so a status line
+// redraw is never split by modem output or local echo.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// size returns the terminal's current width and height.
+func (t *TerminalSession) size() (width, height int) {
+	return int(t.width.Load()), int(t.height.Load())
+}
+
+// hasStatus reports whether the terminal is tall enough for a status line.
+func (t *TerminalSession) hasStatus() bool {
+	width, height := t.size()
+	return height >= 3 && width > 0
+}
+
+// reserveStatus confines scrolling to all but the bottom row, leaving that
+// row for the status line. Setting the scroll region homes the cursor, so
+// it is saved and restored around it; the newline first makes room if the
+// cursor is already on the bottom row.
+func (t *TerminalSession) reserveStatus(w io.Writer) {
+	_, height := t.size()
+	fmt.Fprintf(w, "\r\n\x1b[1A\x1b7\x1b[1;%dr\x1b8", height-1)
+}
+
+// releaseStatus restores full-screen scrolling and clears the status row,
+// if there is one.
+func (t *TerminalSession) releaseStatus(w io.Writer) {
+	if !t.hasStatus() {
+		return
+	}
+	_, height := t.size()
+	fmt.Fprintf(w, "\x1b7\x1b[r\x1b[%d;1H\x1b[2K\x1b8", height)
+}
+
+// followResize picks up a window change from opts.Size. The next redraw
+// moves the scroll region to the new bottom row; a window too short for a
+// status line gets full-screen scrolling back.
+func (t *TerminalSession) followResize() {
+	if t.opts.Size == nil || t.out == nil {
+		return
+	}
+	width, height := t.opts.Size()
+	if w, h := t.size(); width <= 0 || width == w && height == h {
+		return
+	}
+	had := t.hasStatus()
+	t.width.Store(int64(width))
+	t.height.Store(int64(height))
+	switch {
+	case t.hasStatus():
+		t.resized.Store(true)
+	case had:
+		t.out.writeIfLive([]byte("\x1b7\x1b[r\x1b8"))
+	}
+}
+
+// drawStatus repaints the status line, unless the snippet picker has the
+// screen. After a window change it first moves the scroll region, which
+// homes the cursor, inside the same save and restore.
+func (t *TerminalSession) drawStatus() {
+	if t.out == nil || !t.hasStatus() {
+		return
+	}
+	width, height := t.size()
+	var region string
+	if t.resized.Swap(false) {
+		region = fmt.Sprintf("\x1b[1;%dr", height-1)
+	}
+	line := formatStatus(t.statusInfo(time.Now()), width)
+	if !t.out.writeIfLive([]byte(fmt.Sprintf("\x1b7%s\x1b[%d;1H\x1b[2K\x1b[7m%s\x1b[0m\x1b8", region, height, line))) && region != "" {
+		t.resized.Store(true)
+	}
+}
+
+func (t *TerminalSession) statusInfo(now time.Time) statusInfo {
+	up, known := t.call.Carrier()
+	s := statusInfo{
+		site:         t.siteName,
+		rate:         t.call.ConnectRate(),
+		elapsed:      now.Sub(t.call.Started),
+		idle:         now.Sub(t.call.LastInput()),
+		idleLimit:    t.opts.IdleTimeout,
+		bytesIn:      t.call.BytesIn(),
+		bytesOut:     t.call.BytesOut(),
+		raw:          t.raw.Load(),
+		carrierUp:    up,
+		carrierKnown: known,
+	}
+	if p := t.pasting.Load(); p != nil && len(p.lines) > 1 {
+		s.pasteSent, s.pasteTotal = p.sent.Load(), len(p.lines)
+	}
+	return s
+}
+
+// runStatus redraws the status line every second until stop is closed.
+func (t *TerminalSession) runStatus(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	t.drawStatus()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.followResize()
+			t.drawStatus()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestFormatStatus(t *testing.T) {
+	s := statusInfo{
+		site:         "router1",
+		rate:         14400,
+		elapsed:      time.Hour + 2*time.Minute + 3*time.Second,
+		idle:         45 * time.Second,
+		idleLimit:    30 * time.Minute,
+		bytesIn:      1536,
+		bytesOut:     340,
+		carrierUp:    true,
+		carrierKnown: true,
+	}
+	got := formatStatus(s, 120)
+	want := " router1 │ 14400 bps │ up 1:02:03 │ ↓1.5K ↑340B │ idle 0:45/30:00 │ LINE │ ● CD"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("status = %q, want prefix %q", got, want)
+	}
+	if n := len([]rune(got)); n != 120 {
+		t.Errorf("status width = %d, want 120", n)
+	}
+
+	s.raw, s.rate, s.carrierKnown = true, 0, false
+	s.pasteSent, s.pasteTotal = 3, 10
+	got = formatStatus(s, 200)
+	for _, want := range []string{"rate ?", "RAW", "● online", "paste 3/10"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("status %q missing %q", got, want)
+		}
+	}
+
+	s.carrierUp = false
+	if got := formatStatus(s, 40); !strings.HasSuffix(got, "…") || len([]rune(got)) != 40 {
+		t.Errorf("narrow status = %q, want cut to 40 columns", got)
+	}
+}
+
+func TestClock(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{59*time.Second + 600*time.Millisecond, "1:00"},
+		{12*time.Minute + 5*time.Second, "12:05"},
+		{3*time.Hour + 4*time.Second, "3:00:04"},
+	}
+	for _, tt := range tests {
+		if got := clock(tt.d); got != tt.want {
+			t.Errorf("clock(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestStatusFollowsResize(t *testing.T) {
+	mdm, dev, remote := testModem(t)
+	call, err := session.NewManager(t.TempDir(), 0, 1024, 0, nil).Start("alice", session.Ticket{}, 0, config.Site{Name: "lab"}, dev, mdm, modem.NewDeviceLock(dev), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		remote.Close()
+		<-call.Done()
+	}()
+
+	width, height := 80, 24
+	ts := NewTerminalSession(call, "alice", TerminalOptions{Width: width, Height: height, Size: func() (int, int) { return width, height }})
+	var out bytes.Buffer
+	ts.out = &pausableWriter{w: &out}
+	ts.followResize()
+	ts.drawStatus()
+	if got := out.String(); strings.Contains(got, "r\x1b[") || !strings.Contains(got, "\x1b[24;1H") {
+		t.Errorf("status without a resize = %q", got)
+	}
+
+	// The scroll region moves with the bottom row.
+	width, height = 100, 40
+	out.Reset()
+	ts.followResize()
+	ts.drawStatus()
+	if got := out.String(); !strings.Contains(got, "\x1b[1;39r") || !strings.Contains(got, "\x1b[40;1H") {
+		t.Errorf("status after a resize = %q", got)
+	}
+
+	// While the picker has the screen, the move waits for it to close.
+	height = 30
+	ts.out.pause()
+	ts.followResize()
+	ts.drawStatus()
+	ts.out.resume()
+	out.Reset()
+	ts.drawStatus()
+	if got := out.String(); !strings.Contains(got, "\x1b[1;29r") {
+		t.Errorf("status after the picker closed = %q", got)
+	}
+
+	// Too short for a status line: full-screen scrolling comes back.
+	height = 2
+	out.Reset()
+	ts.followResize()
+	ts.drawStatus()
+	if got := out.String(); got != "\x1b7\x1b[r\x1b8" {
+		t.Errorf("output after shrinking = %q", got)
+	}
+}
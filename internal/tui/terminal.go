@@ -1,57 +1,84 @@
 package tui
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"regexp"
 	"sync/atomic"
 	"time"
 
-	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/config"
 	"github.com/gbm-dev/pots/internal/session"
 )
 
-// TerminalSession is a tea.ExecCommand that runs raw bidirectional I/O
-// between the user's terminal and the modem, with line-buffered input
-// and ~. escape detection.
+// TerminalSession is a tea.ExecCommand that attaches the user's terminal to
+// a live call, with line-buffered or raw input and ~ escape detection. If the SSH
+// connection drops the call is detached rather than hung up, so it can be
+// reattached from the menu within the grace period.
 type TerminalSession struct {
-	modem    *modem.Modem
-	device   string
+	call     *session.Call
+	username string
 	siteName string
-	lock     *modem.DeviceLock
-	logger   *session.Logger
-	logDir   string
+	opts     TerminalOptions
 
-	stdin  io.Reader // set by tea.Exec via SetStdin
-	stdout io.Writer // set by tea.Exec via SetStdout
+	stdin  io.Reader       // set by tea.Exec via SetStdin
+	stdout io.Writer       // set by tea.Exec via SetStdout
+	out    *pausableWriter // stdout for modem output and warnings
 
-	carrierLost atomic.Bool
+	raw     atomic.Bool           // raw pass-through rather than line-buffered input
+	pasting atomic.Pointer[paste] // paste being paced to the modem, if any
+
+	width, height atomic.Int64 // current terminal size, for the status line
+	resized       atomic.Bool  // the scroll region must move to the new size
+}
+
+// TerminalOptions configures a TerminalSession.
+type TerminalOptions struct {
+	Grace       time.Duration    // hold the call this long if the SSH connection drops
+	Reattach    bool             // replay scrollback instead of waking the remote end
+	IdleTimeout time.Duration    // hang up after this long without input; 0 disables
+	MaxDuration time.Duration    // hang up this long after the call started; 0 disables
+	RawMode     bool             // start in raw pass-through mode instead of line mode
+	EscapeChar  byte             // starts escape commands after Enter; 0 means ~
+	Snippets    []Snippet        // offered by the ~p picker
+	History     *session.History // line mode history for this user and site; nil keeps none
+	Site        config.Site      // for snippet variables
+	Scrollback  int              // bytes of output held back while the picker is open
+
+	// Terminal size at the start of the session, for the status line. A
+	// Height below 3 disables it. Size, if set, reports the current size;
+	// it is polled so the status line follows window changes.
+	Width  int
+	Height int
+	Size   func() (width, height int)
+
+	// Paste pacing; see config.Site.
+	CharDelay     time.Duration
+	LineDelay     time.Duration
+	Prompt        *regexp.Regexp
+	PromptTimeout time.Duration
+	PasteWait     bool
 }
 
-// NewTerminalSession creates a terminal pass-through session.
-func NewTerminalSession(mdm *modem.Modem, device, siteName, logDir string, lock *modem.DeviceLock) *TerminalSession {
-	return &TerminalSession{
-		modem:    mdm,
-		device:   device,
-		siteName: siteName,
-		lock:     lock,
-		logDir:   logDir,
+// NewTerminalSession creates a terminal pass-through session for call.
+func NewTerminalSession(call *session.Call, username string, opts TerminalOptions) *TerminalSession {
+	t := &TerminalSession{
+		call:     call,
+		username: username,
+		siteName: call.Site,
+		opts:     opts,
 	}
+	t.raw.Store(opts.RawMode)
+	t.width.Store(int64(opts.Width))
+	t.height.Store(int64(opts.Height))
+	return t
 }
 
 // Run implements tea.ExecCommand. It takes over stdin/stdout for raw I/O.
 func (t *TerminalSession) Run() error {
-	// Create session logger
-	var err error
-	t.logger, err = session.NewLogger(t.logDir, t.siteName, t.device)
-	if err != nil {
-		return fmt.Errorf("creating session logger: %w", err)
-	}
-	defer t.cleanup()
-
-	rwc := t.modem.ReadWriteCloser()
-
 	// Use the I/O provided by tea.Exec (SSH channel), fall back to os std.
 	stdin := t.stdin
 	stdout := t.stdout
@@ -61,68 +88,93 @@ func (t *TerminalSession) Run() error {
 	if stdout == nil {
 		stdout = os.Stdout
 	}
+	stdout = &syncWriter{w: stdout}
 
 	// Print connection banner
-	banner := fmt.Sprintf("\r\n*** CONNECTED to %s — Type commands, press Enter to send, ~. to disconnect, Ctrl+C to abort ***\r\n\r\n", t.siteName)
+	esc := t.escapeName()
+	banner := fmt.Sprintf("\r\n*** CONNECTED to %s — Type commands, press Enter to send, %s. to disconnect, %s? for help, Ctrl+C to abort ***\r\n\r\n", t.siteName, esc, esc)
+	if t.raw.Load() {
+		banner = fmt.Sprintf("\r\n*** CONNECTED to %s (raw mode) — keys go straight to the device, Enter %s. to disconnect, Enter %s? for help ***\r\n\r\n", t.siteName, esc, esc)
+	}
+	if t.opts.Reattach {
+		banner = fmt.Sprintf("\r\n*** REATTACHED to %s — replaying scrollback, %s. to disconnect ***\r\n\r\n", t.siteName, esc)
+	}
 	fmt.Fprint(stdout, banner)
 
-	// Modem→user: tee to logger, track when we first receive data
-	loggedReader := t.logger.TeeReader(rwc)
-	var gotData atomic.Bool
+	// Ask the terminal to mark pastes so they can be paced.
+	fmt.Fprint(stdout, pasteModeOn)
+	defer fmt.Fprint(stdout, pasteModeOff)
 
-	done := make(chan error, 2)
+	// Keep the bottom row for the status line.
+	if t.hasStatus() {
+		t.reserveStatus(stdout)
+	}
+	defer t.releaseStatus(stdout)
 
-	// Modem → user
-	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, err := loggedReader.Read(buf)
-			if n > 0 {
-				gotData.Store(true)
-				if _, werr := stdout.Write(buf[:n]); werr != nil {
-					done <- werr
-					return
-				}
-			}
-			if err != nil {
-				t.carrierLost.Store(true)
-				done <- err
-				return
-			}
-		}
-	}()
+	// Modem output reaches stdout through the call from here on, held
+	// back while the snippet picker is open.
+	t.out = &pausableWriter{w: stdout, limit: t.opts.Scrollback}
+	if err := t.call.Attach(t.username, t.out, t.opts.Reattach); err != nil {
+		return err
+	}
 
-	// User → modem (line-buffered with ~. escape and Ctrl+C)
+	// User → modem (line or raw mode, with ~ escapes)
+	userDone := make(chan error, 1)
 	go func() {
-		done <- t.userToModem(stdin, rwc, stdout)
+		userDone <- t.userToModem(stdin, t.call, stdout)
 	}()
 
-	// Send Enter every 2s until remote responds, then stop.
-	// Keeps modem carrier alive and wakes the remote terminal.
-	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
-		// Send first Enter immediately
-		if _, err := rwc.Write([]byte("\r")); err != nil {
-			slog.Debug("wake: initial enter failed", "err", err)
+	if !t.opts.Reattach {
+		go t.wake()
+	}
+
+	stopLimits := make(chan struct{})
+	defer close(stopLimits)
+	go t.enforceLimits(t.out, stopLimits)
+	if t.hasStatus() || t.opts.Size != nil {
+		go t.runStatus(stopLimits)
+	}
+
+	select {
+	case <-t.call.Done():
+		reason := t.call.EndReason()
+		fmt.Fprintf(stdout, "\r\n*** Call ended: %s ***\r\n", reason)
+		return fmt.Errorf("call ended: %s", reason)
+	case err := <-userDone:
+		if err != nil && t.opts.Grace > 0 {
+			// Input failed rather than the user disconnecting: the SSH
+			// connection is gone, so hold the call for reattach.
+			slog.Info("terminal input ended, detaching call", "site", t.siteName, "user", t.username, "err", err)
+			t.call.Detach(t.opts.Grace)
+			return err
+		}
+		t.call.Hangup("disconnected by " + t.username)
+		return err
+	}
+}
+
+// wake sends Enter every 2s until the remote responds, then stops.
+// Keeps modem carrier alive and wakes the remote terminal.
+func (t *TerminalSession) wake() {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	// Send first Enter immediately
+	if _, err := t.call.Write([]byte("\r")); err != nil {
+		slog.Debug("wake: initial enter failed", "err", err)
+		return
+	}
+	slog.Debug("wake: sent initial enter")
+	for range ticker.C {
+		if t.call.GotData() {
+			slog.Debug("wake: got data from remote, stopping")
 			return
 		}
-		slog.Debug("wake: sent initial enter")
-		for range ticker.C {
-			if gotData.Load() {
-				slog.Debug("wake: got data from remote, stopping")
-				return
-			}
-			if _, err := rwc.Write([]byte("\r")); err != nil {
-				slog.Debug("wake: enter failed", "err", err)
-				return
-			}
-			slog.Debug("wake: sent enter")
+		if _, err := t.call.Write([]byte("\r")); err != nil {
+			slog.Debug("wake: enter failed", "err", err)
+			return
 		}
-	}()
-
-	// Wait for either direction to finish
-	return <-done
+		slog.Debug("wake: sent enter")
+	}
 }
 
 // SetStdin stores the SSH session's stdin for use in Run().
@@ -134,84 +186,138 @@ func (t *TerminalSession) SetStdout(w io.Writer) { t.stdout = w }
 // SetStderr is required by tea.ExecCommand.
 func (t *TerminalSession) SetStderr(w io.Writer) {}
 
-// userToModem reads from user with line buffering: characters are echoed
-// locally and accumulated in a buffer, then sent to the modem on Enter.
-// Supports backspace editing, ~. escape sequence, and Ctrl+C disconnect.
+// inputState tracks keyboard input between reads.
+type inputState struct {
+	lineBuf     []byte         // line mode: text typed but not yet sent
+	cursor      int            // line mode: edit position in lineBuf
+	shown       int            // line mode: cursor column on screen, relative to the line start
+	hist        []string       // line mode: command history, oldest first
+	histPos     int            // index into hist being edited; len(hist) for a new line
+	draft       []byte         // the new line, kept while browsing history
+	search      *historySearch // Ctrl+R reverse search, while active
+	atLineStart bool           // last key was Enter, so ~ starts an escape
+	escPending  bool           // ~ seen at line start, waiting for the command key
+	picker      *snippetPicker // ~p overlay, while open
+}
+
+// userToModem reads keystrokes from the user and forwards them to the modem.
+// In line mode characters are echoed locally and sent on Enter, with
+// backspace editing and Ctrl+C to disconnect. In raw mode every byte goes
+// straight to the modem, including Ctrl+C. In both modes the escape
+// character (~ by default) after Enter starts an escape command; see
+// escapeHelp. Line mode supports readline-style editing and history; see
+// lineInput. Bracketed pastes are paced by startPaste; while one is being
+// sent, Ctrl+C or Esc aborts it and other keys are dropped.
 func (t *TerminalSession) userToModem(r io.Reader, w io.Writer, echo io.Writer) error {
-	buf := make([]byte, 1)
-	var lineBuf []byte
-	var prevWasEnter bool
+	br := bufio.NewReader(r)
+	in := &inputState{}
+	if t.opts.History != nil {
+		in.hist = t.opts.History.Entries()
+	}
+	in.histPos = len(in.hist)
+	defer t.abortPaste()
 
 	for {
-		n, err := r.Read(buf)
+		b, err := br.ReadByte()
 		if err != nil {
 			return err
 		}
-		if n == 0 {
-			continue
-		}
 
-		b := buf[0]
-
-		// Ctrl+C: disconnect immediately
-		if b == 0x03 {
-			return nil
+		if b == 0x1b && atPasteStart(br) {
+			text, err := readPaste(br)
+			if err != nil {
+				return err
+			}
+			if err := t.startPaste(in, text, w, echo); err != nil {
+				return err
+			}
+			continue
 		}
-
-		// Backspace (DEL or BS): remove last char from buffer
-		if b == 0x7f || b == 0x08 {
-			if len(lineBuf) > 0 {
-				lineBuf = lineBuf[:len(lineBuf)-1]
-				// Erase character on terminal: backspace, space, backspace
-				echo.Write([]byte{0x08, ' ', 0x08})
+		if p := t.pasting.Load(); p != nil {
+			if b == 0x03 || b == 0x1b {
+				p.cancel()
 			}
 			continue
 		}
-
-		// Enter: send buffered line to modem
-		if b == '\r' || b == '\n' {
-			// Check for ~. escape: line buffer starts with ~.
-			if prevWasEnter && len(lineBuf) == 2 && lineBuf[0] == '~' && lineBuf[1] == '.' {
-				return nil // disconnect
+		// Arrow and editing keys; raw mode passes them to the device.
+		if b == 0x1b && (in.picker != nil || !t.raw.Load()) {
+			if seq := readEscSequence(br); seq != "" {
+				if in.picker != nil {
+					t.pickerSeq(in, seq, echo)
+				} else if err := t.lineSeq(in, seq, w, echo); err != nil {
+					return err
+				}
+				continue
 			}
+		}
 
-			// Echo the newline locally
-			echo.Write([]byte("\r\n"))
+		quit, err := t.handleKey(in, b, w, echo)
+		if err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+}
 
-			// Send buffered line + CR to modem
-			if len(lineBuf) > 0 {
-				line := append(lineBuf, '\r')
-				if _, werr := w.Write(line); werr != nil {
-					return werr
-				}
-			} else {
-				// Empty enter: just send CR
-				if _, werr := w.Write([]byte{'\r'}); werr != nil {
-					return werr
-				}
-			}
+// handleKey processes one byte of user input, returning quit when the user
+// asked to disconnect.
+func (t *TerminalSession) handleKey(in *inputState, b byte, w io.Writer, echo io.Writer) (bool, error) {
+	if in.picker != nil {
+		return false, t.pickerKey(in, b, w, echo)
+	}
 
-			lineBuf = lineBuf[:0]
-			prevWasEnter = true
-			continue
+	esc := t.escapeChar()
+	if in.escPending {
+		in.escPending = false
+		if b == esc {
+			// Doubled escape sends one literal escape character.
+			return t.handleInput(in, esc, w, echo)
 		}
+		if handled, quit, err := t.escapeCommand(in, b, w, echo); handled {
+			return quit, err
+		}
+		// Not an escape command: the escape and this key are ordinary input.
+		if quit, err := t.handleInput(in, esc, w, echo); quit || err != nil {
+			return quit, err
+		}
+		return t.handleInput(in, b, w, echo)
+	}
 
-		// Regular character: add to buffer and echo locally
-		lineBuf = append(lineBuf, b)
-		echo.Write([]byte{b})
-		prevWasEnter = false
+	if in.atLineStart && b == esc {
+		in.escPending = true
+		return false, nil
 	}
+	return t.handleInput(in, b, w, echo)
 }
 
-func (t *TerminalSession) cleanup() {
-	if t.logger != nil {
-		t.logger.Close()
+func (t *TerminalSession) handleInput(in *inputState, b byte, w io.Writer, echo io.Writer) (bool, error) {
+	if t.raw.Load() {
+		return false, rawInput(in, b, w)
 	}
-	if t.carrierLost.Load() {
-		slog.Info("carrier already lost, skipping hangup")
+	return t.lineInput(in, b, w, echo)
+}
+
+// toggleMode switches between line and raw mode. Escapes only fire at the
+// start of a line, so there is never buffered text to carry across.
+func (t *TerminalSession) toggleMode(in *inputState, echo io.Writer) {
+	raw := !t.raw.Load()
+	t.raw.Store(raw)
+	in.resetLine()
+	in.atLineStart = true
+	if raw {
+		fmt.Fprint(echo, "\r\n*** Raw mode: keys go straight to the device ***\r\n")
 	} else {
-		t.modem.Hangup()
+		fmt.Fprint(echo, "\r\n*** Line mode: press Enter to send ***\r\n")
+	}
+}
+
+// rawInput forwards b unmodified; the remote end does any echoing.
+func rawInput(in *inputState, b byte, w io.Writer) error {
+	if _, err := w.Write([]byte{b}); err != nil {
+		return err
 	}
-	t.modem.Close()
-	t.lock.Release()
+	in.atLineStart = b == '\r' || b == '\n'
+	return nil
 }
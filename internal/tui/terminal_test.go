@@ -159,6 +159,77 @@ func TestUserToModem_CtrlC(t *testing.T) {
 	}
 }
 
+func TestUserToModem_RawMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantModem string
+		wantEcho  string
+	}{
+		{
+			name:      "bytes forwarded immediately without echo",
+			input:     "ls\t\x1b[A\x03\x1a",
+			wantModem: "ls\t\x1b[A\x03\x1a",
+			wantEcho:  "",
+		},
+		{
+			name:      "enter tilde dot disconnects",
+			input:     "exit\r~.more",
+			wantModem: "exit\r",
+			wantEcho:  "",
+		},
+		{
+			name:      "tilde mid-line is forwarded",
+			input:     "a~.b",
+			wantModem: "a~.b",
+			wantEcho:  "",
+		},
+		{
+			name:      "tilde at line start with other key is forwarded",
+			input:     "\r~x",
+			wantModem: "\r~x",
+			wantEcho:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &TerminalSession{}
+			ts.raw.Store(true)
+			r := strings.NewReader(tt.input)
+			var modemBuf, echoBuf bytes.Buffer
+
+			ts.userToModem(r, &modemBuf, &echoBuf)
+
+			if got := modemBuf.String(); got != tt.wantModem {
+				t.Errorf("modem output = %q, want %q", got, tt.wantModem)
+			}
+			if got := echoBuf.String(); got != tt.wantEcho {
+				t.Errorf("echo output = %q, want %q", got, tt.wantEcho)
+			}
+		})
+	}
+}
+
+func TestUserToModem_ToggleMode(t *testing.T) {
+	ts := &TerminalSession{}
+	// Line mode, switch to raw, type raw keys, switch back, send a line.
+	r := strings.NewReader("\r~rab\r~rcd\r")
+	var modemBuf, echoBuf bytes.Buffer
+
+	ts.userToModem(r, &modemBuf, &echoBuf)
+
+	if got, want := modemBuf.String(), "\rab\rcd\r"; got != want {
+		t.Errorf("modem output = %q, want %q", got, want)
+	}
+	if !strings.Contains(echoBuf.String(), "Raw mode") || !strings.Contains(echoBuf.String(), "Line mode") {
+		t.Errorf("expected mode change notices in echo, got %q", echoBuf.String())
+	}
+	if ts.raw.Load() {
+		t.Error("expected to end in line mode")
+	}
+}
+
 func TestSetStdinStdout_Used(t *testing.T) {
 	ts := &TerminalSession{}
 
@@ -218,13 +289,3 @@ func TestWakeLoop_SendsEntersUntilData(t *testing.T) {
 		}
 	}
 }
-
-func TestCarrierLost_SkipsHangup(t *testing.T) {
-	ts := &TerminalSession{}
-	// Simulate carrier loss
-	ts.carrierLost.Store(true)
-
-	if !ts.carrierLost.Load() {
-		t.Error("expected carrierLost to be true")
-	}
-}
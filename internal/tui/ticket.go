@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// TicketModel asks for a change ticket and reason after a site is picked,
+// for sites that need one before they are dialed.
+type TicketModel struct {
+	index      int // the site's index in Deps.Sites
+	site       config.Site
+	rule       *regexp.Regexp
+	inputs     []textinput.Model // ticket ID, reason
+	focusIndex int
+	err        string
+	theme      Theme
+}
+
+// NewTicketModel creates the ticket form for the site at index, whose
+// ticket must match rule.
+func NewTicketModel(index int, site config.Site, rule *regexp.Regexp, theme Theme) TicketModel {
+	id := textinput.New()
+	id.Placeholder = "e.g. CHG0012345"
+	id.CharLimit = 64
+	reason := textinput.New()
+	reason.Placeholder = "why you need console access"
+	reason.CharLimit = 200
+	reason.Width = 50
+
+	m := TicketModel{
+		index:  index,
+		site:   site,
+		rule:   rule,
+		inputs: []textinput.Model{id, reason},
+		theme:  theme,
+	}
+	m.inputs[0].Focus()
+	return m
+}
+
+func (m TicketModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// focus moves the cursor to field i, wrapping around.
+func (m *TicketModel) focus(i int) {
+	m.inputs[m.focusIndex].Blur()
+	m.focusIndex = (i + len(m.inputs)) % len(m.inputs)
+	m.inputs[m.focusIndex].Focus()
+}
+
+func (m TicketModel) Update(msg tea.Msg) (TicketModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "tab", "down":
+			m.focus(m.focusIndex + 1)
+			return m, nil
+		case "shift+tab", "up":
+			m.focus(m.focusIndex - 1)
+			return m, nil
+
+		case "enter":
+			if m.focusIndex < len(m.inputs)-1 {
+				m.focus(m.focusIndex + 1)
+				return m, nil
+			}
+			ticket := session.Ticket{
+				ID:     strings.TrimSpace(m.inputs[0].Value()),
+				Reason: strings.TrimSpace(m.inputs[1].Value()),
+			}
+			if err := ticket.Check(m.rule); err != nil {
+				m.err = err.Error()
+				return m, nil
+			}
+			return m, func() tea.Msg { return TicketEnteredMsg{SiteIndex: m.index, Ticket: ticket} }
+
+		case "esc", "ctrl+c":
+			return m, func() tea.Msg { return TicketCancelledMsg{} }
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focusIndex], cmd = m.inputs[m.focusIndex].Update(msg)
+	return m, cmd
+}
+
+func (m TicketModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.theme.TitleStyle.Render("Change Ticket"))
+	b.WriteString("\n\n")
+	b.WriteString(m.theme.LabelStyle.Render(fmt.Sprintf("  %s needs a change ticket and reason before it is dialed.", m.site.Name)))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "  Ticket: %s\n", m.inputs[0].View())
+	fmt.Fprintf(&b, "  Reason: %s\n", m.inputs[1].View())
+	b.WriteString("\n")
+	b.WriteString(m.theme.LabelStyle.Render("  Ticket must match " + m.rule.String()))
+
+	if m.err != "" {
+		b.WriteString("\n\n")
+		b.WriteString(m.theme.ErrorStyle.Render("  " + m.err))
+	}
+
+	b.WriteString("\n\n")
+	b.WriteString(m.theme.LabelStyle.Render("  Tab to switch fields | Enter to dial | Esc to cancel"))
+
+	return m.theme.BoxStyle.Render(b.String())
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func newTicketTestModel(t *testing.T, pattern *regexp.Regexp) Model {
+	t.Helper()
+	deps, _ := newTestDeps(t, "alice")
+	deps.Sites = []config.Site{{Name: "lab"}, {Name: "core", NoTicket: true}}
+	deps.TicketPattern = pattern
+	return New(context.Background(), "alice", deps, nil, false, nil)
+}
+
+// feed runs msg through the root model and then any message its command
+// produces, as the ticket form answers with a command.
+func feed(m Model, msg tea.Msg) Model {
+	next, cmd := m.Update(msg)
+	m = next.(Model)
+	if cmd != nil {
+		if out := cmd(); out != nil {
+			switch out.(type) {
+			case TicketEnteredMsg, TicketCancelledMsg:
+				next, _ = m.Update(out)
+				m = next.(Model)
+			}
+		}
+	}
+	return m
+}
+
+func TestTicketPromptBeforeDial(t *testing.T) {
+	m := newTicketTestModel(t, regexp.MustCompile(`^CHG[0-9]+$`))
+
+	m = feed(m, DialRequestMsg{SiteIndex: 0})
+	if m.state != StateTicket {
+		t.Fatalf("state = %v, want the ticket form", m.state)
+	}
+	m = feed(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("INC42")})
+	m = feed(m, tea.KeyMsg{Type: tea.KeyEnter})
+	m = feed(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("replace PSU")})
+	m = feed(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.state != StateTicket || !strings.Contains(m.View(), "does not match") {
+		t.Fatalf("bad ticket accepted; state %v:\n%s", m.state, m.View())
+	}
+
+	m = feed(m, tea.KeyMsg{Type: tea.KeyShiftTab})
+	for range len("INC42") {
+		m = feed(m, tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	m = feed(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("CHG7")})
+	m = feed(m, tea.KeyMsg{Type: tea.KeyEnter})
+	m = feed(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.state != StateDialing {
+		t.Fatalf("state = %v, want dialing", m.state)
+	}
+	if want := (session.Ticket{ID: "CHG7", Reason: "replace PSU"}); m.activeTicket != want || m.dialing.ticket != want {
+		t.Errorf("ticket = %+v, want %+v", m.activeTicket, want)
+	}
+}
+
+func TestTicketPromptSkippedAndCancelled(t *testing.T) {
+	m := newTicketTestModel(t, regexp.MustCompile(`^CHG[0-9]+$`))
+	if m = feed(m, DialRequestMsg{SiteIndex: 1}); m.state != StateDialing {
+		t.Errorf("exempt site: state = %v, want dialing", m.state)
+	}
+
+	m = newTicketTestModel(t, regexp.MustCompile(`^CHG[0-9]+$`))
+	m = feed(m, DialRequestMsg{SiteIndex: 0})
+	if m = feed(m, tea.KeyMsg{Type: tea.KeyEsc}); m.state != StateMenu {
+		t.Errorf("esc: state = %v, want menu", m.state)
+	}
+
+	m = newTicketTestModel(t, nil)
+	if m = feed(m, DialRequestMsg{SiteIndex: 0}); m.state != StateDialing {
+		t.Errorf("no pattern: state = %v, want dialing", m.state)
+	}
+}
+
+func TestTicketAudited(t *testing.T) {
+	m := newTicketTestModel(t, regexp.MustCompile(`^CHG[0-9]+$`))
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var err error
+	if m.audit, err = session.OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	m = feed(m, DialRequestMsg{SiteIndex: 0})
+	for _, key := range []string{"CHG7", "enter", "replace PSU", "enter"} {
+		if key == "enter" {
+			m = feed(m, tea.KeyMsg{Type: tea.KeyEnter})
+		} else {
+			m = feed(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		}
+	}
+
+	mdm, dev, remote := testModem(t)
+	m = feed(m, DialResultMsg{Result: modem.ResultConnect, Modem: mdm, Device: dev, Attempts: 1})
+	if m.activeCall == nil {
+		t.Fatalf("call not started; state %v:\n%s", m.state, m.View())
+	}
+	remote.Close()
+	<-m.activeCall.Done()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e session.AuditEvent
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Event != "dial" || e.User != "alice" || e.Site != "lab" || e.Ticket != "CHG7" || e.Reason != "replace PSU" {
+		t.Errorf("audit event = %+v", e)
+	}
+}
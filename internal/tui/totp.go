@@ -0,0 +1,225 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// totpStep is where the user is on the two-factor screen.
+type totpStep int
+
+const (
+	totpStatus   totpStep = iota // showing whether two-factor login is on
+	totpEnroll                   // scanning the QR code, entering a code to confirm
+	totpRegen                    // entering a code to replace the recovery codes
+	totpRecovery                 // showing new recovery codes, once
+)
+
+// TOTPModel lets users enroll an authenticator app for two-factor login
+// and replace their recovery codes. Only an admin can turn it off again,
+// with oob-manage.
+type TOTPModel struct {
+	store    auth.UserStore
+	username string
+	theme    Theme
+
+	step     totpStep
+	enabled  bool
+	left     int // unused recovery codes
+	secret   string
+	qr       string
+	recovery []string
+	input    textinput.Model
+
+	notice string
+	err    string
+}
+
+// NewTOTPModel creates the two-factor screen for username.
+func NewTOTPModel(username string, store auth.UserStore, theme Theme) TOTPModel {
+	in := textinput.New()
+	in.Placeholder = "123456"
+	in.CharLimit = 6
+	in.Width = 10
+	m := TOTPModel{store: store, username: username, theme: theme, input: in}
+	m.reload()
+	return m
+}
+
+func (m *TOTPModel) reload() {
+	info, err := m.store.Get(m.username)
+	if err != nil {
+		m.err = fmt.Sprintf("loading two-factor status: %v", err)
+		return
+	}
+	m.enabled = info.TOTPEnabled
+	m.left = info.RecoveryCodesLeft
+}
+
+func (m TOTPModel) Update(msg tea.Msg) (TOTPModel, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if m.step == totpEnroll || m.step == totpRegen {
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+	if key.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	m.notice, m.err = "", ""
+
+	switch m.step {
+	case totpEnroll, totpRegen:
+		switch key.String() {
+		case "enter":
+			m.confirm(strings.TrimSpace(m.input.Value()))
+			return m, nil
+		case "esc":
+			m.step = totpStatus
+			m.input.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	case totpRecovery:
+		if key.String() == "enter" || key.String() == "esc" {
+			m.step = totpStatus
+			m.recovery = nil
+		}
+		return m, nil
+	}
+
+	switch key.String() {
+	case "e":
+		if !m.enabled {
+			m.begin()
+			return m, m.input.Focus()
+		}
+	case "r":
+		if m.enabled {
+			m.step = totpRegen
+			m.input.Reset()
+			return m, m.input.Focus()
+		}
+	case "esc", "q":
+		return m, func() tea.Msg { return TOTPDoneMsg{} }
+	}
+	return m, nil
+}
+
+// begin starts enrollment with a new secret and renders its QR code.
+func (m *TOTPModel) begin() {
+	secret, err := m.store.BeginTOTP(m.username)
+	if err != nil {
+		m.err = err.Error()
+		return
+	}
+	qr, err := qrcode.New(auth.TOTPURI(m.username, secret), qrcode.Low)
+	if err != nil {
+		m.err = fmt.Sprintf("rendering QR code: %v", err)
+		return
+	}
+	m.secret = secret
+	m.qr = qr.ToSmallString(false)
+	m.step = totpEnroll
+	m.input.Reset()
+}
+
+// confirm checks code to finish enrolling or to replace recovery codes.
+func (m *TOTPModel) confirm(code string) {
+	var recovery []string
+	var err error
+	if m.step == totpEnroll {
+		recovery, err = m.store.ConfirmTOTP(m.username, code)
+	} else {
+		recovery, err = m.store.NewRecoveryCodes(m.username, code)
+	}
+	if err != nil {
+		m.err = err.Error()
+		m.input.Reset()
+		return
+	}
+	if m.step == totpEnroll {
+		m.notice = "Two-factor login is on. You'll be asked for a code each time you log in."
+	}
+	m.input.Blur()
+	m.secret, m.qr = "", ""
+	m.recovery = recovery
+	m.step = totpRecovery
+	m.reload()
+}
+
+func (m TOTPModel) View() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Two-Factor Login"))
+	b.WriteString("\n\n")
+
+	switch m.step {
+	case totpEnroll:
+		b.WriteString(m.theme.LabelStyle.Render("  Scan this with your authenticator app:"))
+		b.WriteString("\n\n")
+		for _, line := range strings.Split(strings.TrimRight(m.qr, "\n"), "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(m.theme.LabelStyle.Render("  Or enter this key by hand: "))
+		b.WriteString(m.secret)
+		b.WriteString("\n\n")
+		fmt.Fprintf(&b, "  Code from the app: %s\n", m.input.View())
+	case totpRegen:
+		b.WriteString(m.theme.LabelStyle.Render("  New recovery codes replace all the old ones."))
+		b.WriteString("\n\n")
+		fmt.Fprintf(&b, "  Code from your app: %s\n", m.input.View())
+	case totpRecovery:
+		if m.notice != "" {
+			b.WriteString(m.theme.SuccessStyle.Render("  " + m.notice))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(m.theme.WarningStyle.Render("  Recovery codes — write these down now, they won't be shown again."))
+		b.WriteString("\n")
+		b.WriteString(m.theme.LabelStyle.Render("  Each one logs you in once in place of a code if you lose your device."))
+		b.WriteString("\n\n")
+		for _, code := range m.recovery {
+			b.WriteString("    " + code + "\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(m.theme.LabelStyle.Render("  Enter when done"))
+		return m.theme.BoxStyle.Render(b.String())
+	default:
+		if m.enabled {
+			b.WriteString(m.theme.SuccessStyle.Render("  Two-factor login: on"))
+			fmt.Fprintf(&b, "\n  Recovery codes left: %d\n", m.left)
+			if m.left <= 2 {
+				b.WriteString(m.theme.WarningStyle.Render("  Running low; press r for new ones."))
+				b.WriteString("\n")
+			}
+		} else {
+			b.WriteString(m.theme.LabelStyle.Render("  Two-factor login: off"))
+			b.WriteString("\n  Turn it on to be asked for a code from an authenticator app after\n  your password or key each time you log in.\n")
+		}
+	}
+
+	b.WriteString("\n")
+	if m.err != "" {
+		b.WriteString(m.theme.ErrorStyle.Render("  " + m.err))
+		b.WriteString("\n")
+	}
+	switch {
+	case m.step != totpStatus:
+		b.WriteString(m.theme.LabelStyle.Render("  Enter to confirm · Esc to cancel"))
+	case m.enabled:
+		b.WriteString(m.theme.LabelStyle.Render("  r new recovery codes · esc back"))
+	default:
+		b.WriteString(m.theme.LabelStyle.Render("  e set up · esc back"))
+	}
+	return m.theme.BoxStyle.Render(b.String())
+}
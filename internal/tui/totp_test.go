@@ -0,0 +1,56 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+)
+
+func TestTOTPScreen(t *testing.T) {
+	store, err := auth.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add("alice", "password1")
+
+	press := func(m TOTPModel, k string) TOTPModel {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		if k == "enter" {
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		m, _ = m.Update(msg)
+		return m
+	}
+
+	m := NewTOTPModel("alice", store, NewTheme(nil))
+	if !strings.Contains(m.View(), "Two-factor login: off") {
+		t.Fatalf("not shown as off:\n%s", m.View())
+	}
+	m = press(m, "e")
+	if m.step != totpEnroll || !strings.Contains(m.View(), m.secret) || !strings.Contains(m.View(), "█") {
+		t.Fatalf("enrollment doesn't show the QR code and key:\n%s", m.View())
+	}
+
+	m = press(m, "000000")
+	m = press(m, "enter")
+	if m.step != totpEnroll || m.err == "" {
+		t.Errorf("wrong code accepted:\n%s", m.View())
+	}
+
+	code, _ := auth.TOTPCode(m.secret, time.Now())
+	m = press(m, code)
+	m = press(m, "enter")
+	if m.step != totpRecovery || len(m.recovery) == 0 || !strings.Contains(m.View(), m.recovery[0]) {
+		t.Fatalf("recovery codes not shown:\n%s", m.View())
+	}
+	m = press(m, "enter")
+	if !strings.Contains(m.View(), "Two-factor login: on") || m.recovery != nil {
+		t.Errorf("not shown as on:\n%s", m.View())
+	}
+	if info, _ := store.Get("alice"); !info.TOTPEnabled {
+		t.Error("store not enrolled")
+	}
+}
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/config"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+// whoTickMsg refreshes the who's-on dashboard.
+type whoTickMsg struct{}
+
+func whoTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return whoTickMsg{} })
+}
+
+// WhoModel is the live dashboard of every SSH user connected to the hub
+// and what they are doing. Sites the user has no role on are hidden.
+type WhoModel struct {
+	sessions *session.Registry
+	username string
+	access   auth.Access
+	sites    []config.Site
+	theme    Theme
+}
+
+// NewWhoModel creates the dashboard.
+func NewWhoModel(username string, sessions *session.Registry, access auth.Access, sites []config.Site, theme Theme) WhoModel {
+	return WhoModel{sessions: sessions, username: username, access: access, sites: sites, theme: theme}
+}
+
+func (m WhoModel) Init() tea.Cmd {
+	return whoTick()
+}
+
+func (m WhoModel) Update(msg tea.Msg) (WhoModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case whoTickMsg:
+		return m, whoTick()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "w":
+			return m, func() tea.Msg { return WhoDoneMsg{} }
+		case "ctrl+c":
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m WhoModel) View() string {
+	var b strings.Builder
+	b.WriteString(m.theme.TitleStyle.Render("Who's On"))
+	b.WriteString("\n\n")
+	b.WriteString(whoTable(m.sessions.List(), m.username, m.access, m.sites, time.Now()))
+	b.WriteString("\n")
+	b.WriteString(m.theme.LabelStyle.Render("  Updates live · esc back"))
+	return m.theme.BoxStyle.Render(b.String())
+}
+
+// whoTable lays out the sessions one per line, marking the viewer's own
+// and showing sites access gives no role on as hiddenSite.
+func whoTable(list []session.PresenceInfo, self string, access auth.Access, sites []config.Site, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %-16s %-10s %-16s %-14s %-9s %s\n", "USER", "STATE", "SITE", "DEVICE", "CALL", "IDLE")
+	for _, p := range list {
+		mark := " "
+		if p.User == self {
+			mark = "*"
+		}
+		site, device, call := "—", "—", "—"
+		if p.Site != "" {
+			site = truncate(siteLabel(access, sites, p.Site), 16)
+		}
+		if p.Device != "" {
+			device = p.Device
+		}
+		if !p.CallStarted.IsZero() {
+			call = clock(now.Sub(p.CallStarted))
+		}
+		fmt.Fprintf(&b, " %s%-16s %-10s %-16s %-14s %-9s %s\n",
+			mark, truncate(p.User, 16), p.State, site, device, call, clock(p.Idle))
+	}
+	if len(list) == 0 {
+		b.WriteString("  Nobody is connected.\n")
+	}
+	return b.String()
+}
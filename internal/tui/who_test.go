@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gbm-dev/pots/internal/auth"
+	"github.com/gbm-dev/pots/internal/modem"
+	"github.com/gbm-dev/pots/internal/session"
+)
+
+func TestWhoTable(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	got := whoTable([]session.PresenceInfo{
+		{User: "alice", State: session.PresenceMenu, Idle: 90 * time.Second},
+		{User: "bob", State: session.PresenceConnected, Site: "router1", Device: "/dev/ttyIAX0", CallStarted: now.Add(-time.Hour - 5*time.Minute), Idle: 3 * time.Second},
+	}, "bob", auth.Access{}, nil, now)
+	lines := strings.Split(strings.TrimRight(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("table:\n%s", got)
+	}
+	if f := strings.Fields(lines[1]); f[0] != "alice" || f[1] != "menu" || f[2] != "—" || f[5] != "1:30" {
+		t.Errorf("alice row = %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[0] != "*bob" || f[2] != "router1" || f[3] != "/dev/ttyIAX0" || f[4] != "1:05:00" || f[5] != "0:03" {
+		t.Errorf("bob row = %q", lines[2])
+	}
+}
+
+func TestRestrictedSitesHidden(t *testing.T) {
+	_, store := newTestDeps(t, "alice")
+	store.AddGrant(auth.Grant{Subject: "alice", Target: "tag:nyc", Role: auth.RoleViewer})
+	store.SetAccessEnforced(true)
+	access, err := store.Access("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	got := whoTable([]session.PresenceInfo{
+		{User: "bob", State: session.PresenceConnected, Site: "nyc-sw1", Device: "/dev/ttyIAX0", CallStarted: now},
+		{User: "carol", State: session.PresenceConnected, Site: "chi-rtr1", Device: "/dev/ttyIAX1", CallStarted: now},
+	}, "alice", access, menuSites, now)
+	lines := strings.Split(strings.TrimRight(got, "\n"), "\n")
+	if f := strings.Fields(lines[1]); f[2] != "nyc-sw1" {
+		t.Errorf("bob row = %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); f[2] != hiddenSite {
+		t.Errorf("carol row = %q, want site hidden", lines[2])
+	}
+	// Nor does the modem queue name the busy lines' sites.
+	dir := t.TempDir()
+	devs := []string{filepath.Join(dir, "ttyIAX0"), filepath.Join(dir, "ttyIAX1")}
+	for _, dev := range devs {
+		os.WriteFile(dev, nil, 0o600)
+	}
+	lock := modem.NewDeviceLock(devs...)
+	lock.Acquire("nyc-sw1")
+	lock.Acquire("chi-rtr1")
+	d := NewDialingModel(context.Background(), menuSites[3], "alice", session.Ticket{}, lock, session.NewManager(t.TempDir(), 0, 1024, 0, nil), NewTheme(nil))
+	d.access, d.sites = access, menuSites
+	want := []string{"    " + devs[0] + ": nyc-sw1", "    " + devs[1] + ": " + hiddenSite}
+	if got := d.holders(); !slices.Equal(got, want) {
+		t.Errorf("holders = %q, want %q", got, want)
+	}
+}
+
+func TestModelKeepsPresence(t *testing.T) {
+	deps, _ := newTestDeps(t, "alice")
+	reg := session.NewRegistry()
+	deps.Sessions = reg
+	var model tea.Model = New(context.Background(), "alice", deps, reg.Register("alice", "10.0.0.1:5000"), false, nil)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	model, _ = model.Update(cmd())
+	if s := model.(Model).state; s != StateWho {
+		t.Fatalf("state = %v, want who", s)
+	}
+	if view := model.View(); !strings.Contains(view, "*alice") {
+		t.Errorf("dashboard does not list alice:\n%s", view)
+	}
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model, _ = model.Update(cmd())
+
+	model, _ = model.Update(DialRequestMsg{SiteIndex: 2})
+	if p := reg.List()[0]; p.State != session.PresenceDialing || p.Site != "chi-rtr1" {
+		t.Errorf("presence = %+v, want dialing chi-rtr1", p)
+	}
+}
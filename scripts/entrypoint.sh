@@ -55,18 +55,21 @@ sh -c "ulimit -n 1024; slmodemd -e /usr/local/bin/d-modem" &
 DMODEM_PID=$!
 echo "  D-Modem started (PID ${DMODEM_PID})"
 
-# Wait for modem device to appear
-echo "Waiting for ${DEVICE_PATH}..."
-for i in $(seq 1 10); do
-    if [[ -e "${DEVICE_PATH}" ]]; then
-        echo "  ${DEVICE_PATH} - OK"
-        break
-    fi
-    if [ "$i" -eq 10 ]; then
-        echo "  ERROR: ${DEVICE_PATH} did not appear after 10s"
-        exit 1
-    fi
-    sleep 1
+# Wait for modem devices to appear (DEVICE_PATH may list a comma-separated pool)
+IFS=',' read -ra DEVICES <<< "${DEVICE_PATH}"
+for dev in "${DEVICES[@]}"; do
+    echo "Waiting for ${dev}..."
+    for i in $(seq 1 10); do
+        if [[ -e "${dev}" ]]; then
+            echo "  ${dev} - OK"
+            break
+        fi
+        if [ "$i" -eq 10 ]; then
+            echo "  ERROR: ${dev} did not appear after 10s"
+            exit 1
+        fi
+        sleep 1
+    done
 done
 
 # --- Start Asterisk ---